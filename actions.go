@@ -0,0 +1,681 @@
+package main
+
+// Registry of the actions of the interactive interface. Keys are bound to
+// actions by name (see the keymap), commands typed on the command line run
+// actions by name, and the help page lists them in the order they are
+// registered.
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// An action of the interactive interface.
+type Action struct {
+	Name        string
+	Aliases     []string
+	Description string
+
+	// Arguments accepted on the command line, e.g. `[URI...]`.
+	Args string
+
+	// Run on the application's event loop, with a count of at least 1. If
+	// nil, the action can only be run from the command line.
+	Run func(ui *UI, count int)
+
+	// Run on the application's event loop from the command line. If nil,
+	// the only argument accepted is a count.
+	Command func(ui *UI, args []string) error
+
+	// Get the candidates for completing an argument on the command line.
+	Complete func(ui *UI) []string
+}
+
+// Every action, in the order they are listed on the help page.
+var actions = []*Action{
+	{
+		Name: "select",
+		Description: "Play the selected item, open the selected playlist, album, or show, or move playback to the selected device",
+		Run: func(ui *UI, _ int) {
+			table, page := ui.currentTable()
+			if table == nil {
+				ui.showPage("listing")
+				return
+			}
+
+			row, _ := table.GetSelection()
+			switch page {
+			case "playlists":
+				ui.openPlaylist(row)
+			case "albums":
+				ui.openAlbum(row)
+			case "shows":
+				ui.openShow(row)
+			case "devices":
+				ui.selectDevice(row)
+			case "search", "changes", "history", "stats":
+				RunAction(ui, "play", 1)
+			case "help":
+				ui.runHelpAction(row)
+			default:
+				ui.playItem(table, row)
+			}
+		},
+	},
+	{
+		Name: "play",
+		Args: "[URI...]",
+		Description: "Play the selected item, group, or collection, or some URIs (or links)",
+		Run: func(ui *UI, _ int) {
+			if group, ok := ui.selectedGroup(); ok {
+				ui.tx <- RequestPlayURIList(group.URIs())
+				return
+			}
+			if item, ok := ui.selectedItem(); ok {
+				ui.tx <- RequestResumeURI(item.URI(), item.ResumePosition())
+				return
+			}
+
+			uri, ok := ui.selectedURI()
+			if !ok {
+				log.Error("invalid URI")
+				return
+			}
+			ui.tx <- RequestPlayURI(uri.Spotify())
+		},
+		Command: func(ui *UI, args []string) error {
+			if len(args) == 0 {
+				return ui.runAction("play", 1)
+			}
+			return ui.playURIs(args)
+		},
+	},
+	{
+		Name: "queue",
+		Args: "[URI...]",
+		Description: "Add the selected item or group, or some URIs (or links), to the queue",
+		Run: func(ui *UI, count int) {
+			if group, ok := ui.selectedGroup(); ok {
+				for i := 0; i < count; i++ {
+					for _, uri := range group.URIs() {
+						ui.tx <- RequestQueueURI(uri)
+					}
+				}
+				return
+			}
+
+			uri, ok := ui.selectedURI()
+			if !ok {
+				log.Error("invalid URI")
+				return
+			}
+			if !uri.IsPlayable() {
+				log.Errorf("cannot queue a URI of type %s", uri.Type)
+				return
+			}
+			for i := 0; i < count; i++ {
+				ui.tx <- RequestQueueURI(uri.Spotify())
+			}
+		},
+		Command: func(ui *UI, args []string) error {
+			if len(args) == 0 {
+				return ui.runAction("queue", 1)
+			}
+			return ui.queueURIs(args)
+		},
+	},
+	{
+		Name: "toggle",
+		Description: "Pause or resume playback",
+		Run: func(ui *UI, _ int) {
+			ui.tx <- RequestToggle()
+		},
+	},
+	{
+		Name: "next",
+		Description: "Skip to the next item",
+		Run: func(ui *UI, count int) {
+			for i := 0; i < count; i++ {
+				ui.tx <- RequestPlayNext()
+			}
+		},
+	},
+	{
+		Name: "previous",
+		Description: "Skip to the previous item",
+		Run: func(ui *UI, count int) {
+			for i := 0; i < count; i++ {
+				ui.tx <- RequestPlayPrevious()
+			}
+		},
+	},
+	{
+		Name: "volume",
+		Args: "PERCENT|+N|-N",
+		Description: "Set the volume, or change it by some percent",
+		Command: func(ui *UI, args []string) error {
+			return ui.setVolume(args)
+		},
+	},
+	{
+		Name: "device",
+		Args: "NAME|ID",
+		Description: "Move playback to another device",
+		Command: func(ui *UI, args []string) error {
+			return ui.transferPlayback(args)
+		},
+		Complete: func(ui *UI) []string {
+			return ui.deviceNames
+		},
+	},
+	{
+		Name: "paste",
+		Description: "Play the URIs or links on the clipboard",
+		Run: func(ui *UI, _ int) {
+			go func() {
+				text, err := ReadClipboard()
+				if err != nil {
+					log.WithError(err).Error("failed to read clipboard")
+					return
+				}
+				playPasted(text, ui.tx)
+			}()
+		},
+	},
+	{
+		Name: "down",
+		Description: "Move the selection down",
+		Run: func(ui *UI, count int) {
+			ui.moveSelection(count)
+		},
+	},
+	{
+		Name: "up",
+		Description: "Move the selection up",
+		Run: func(ui *UI, count int) {
+			ui.moveSelection(-count)
+		},
+	},
+	{
+		Name: "page-down",
+		Description: "Move the selection down a page",
+		Run: func(ui *UI, count int) {
+			ui.moveSelection(count * ui.pageSize())
+		},
+	},
+	{
+		Name: "page-up",
+		Description: "Move the selection up a page",
+		Run: func(ui *UI, count int) {
+			ui.moveSelection(-count * ui.pageSize())
+		},
+	},
+	{
+		Name: "top",
+		Description: "Move the selection to the first row",
+		Run: func(ui *UI, _ int) {
+			ui.moveSelection(-ui.rowCount())
+		},
+	},
+	{
+		Name: "bottom",
+		Description: "Move the selection to the last loaded row",
+		Run: func(ui *UI, _ int) {
+			ui.moveSelection(ui.rowCount())
+		},
+	},
+	{
+		Name: "sort",
+		Args: "[ORDER [desc]]",
+		Description: "Sort the listing, or restore the order it was loaded in",
+		Command: func(ui *UI, args []string) error {
+			return ui.sortItems(args)
+		},
+		Complete: func(ui *UI) []string {
+			return ItemOrders()
+		},
+	},
+	{
+		Name: "filter",
+		Args: "[TEXT]",
+		Description: "Show only items whose name, artist, or album match, or show every item",
+		Command: func(ui *UI, args []string) error {
+			return ui.filterItems(args)
+		},
+	},
+	{
+		Name: "view",
+		Args: "[flat|artist|album]",
+		Description: "Group the listing by artist and album, or by album, or switch to the next grouping",
+		Run: func(ui *UI, _ int) {
+			table, ok := ui.currentItems()
+			if !ok {
+				log.Info("nothing to group on this page")
+				return
+			}
+			table.Group(nextGrouping(table.Grouping()))
+		},
+		Command: func(ui *UI, args []string) error {
+			return ui.groupItems(args)
+		},
+		Complete: func(ui *UI) []string {
+			return ItemGroupings()
+		},
+	},
+	{
+		Name: "fold",
+		Description: "Collapse or expand the selected group, or collapse the group of the selected item",
+		Run: func(ui *UI, _ int) {
+			if table, ok := ui.currentItems(); ok {
+				table.Fold(table.selectedRow())
+			}
+		},
+	},
+	{
+		Name: "fold-all",
+		Description: "Collapse every group",
+		Run: func(ui *UI, _ int) {
+			if table, ok := ui.currentItems(); ok {
+				table.FoldAll(true)
+			}
+		},
+	},
+	{
+		Name: "unfold-all",
+		Description: "Expand every group",
+		Run: func(ui *UI, _ int) {
+			if table, ok := ui.currentItems(); ok {
+				table.FoldAll(false)
+			}
+		},
+	},
+	{
+		Name: "details",
+		Description: "Show or hide the details of the selected row",
+		Run: func(ui *UI, _ int) {
+			ui.toggleDetails()
+		},
+	},
+	{
+		Name: "open-artist",
+		Description: "Show the top tracks of the artist of the selected track (with a count, the Nth artist)",
+		Run: func(ui *UI, count int) {
+			ui.openSelected(ui.selectedReference(), count)
+		},
+	},
+	{
+		Name: "open-album",
+		Description: "Show the album of the selected track, or the show of the selected episode",
+		Run: func(ui *UI, _ int) {
+			ui.openSelected(ui.selectedReference(), 0)
+		},
+	},
+	{
+		Name: "mark",
+		Description: "Mark or unmark the selected item (or group) and move down, to copy several at once",
+		Run: func(ui *UI, count int) {
+			table, ok := ui.currentItems()
+			if !ok {
+				return
+			}
+			for i := 0; i < count; i++ {
+				table.Mark(table.selectedRow())
+				ui.moveSelection(1)
+			}
+		},
+	},
+	{
+		Name: "unmark-all",
+		Description: "Unmark every item",
+		Run: func(ui *UI, _ int) {
+			if table, ok := ui.currentItems(); ok {
+				table.Unmark()
+			}
+		},
+	},
+	{
+		Name: "copy-uri",
+		Description: "Copy the URI of the marked items, or else the selected row, to the clipboard",
+		Run: func(ui *UI, _ int) {
+			ui.copyReferences(ui.copyTargets(), "uri")
+		},
+	},
+	{
+		Name: "copy-link",
+		Description: "Copy a link on open.spotify.com to the marked items, or else the selected row",
+		Run: func(ui *UI, _ int) {
+			ui.copyReferences(ui.copyTargets(), "link")
+		},
+	},
+	{
+		Name: "copy-text",
+		Description: "Copy the marked items, or else the selected row, as text like: Artist – Title",
+		Run: func(ui *UI, _ int) {
+			ui.copyReferences(ui.copyTargets(), "text")
+		},
+	},
+	{
+		Name: "copy-markdown",
+		Description: "Copy the marked items, or else the selected row, as Markdown links",
+		Run: func(ui *UI, _ int) {
+			ui.copyReferences(ui.copyTargets(), "markdown")
+		},
+	},
+	{
+		Name: "export",
+		Args: "FORMAT FILE",
+		Description: "Export the shown or selected playlist, or else the saved tracks, to a file",
+		Command: func(ui *UI, args []string) error {
+			return ui.exportItems(args)
+		},
+		Complete: func(ui *UI) []string {
+			return ExportFormats()
+		},
+	},
+	{
+		Name: "snapshot",
+		Description: "Save a snapshot of the saved tracks",
+		Run: func(ui *UI, _ int) {
+			if ui.snapshotDir == "" {
+				ui.message(errSnapshotsDisabled.Error())
+				return
+			}
+			go ui.takeSnapshot()
+		},
+	},
+	{
+		Name: "diff",
+		Args: "[OLD [NEW]]",
+		Description: "Compare two snapshots, or a snapshot (by default the latest) with the saved tracks",
+		Run: func(ui *UI, _ int) {
+			go ui.showDiff(nil)
+		},
+		Command: func(ui *UI, args []string) error {
+			if len(args) > 2 {
+				return fmt.Errorf("diff: too many arguments")
+			}
+			go ui.showDiff(args)
+			return nil
+		},
+		Complete: func(ui *UI) []string {
+			names, _ := ListSnapshots(ui.snapshotDir)
+			return names
+		},
+	},
+	{
+		Name: "duplicates",
+		Description: "Find the saved tracks that are duplicated, by ISRC or by artist, name, and duration",
+		Run: func(ui *UI, _ int) {
+			go ui.findDuplicates()
+		},
+	},
+	{
+		Name: "keep",
+		Description: "Keep the selected copy of a duplicated track, instead of the others",
+		Run: func(ui *UI, _ int) {
+			table, page := ui.currentTable()
+			if page != "duplicates" {
+				log.Info("nothing to keep on this page (try :duplicates)")
+				return
+			}
+			row, _ := table.GetSelection()
+			ui.keepDuplicate(row)
+		},
+	},
+	{
+		Name: "remove-duplicates",
+		Description: "Remove every copy of a duplicated track that is not kept from the saved tracks, after confirming",
+		Run: func(ui *UI, _ int) {
+			ui.removeDuplicates()
+		},
+	},
+	{
+		Name: "list",
+		Args: "[NAME]",
+		Description: "Show a smart list, or else the next smart list",
+		Run: func(ui *UI, _ int) {
+			if err := ui.openSmartList(""); err != nil {
+				log.Error(err)
+			}
+		},
+		Command: func(ui *UI, args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("list: too many arguments")
+			}
+			if err := ui.openSmartList(strings.Join(args, "")); err != nil {
+				return fmt.Errorf("list: %w", err)
+			}
+			return nil
+		},
+		Complete: func(ui *UI) []string {
+			return ui.smartListNames()
+		},
+	},
+	{
+		Name: "sync-list",
+		Description: "Replace the tracks of the shown smart list's playlist, or of a new playlist, after confirming",
+		Run: func(ui *UI, _ int) {
+			if err := ui.syncSmartList(); err != nil {
+				log.Error(err)
+			}
+		},
+	},
+	{
+		Name: "play-all",
+		Description: "Play every item shown on the page, in the order shown",
+		Run: func(ui *UI, _ int) {
+			uris := ui.shownURIs()
+			if len(uris) == 0 {
+				log.Info("nothing to play on this page")
+				return
+			}
+			ui.tx <- RequestPlayURIList(uris)
+		},
+	},
+	{
+		Name: "queue-all",
+		Description: "Add every item shown on the page to the queue, in the order shown",
+		Run: func(ui *UI, count int) {
+			uris := ui.shownURIs()
+			if len(uris) == 0 {
+				log.Info("nothing to queue on this page")
+				return
+			}
+			for i := 0; i < count; i++ {
+				for _, uri := range uris {
+					ui.tx <- RequestQueueURI(uri)
+				}
+			}
+		},
+	},
+	{
+		Name: "history",
+		Args: "[SINCE [UNTIL]]",
+		Description: "Show the play history, or the plays since a time like 2024-01-31, today, or 7d",
+		Run: func(ui *UI, _ int) {
+			ui.showHistory(nil)
+		},
+		Command: func(ui *UI, args []string) error {
+			return ui.showHistory(args)
+		},
+		Complete: func(ui *UI) []string {
+			return []string{"today", "yesterday", "7d", "30d"}
+		},
+	},
+	{
+		Name: "stats",
+		Args: "[SINCE [UNTIL]]",
+		Description: "Show listening statistics of the play history and the saved tracks, or of the plays since a time",
+		Run: func(ui *UI, _ int) {
+			ui.showStats(nil)
+		},
+		Command: func(ui *UI, args []string) error {
+			return ui.showStats(args)
+		},
+		Complete: func(ui *UI) []string {
+			return []string{"today", "yesterday", "7d", "30d"}
+		},
+	},
+	{
+		Name: "export-stats",
+		Args: "FILE",
+		Description: "Export the listening statistics shown as JSON",
+		Command: func(ui *UI, args []string) error {
+			return ui.exportStats(args)
+		},
+	},
+	{
+		Name: "jump-to-playing",
+		Description: "Select the playing item",
+		Run: func(ui *UI, _ int) {
+			ui.jumpToPlaying()
+		},
+	},
+	{
+		Name: "refresh",
+		Description: "Reload the playlists, albums, podcasts, queue, or devices",
+		Run: func(ui *UI, _ int) {
+			ui.refreshPage()
+		},
+	},
+	{
+		Name: "show-listing",
+		Description: "Show the saved tracks (tab 1)",
+		Run: func(ui *UI, _ int) {
+			ui.showTab(0)
+		},
+	},
+	{
+		Name: "show-playlists",
+		Description: "Show the playlists (tab 2)",
+		Run: func(ui *UI, _ int) {
+			ui.showTab(1)
+		},
+	},
+	{
+		Name: "show-albums",
+		Description: "Show the saved albums (tab 3)",
+		Run: func(ui *UI, _ int) {
+			ui.showTab(2)
+		},
+	},
+	{
+		Name: "show-shows",
+		Description: "Show the saved podcasts (tab 4)",
+		Run: func(ui *UI, _ int) {
+			ui.showTab(3)
+		},
+	},
+	{
+		Name: "show-search",
+		Description: "Show the search page (tab 5)",
+		Run: func(ui *UI, _ int) {
+			ui.showTab(4)
+		},
+	},
+	{
+		Name: "show-queue",
+		Description: "Show the queue (tab 6)",
+		Run: func(ui *UI, _ int) {
+			ui.showTab(5)
+		},
+	},
+	{
+		Name: "show-devices",
+		Description: "Show the devices (tab 7)",
+		Run: func(ui *UI, _ int) {
+			ui.showTab(6)
+		},
+	},
+	{
+		Name: "show-logs",
+		Description: "Show the logs (tab 8)",
+		Run: func(ui *UI, _ int) {
+			ui.showTab(7)
+		},
+	},
+	{
+		Name: "show-help",
+		Description: "Show this help, listing every action (tab 9)",
+		Run: func(ui *UI, _ int) {
+			ui.showTab(8)
+		},
+	},
+	{
+		Name: "next-tab",
+		Description: "Show the next tab",
+		Run: func(ui *UI, count int) {
+			ui.cycleTab(count)
+		},
+	},
+	{
+		Name: "previous-tab",
+		Description: "Show the previous tab",
+		Run: func(ui *UI, count int) {
+			ui.cycleTab(-count)
+		},
+	},
+	{
+		Name: "command-line",
+		Description: "Open the command line, to run any action by name",
+		Run: func(ui *UI, _ int) {
+			ui.openCommandLine()
+		},
+	},
+	{
+		Name: "quit",
+		Aliases: []string{"q"},
+		Description: "Quit",
+		Run: func(ui *UI, _ int) {
+			ui.app.Stop()
+		},
+	},
+}
+
+// Actions by name and by alias. Built from the registry on start up.
+var actionsByName = map[string]*Action{}
+
+func init() {
+	for _, action := range actions {
+		actionsByName[action.Name] = action
+		for _, alias := range action.Aliases {
+			actionsByName[alias] = action
+		}
+	}
+}
+
+// Get an action by name.
+func LookupAction(name string) (*Action, bool) {
+	action, ok := actionsByName[name]
+	return action, ok
+}
+
+// Check if an action exists and can be bound to a key.
+func KnownAction(name string) bool {
+	action, ok := actionsByName[name]
+	return ok && action.Run != nil
+}
+
+// Run an action by name.
+func RunAction(ui *UI, name string, count int) {
+	if err := ui.runAction(name, count); err != nil {
+		log.Error(err)
+	}
+}
+
+// Run an action by name, if it can run without arguments.
+func (ui *UI) runAction(name string, count int) error {
+	action, ok := LookupAction(name)
+	if !ok {
+		return fmt.Errorf("unknown action: %s", name)
+	}
+
+	if action.Run == nil {
+		return fmt.Errorf("%s: missing arguments: %s", action.Name, action.Args)
+	}
+
+	action.Run(ui, count)
+	return nil
+}
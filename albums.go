@@ -0,0 +1,105 @@
+package main
+
+// Spotify albums interactions.
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
+)
+
+// Create a row of table cells from a saved Spotify album. The first cell
+// references the album.
+func AlbumIntoCells(album *spotify.SavedAlbum) []*tview.TableCell {
+	name := tview.NewTableCell(album.Name).SetReference(&album.SimpleAlbum)
+	artist := tview.NewTableCell(FormatArtists(album.Artists))
+	released := tview.NewTableCell(album.ReleaseDate)
+	tracks := tview.NewTableCell(fmt.Sprint(album.Tracks.Total)).SetAlign(tview.AlignRight)
+
+	return []*tview.TableCell{name, artist, released, tracks}
+}
+
+// Fetch all of the end user's saved albums.
+func FetchAlbums(ctx context.Context, cli *spotify.Client) ([]spotify.SavedAlbum, error) {
+	log.Trace("fetching first page of albums...")
+	page, err := cli.CurrentUsersAlbums(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	albums := page.Albums
+	for {
+		log.Trace("fetching a new page of albums...")
+		err = cli.NextPage(ctx, page)
+		if err == spotify.ErrNoMorePages {
+			return albums, nil
+		}
+		if err != nil {
+			return albums, err
+		}
+
+		albums = append(albums, page.Albums...)
+	}
+}
+
+// Fetch the tracks of an album, in album order. The tracks are sent through
+// the channel as they are fetched, and the channel is closed once there are
+// no more tracks or the context is cancelled.
+func FetchAlbumTracks(ctx context.Context, cli *spotify.Client, album *spotify.SimpleAlbum, ch chan<- *Item) {
+	defer close(ch)
+
+	log.Tracef("fetching first page of tracks for %s...", album.Name)
+	page, err := cli.GetAlbumTracks(ctx, album.ID)
+	if err != nil {
+		log.WithError(err).Errorf("failed to fetch tracks for %s", album.Name)
+		return
+	}
+
+	for {
+		for i := range page.Tracks {
+			// The album tracks endpoint omits the album, but it
+			// is needed for display.
+			track := &spotify.FullTrack{
+				SimpleTrack: page.Tracks[i],
+				Album: *album,
+			}
+
+			select {
+			case ch <- &Item{Track: track}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		log.Trace("fetching a new page of album tracks...")
+		err = cli.NextPage(ctx, page)
+		if err == spotify.ErrNoMorePages {
+			log.Debug("no more pages of album tracks")
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("failed to fetch a page of album tracks")
+			return
+		}
+	}
+}
+
+// Manager for the listing of saved albums. Albums are loaded eagerly.
+func AlbumsManager(ctx context.Context, cli *spotify.Client, app *tview.Application, albums *tview.Table) {
+	saved, err := FetchAlbums(ctx, cli)
+	if err != nil {
+		log.WithError(err).Error("failed to fetch albums")
+	}
+	log.Tracef("fetched %d albums", len(saved))
+
+	app.QueueUpdateDraw(func() {
+		for row := range saved {
+			for col, cell := range AlbumIntoCells(&saved[row]) {
+				albums.SetCell(row, col, cell)
+			}
+		}
+	})
+}
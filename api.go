@@ -0,0 +1,66 @@
+package main
+
+// Spotify Web API interactions that are not wrapped by the client library.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Base address of the Spotify Web API.
+const apiBase = "https://api.spotify.com/v1/"
+
+// Make an authorized request to a Spotify Web API endpoint. If `result` is
+// not nil, the response body is decoded into it.
+func apiRequest(ctx context.Context, cli *spotify.Client, method, endpoint string, query url.Values, result any) error {
+	tok, err := cli.Token()
+	if err != nil {
+		return err
+	}
+
+	target := apiBase + endpoint
+	if len(query) != 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return err
+	}
+	tok.SetAuthHeader(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("spotify: HTTP %d: %s", resp.StatusCode, body)
+	}
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// Enqueue any playable URI on a device. Unlike `QueueSong`, this is not
+// limited to tracks.
+func QueueURIOnDevice(ctx context.Context, cli *spotify.Client, dev spotify.ID, uri spotify.URI) error {
+	query := url.Values{}
+	query.Set("uri", string(uri))
+	if dev != "" {
+		query.Set("device_id", dev.String())
+	}
+
+	return apiRequest(ctx, cli, http.MethodPost, "me/player/queue", query, nil)
+}
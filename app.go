@@ -2,7 +2,11 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zmb3/spotify/v2"
@@ -10,132 +14,1037 @@ import (
 	"github.com/rivo/tview"
 )
 
+// State of the terminal application, shared by every action.
+type UI struct {
+	ctx context.Context
+	cli *spotify.Client
+	app *tview.Application
+	tx  chan<- *Event
+
+	// Screen of the application, e.g. to set the terminal's clipboard.
+	screen tcell.Screen
+
+	state *StateWatcher
+
+	keymap  *Keymap
+	theme   *Theme
+	playing *Playing
+
+	tabs      *TabBar
+	body      *tview.Flex
+	pages     *tview.Pages
+	details   *DetailsPane
+	bar       *tview.Pages
+	status    *tview.TextView
+	messages  *tview.TextView
+	cmdline   *CommandLine
+	logs      *tview.TextView
+	help      *HelpPage
+	listing   *ItemTable
+	playlists *tview.Table
+	playlist  *ItemTable
+	albums    *tview.Table
+	album     *ItemTable
+	shows     *tview.Table
+	episodes  *ItemTable
+	search    *SearchPage
+	queue     *ItemTable
+	devices   *tview.Table
+	artist    *ItemTable
+	changes   *tview.Table
+	duplicates *tview.Table
+	smart     *ItemTable
+	history   *tview.Table
+	stats     *tview.Table
+
+	// Cancels loading items into a page, e.g. the episodes of the
+	// previously selected show, by page name.
+	loading map[string]func()
+
+	// Page to return to from the help page.
+	previousPage string
+
+	// Pages to return to from pages that can be opened from anywhere, e.g.
+	// the artist page, by page name.
+	openedFrom map[string]string
+
+	// Playlist shown on the playlist page, e.g. to export it.
+	shownPlaylist *spotify.SimplePlaylist
+
+	// Directory of library snapshots.
+	snapshotDir string
+
+	// Groups of duplicates shown on the duplicates page.
+	duplicateGroups []*duplicateGroup
+
+	// File of play history, and the range (`[SINCE [UNTIL]]`) shown on
+	// the history page.
+	historyPath string
+	historyArgs []string
+
+	// Statistics shown on the stats page, e.g. to export them.
+	shownStats *Stats
+
+	// Smart lists, and the smart list shown on the smart list page.
+	smartLists []*SmartList
+	shownList  *SmartList
+
+	// Names of the end user's devices, for completion.
+	deviceNames []string
+}
+
+// Root of the application. Intercepts text pasted into the terminal.
+type pasteRoot struct {
+	*tview.Flex
+	paste func(text string)
+}
+
+// Handle text pasted into the terminal.
+func (root *pasteRoot) PasteHandler() func(string, func(tview.Primitive)) {
+	return root.WrapPasteHandler(func(text string, _ func(tview.Primitive)) {
+		root.paste(text)
+	})
+}
+
+// Request that some pasted URIs (or links) be played.
+func playPasted(text string, tx chan<- *Event) {
+	uris, err := ParseURIs(text)
+	if err != nil {
+		log.WithError(err).Error("failed to parse pasted URI")
+		return
+	}
+
+	for _, ev := range RequestPlayURIs(uris) {
+		tx <- ev
+	}
+}
+
+// Get the table on the current page, if any.
+func (ui *UI) currentTable() (*tview.Table, string) {
+	name, _ := ui.pages.GetFrontPage()
+	switch name {
+	case "listing":
+		return ui.listing.Table, name
+	case "playlists":
+		return ui.playlists, name
+	case "playlist":
+		return ui.playlist.Table, name
+	case "albums":
+		return ui.albums, name
+	case "album":
+		return ui.album.Table, name
+	case "shows":
+		return ui.shows, name
+	case "episodes":
+		return ui.episodes.Table, name
+	case "search":
+		return ui.search.results, name
+	case "queue":
+		return ui.queue.Table, name
+	case "devices":
+		return ui.devices, name
+	case "artist":
+		return ui.artist.Table, name
+	case "changes":
+		return ui.changes, name
+	case "duplicates":
+		return ui.duplicates, name
+	case "smart":
+		return ui.smart.Table, name
+	case "history":
+		return ui.history, name
+	case "stats":
+		return ui.stats, name
+	case "help":
+		return ui.help.table, name
+	}
+	return nil, name
+}
+
+// Get the table of items on the current page, if any.
+func (ui *UI) currentItems() (*ItemTable, bool) {
+	name, _ := ui.pages.GetFrontPage()
+	switch name {
+	case "listing":
+		return ui.listing, true
+	case "playlist":
+		return ui.playlist, true
+	case "album":
+		return ui.album, true
+	case "episodes":
+		return ui.episodes, true
+	case "queue":
+		return ui.queue, true
+	case "artist":
+		return ui.artist, true
+	case "smart":
+		return ui.smart, true
+	}
+	return nil, false
+}
+
+// Get the item selected on the current page, if any.
+func (ui *UI) selectedItem() (*Item, bool) {
+	table, _ := ui.currentTable()
+	if table == nil {
+		return nil, false
+	}
+
+	row, _ := table.GetSelection()
+	return ItemAt(table, row)
+}
+
+// Get the URIs of the items shown on the current page, in the order shown.
+func (ui *UI) shownURIs() []spotify.URI {
+	table, ok := ui.currentItems()
+	if !ok {
+		return nil
+	}
+
+	uris := []spotify.URI{}
+	for _, item := range table.Shown() {
+		uris = append(uris, item.URI())
+	}
+	return uris
+}
+
+// Get the group selected on the current page, if any.
+func (ui *UI) selectedGroup() (*itemGroup, bool) {
+	table, _ := ui.currentTable()
+	if table == nil {
+		return nil, false
+	}
+
+	row, _ := table.GetSelection()
+	return groupAt(table, row)
+}
+
+// Get the reference of the row selected on the current page, if any.
+func (ui *UI) selectedReference() any {
+	table, _ := ui.currentTable()
+	if table == nil {
+		return nil
+	}
+
+	row, _ := table.GetSelection()
+	cell := table.GetCell(row, 0)
+	if cell == nil {
+		return nil
+	}
+	return cell.GetReference()
+}
+
+// Get the URI selected on the current page, if any. This may be an item, or
+// a collection like a playlist.
+func (ui *UI) selectedURI() (*URI, bool) {
+	return referenceURI(ui.selectedReference())
+}
+
+// Get the URI of the reference of a row, if any.
+func referenceURI(ref any) (*URI, bool) {
+	var uri spotify.URI
+	switch ref := ref.(type) {
+	case *SearchResult:
+		uri = ref.URI
+	case *Item:
+		uri = ref.URI()
+	case *spotify.SimplePlaylist:
+		uri = ref.URI
+	case *spotify.SimpleAlbum:
+		uri = ref.URI
+	case *spotify.SimpleShow:
+		uri = ref.URI
+	case *SnapshotTrack:
+		uri = spotify.URI(ref.URI)
+	case *HistoryEntry:
+		uri = ref.URI
+	case *StatsRank:
+		uri = ref.URI
+	default:
+		return nil, false
+	}
+
+	parsed, err := ParseURI(string(uri))
+	if err != nil {
+		return nil, false
+	}
+	return parsed, true
+}
+
+// Switch to a page, activating its tab. Pages that list what the player is
+// doing are reloaded.
+func (ui *UI) showPage(name string) {
+	current, _ := ui.pages.GetFrontPage()
+	if name == "help" && current != "help" {
+		ui.previousPage = current
+	}
+
+	ui.pages.SwitchToPage(name)
+	ui.tabs.Visit(name)
+
+	switch name {
+	case "queue":
+		go LoadQueue(ui.ctx, ui.cli, ui.app, ui.queue)
+	case "devices":
+		go LoadDevices(ui.ctx, ui.cli, ui.app, ui.devices)
+	case "history":
+		go ui.loadHistory()
+	}
+}
+
+// Switch to a tab, showing the page it showed last.
+func (ui *UI) showTab(tab int) {
+	ui.showPage(ui.tabs.Page(tab))
+}
+
+// Switch to a tab relative to the active tab, wrapping around.
+func (ui *UI) cycleTab(delta int) {
+	n := len(tabs)
+	ui.showTab(((ui.tabs.Active()+delta)%n + n) % n)
+}
+
+// Reload the current page, if it lists something that can change.
+func (ui *UI) refreshPage() {
+	name, _ := ui.pages.GetFrontPage()
+	switch name {
+	case "playlists":
+		go PlaylistsManager(ui.ctx, ui.cli, ui.app, ui.playlists)
+	case "albums":
+		go AlbumsManager(ui.ctx, ui.cli, ui.app, ui.albums)
+	case "shows":
+		go ShowsManager(ui.ctx, ui.cli, ui.app, ui.shows)
+	case "queue", "devices", "history":
+		ui.showPage(name)
+	default:
+		log.Infof("nothing to refresh on page: %s", name)
+	}
+}
+
+// Ask the end user to confirm something in a dialog over the current page,
+// then run `yes` if confirmed. Must be called on the event loop.
+func (ui *UI) confirm(text, button string, yes func()) {
+	dialog := tview.NewModal().SetText(text).AddButtons([]string{button, "Cancel"})
+	dialog.SetDoneFunc(func(index int, _ string) {
+		ui.pages.RemovePage("confirm")
+		ui.app.SetFocus(ui.pages)
+		if index == 0 {
+			yes()
+		}
+	})
+
+	ui.pages.AddPage("confirm", dialog, false, true)
+	ui.app.SetFocus(dialog)
+}
+
+// Remember the page shown before opening a page, to return to it. Returning
+// to a page does not count as opening it, so that two pages never return to
+// each other.
+func (ui *UI) openingPage(name string) {
+	current, _ := ui.pages.GetFrontPage()
+	if current != name && current != "help" && ui.openedFrom[current] != name {
+		ui.openedFrom[name] = current
+	}
+}
+
+// Leave a page, returning to the page it was opened from, or else a default.
+func (ui *UI) leavePage(name, fallback string) {
+	page := ui.openedFrom[name]
+	if page == "" {
+		page = fallback
+	}
+	ui.showPage(page)
+}
+
+// Leave the help page, returning to the page it was opened from.
+func (ui *UI) closeHelp() {
+	page := ui.previousPage
+	if page == "" {
+		page = "listing"
+	}
+	ui.showPage(page)
+}
+
+// Run the action in a row of the help page. The help page is closed first,
+// so that the action applies to the page it was opened from.
+func (ui *UI) runHelpAction(row int) {
+	action, ok := ui.help.ActionAt(row)
+	if !ok {
+		return
+	}
+
+	ui.closeHelp()
+
+	// Actions that need arguments are started on the command line.
+	if action.Run == nil {
+		ui.openCommandLine()
+		ui.cmdline.SetText(action.Name + " ")
+		return
+	}
+
+	if action.Name != "select" {
+		action.Run(ui, 1)
+	}
+}
+
+// Request that an item be played. Episodes resume from where the end user
+// left off. A group plays its items in order.
+func (ui *UI) playItem(table *tview.Table, row int) {
+	if group, ok := groupAt(table, row); ok {
+		ui.tx <- RequestPlayURIList(group.URIs())
+		return
+	}
+
+	item, ok := ItemAt(table, row)
+	if !ok {
+		log.Error("invalid URI")
+	} else {
+		ui.tx <- RequestResumeURI(item.URI(), item.ResumePosition())
+	}
+}
+
+// Load items into the table of a page, replacing any items still loading into
+// it, and switch to the page.
+func (ui *UI) openItems(page string, table *ItemTable, fetch func(ctx context.Context, ch chan<- *Item)) {
+	if stop, ok := ui.loading[page]; ok {
+		stop()
+	}
+	ctx, stop := context.WithCancel(ui.ctx)
+	ui.loading[page] = stop
+
+	table.Clear().Select(1, 0)
+
+	ch := make(chan *Item, fetchingBuffer)
+	go fetch(ctx, ch)
+	go ItemsManager(ctx, ui.app, table, ch)
+	ui.showPage(page)
+}
+
+// Load the episodes of the show in a row of the shows page, and switch to
+// the episodes page.
+func (ui *UI) openShow(row int) {
+	show, ok := ui.shows.GetCell(row, 0).GetReference().(*spotify.SimpleShow)
+	if !ok {
+		log.Error("invalid show")
+		return
+	}
+
+	ui.loadShow(show)
+}
+
+// Load the episodes of a show, and switch to the episodes page.
+func (ui *UI) loadShow(show *spotify.SimpleShow) {
+	ui.openItems("episodes", ui.episodes, func(ctx context.Context, ch chan<- *Item) {
+		FetchEpisodes(ctx, ui.cli, show, ch)
+	})
+}
+
+// Load the items of the playlist in a row of the playlists page, and switch
+// to the playlist page.
+func (ui *UI) openPlaylist(row int) {
+	playlist, ok := ui.playlists.GetCell(row, 0).GetReference().(*spotify.SimplePlaylist)
+	if !ok {
+		log.Error("invalid playlist")
+		return
+	}
+
+	ui.shownPlaylist = playlist
+	ui.openItems("playlist", ui.playlist, func(ctx context.Context, ch chan<- *Item) {
+		if err := FetchPlaylistItems(ctx, ui.cli, playlist, ch); err != nil && ctx.Err() == nil {
+			log.WithError(err).Error("failed to fetch playlist items")
+		}
+	})
+}
+
+// Load the tracks of the album in a row of the albums page, and switch to
+// the album page.
+func (ui *UI) openAlbum(row int) {
+	album, ok := ui.albums.GetCell(row, 0).GetReference().(*spotify.SimpleAlbum)
+	if !ok {
+		log.Error("invalid album")
+		return
+	}
+
+	ui.loadAlbum(album)
+}
+
+// Load the tracks of an album, and switch to the album page.
+func (ui *UI) loadAlbum(album *spotify.SimpleAlbum) {
+	ui.openItems("album", ui.album, func(ctx context.Context, ch chan<- *Item) {
+		FetchAlbumTracks(ctx, ui.cli, album, ch)
+	})
+}
+
+// Load the top tracks of an artist, and switch to the artist page.
+func (ui *UI) loadArtist(artist *spotify.SimpleArtist) {
+	ui.openingPage("artist")
+	ui.openItems("artist", ui.artist, func(ctx context.Context, ch chan<- *Item) {
+		FetchArtistTopTracks(ctx, ui.cli, artist, ch)
+	})
+}
+
+// Open an artist of the selected item (counting from 1), or the album (or
+// show) of the selected item.
+func (ui *UI) openSelected(ref any, artist int) {
+	item, ok := ref.(*Item)
+	if !ok {
+		log.Info("no artist or album to open")
+		return
+	}
+
+	switch {
+	case item.Episode != nil:
+		ui.loadShow(&item.Episode.Show)
+	case artist == 0:
+		ui.loadAlbum(&item.Track.Album)
+	case artist <= len(item.Track.Artists):
+		ui.loadArtist(&item.Track.Artists[artist-1])
+	default:
+		log.Infof("no artist %d", artist)
+	}
+}
+
+// Show or hide the details pane.
+func (ui *UI) toggleDetails() {
+	if ui.body.GetItemCount() > 1 {
+		ui.body.RemoveItem(ui.details)
+	} else {
+		ui.body.AddItem(ui.details, 0, 1, false)
+	}
+}
+
+// Follow a link clicked in the details pane.
+func (ui *UI) followDetailsLink(region string) {
+	ref := ui.details.shown
+
+	switch region {
+	case "copy-uri":
+		ui.copyReferences([]any{ref}, "uri")
+	case "copy-link":
+		ui.copyReferences([]any{ref}, "link")
+	case "album":
+		ui.openSelected(ref, 0)
+	default:
+		var n int
+		if _, err := fmt.Sscanf(region, "artist-%d", &n); err == nil {
+			ui.openSelected(ref, n+1)
+		}
+	}
+}
+
+// Move playback to the device in a row of the devices page.
+func (ui *UI) selectDevice(row int) {
+	device, ok := ui.devices.GetCell(row, 0).GetReference().(*spotify.PlayerDevice)
+	if !ok {
+		log.Error("invalid device")
+		return
+	}
+
+	ui.tx <- RequestTransfer(device.ID)
+
+	// The active device is marked once playback has moved.
+	go func() {
+		time.Sleep(stateTimeout * time.Second)
+		LoadDevices(ui.ctx, ui.cli, ui.app, ui.devices)
+	}()
+}
+
+// Search the Spotify catalog and list the results on the search page.
+func (ui *UI) runSearch(query string) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return
+	}
+
+	go func() {
+		results, err := Search(ui.ctx, ui.cli, query)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		ui.app.QueueUpdateDraw(func() {
+			ui.search.Show(results)
+			ui.app.SetFocus(ui.search.results)
+		})
+	}()
+}
+
+// Select the playing item in the current table, or else in the listing.
+func (ui *UI) jumpToPlaying() {
+	if ui.playing.URI() == "" {
+		log.Info("nothing is playing")
+		return
+	}
+
+	table, _ := ui.currentTable()
+	if table != nil {
+		if row, ok := ui.playing.Find(table); ok {
+			table.Select(row, 0)
+			return
+		}
+	}
+
+	if row, ok := ui.playing.Find(ui.listing.Table); ok {
+		ui.showPage("listing")
+		ui.listing.Select(row, 0)
+		return
+	}
+
+	log.Info("playing item is not loaded")
+}
+
+// Move the selection of the current table by some rows.
+func (ui *UI) moveSelection(delta int) {
+	table, _ := ui.currentTable()
+	if table == nil {
+		return
+	}
+
+	row, _ := table.GetSelection()
+	row += delta
+
+	if last := table.GetRowCount() - 1; row > last {
+		row = last
+	}
+	if row < 0 {
+		row = 0
+	}
+
+	// Skip headers.
+	for row < table.GetRowCount()-1 && table.GetCell(row, 0).NotSelectable {
+		row++
+	}
+
+	table.Select(row, 0)
+}
+
+// Get the row of a table at a line of the screen, or -1 if none. The first
+// rows of the table may be fixed, e.g. as a header.
+func rowAt(table *tview.Table, fixed, y int) int {
+	_, top, _, height := table.GetInnerRect()
+	line := y - top
+	if line < 0 || line >= height {
+		return -1
+	}
+	if line < fixed {
+		return line
+	}
+
+	offset, _ := table.GetOffset()
+	row := line + offset
+	if row >= table.GetRowCount() {
+		return -1
+	}
+	return row
+}
+
+// Handle double-clicks on a table like `Enter`. The first click selected the
+// row.
+func (ui *UI) doubleClickCapture(table *tview.Table, fixed int) func(tview.MouseAction, *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+	return func(action tview.MouseAction, ev *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		if action != tview.MouseLeftDoubleClick {
+			return action, ev
+		}
+
+		_, y := ev.Position()
+		selected, _ := table.GetSelection()
+		if row := rowAt(table, fixed, y); row >= fixed && row == selected {
+			RunAction(ui, "select", 1)
+		}
+
+		return action, nil
+	}
+}
+
+// Get the number of rows in the current table.
+func (ui *UI) rowCount() int {
+	table, _ := ui.currentTable()
+	if table == nil {
+		return 0
+	}
+	return table.GetRowCount()
+}
+
+// Get the number of rows visible in the current table.
+func (ui *UI) pageSize() int {
+	table, _ := ui.currentTable()
+	if table == nil {
+		return 1
+	}
+
+	_, _, _, height := table.GetInnerRect()
+	if height < 1 {
+		return 1
+	}
+	return height
+}
+
+// Handle a key anywhere, checking the keymap first.
+func (ui *UI) inputCapture(ev *tcell.EventKey) *tcell.EventKey {
+	// Messages are dismissed by any key.
+	if name, _ := ui.bar.GetFrontPage(); name == "message" {
+		ui.bar.SwitchToPage("status")
+	}
+
+	// Text being typed is never a key binding, nor are the buttons of a
+	// dialog.
+	if _, typing := ui.app.GetFocus().(*tview.InputField); typing {
+		ui.keymap.Reset()
+		return ev
+	}
+	if _, pressing := ui.app.GetFocus().(*tview.Button); pressing {
+		ui.keymap.Reset()
+		return ev
+	}
+
+	name, count, consumed := ui.keymap.Handle(ev)
+	if name != "" {
+		log.Tracef("action: %s (x%d)", name, count)
+		RunAction(ui, name, count)
+	}
+	if consumed {
+		return nil
+	}
+
+	// Pass event back to application, will be routed to focused
+	// primitive.
+	return ev
+}
+
 // Start the core application and return once it terminates.
-func Start(ctx context.Context, rx <-chan *spotify.FullTrack, tx chan<- *Event) {
+func Start(ctx context.Context, cli *spotify.Client, cfg *Config, state *StateWatcher, rx <-chan *Item, tx chan<- *Event) {
 	ctx, cancel := context.WithCancel(ctx)
-	app := tview.NewApplication()
-	pages := tview.NewPages()
 
-	//TODO: help := tview.NewTextView().SetScrollable(false).ScrollToEnd()
-	//fmt.Fprint(help, `help
-	//text
-	//here`)
-	//pages.AddPage("help", help, true, false)
+	keymap, err := cfg.NewKeymap()
+	if err != nil {
+		log.WithError(err).Fatal("invalid keymap")
+	}
+
+	theme, err := cfg.NewTheme()
+	if err != nil {
+		log.WithError(err).Fatal("invalid theme")
+	}
+
+	lists, err := ParseSmartLists(cfg.Lists)
+	if err != nil {
+		log.WithError(err).Fatal("invalid smart list")
+	}
+
+	// Must be applied before any primitives are created.
+	theme.Apply()
+
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		log.WithError(err).Fatal("failed to open terminal")
+	}
+
+	ui := &UI{
+		ctx: ctx,
+		cli: cli,
+		app: tview.NewApplication().SetScreen(screen),
+		tx: tx,
+		screen: screen,
+		state: state,
+		keymap: keymap,
+		theme: theme,
+		playing: NewPlaying(theme),
+		pages: tview.NewPages(),
+		loading: map[string]func(){},
+		openedFrom: map[string]string{},
+		snapshotDir: cfg.SnapshotDir,
+		smartLists: lists,
+		historyPath: cfg.History,
+	}
+
+	ui.tabs = NewTabBar(theme, ui.showTab)
+
+	ui.help = NewHelpPage(keymap, theme)
+	ui.help.table.SetMouseCapture(ui.doubleClickCapture(ui.help.table, 1))
+	ui.pages.AddPage("help", ui.help, true, false)
+
+	// End user pressed one of `Escape`, `Enter`, `Tab`, or `Backtab` in the
+	// search field of the help page.
+	ui.help.search.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			ui.closeHelp()
+		} else {
+			ui.app.SetFocus(ui.help.table)
+		}
+	})
+
+	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the help page.
+	ui.help.table.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			ui.closeHelp()
+		} else {
+			ui.app.SetFocus(ui.help.search)
+		}
+	})
 
-	logs := tview.NewTextView().SetDynamicColors(true).SetScrollable(false).ScrollToEnd()
-	log.SetOutput(tview.ANSIWriter(logs))
-	pages.AddPage("logs", logs, true, true)
+	ui.logs = tview.NewTextView().SetDynamicColors(true).SetScrollable(false).ScrollToEnd()
+	log.SetFormatter(&themeFormatter{theme})
+	log.SetOutput(ui.logs)
+	ui.pages.AddPage("logs", ui.logs, true, true)
 
 	// End user pressed one of `Escape`, `Enter`, `Tab`, or `Backtab` on the logs
 	// page.
-	logs.SetDoneFunc(func(key tcell.Key) {
-		pages.SwitchToPage("listing")
+	ui.logs.SetDoneFunc(func(key tcell.Key) {
+		ui.showPage("listing")
 	})
 
 	// Redraw if logs have been written.
-	logs.SetChangedFunc(func() {
-		app.Draw()
+	ui.logs.SetChangedFunc(func() {
+		ui.app.Draw()
 	})
 
-	listing := tview.NewTable().SetSelectable(true, false).Select(0, 0)
-	go ListingManager(ctx, listing, rx)
-	pages.AddPage("listing", listing, true, false)
+	ui.listing = NewItemTable(trackColumns, theme, ui.playing)
+	ui.listing.SetMouseCapture(ui.doubleClickCapture(ui.listing.Table, 1))
+	go ListingManager(ctx, ui.listing, rx)
+	ui.pages.AddPage("listing", ui.listing, true, false)
 
-	// End user pressed `Enter` on the listing page.
-	listing.SetSelectedFunc(func(row, _ int) {
-		uri, ok := listing.GetCell(row, 0).GetReference().(spotify.URI)
-		if !ok {
-			log.Error("invalid URI")
-		} else {
-			tx <- RequestPlayURI(uri)
+	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the listing page.
+	// Also triggers if user pressed `Enter` when nothing is selected.
+	ui.listing.SetDoneFunc(func(key tcell.Key) {
+	})
+
+	ui.playlists = theme.ApplyTable(tview.NewTable().SetSelectable(true, false).Select(0, 0))
+	ui.playlists.SetMouseCapture(ui.doubleClickCapture(ui.playlists, 0))
+	go PlaylistsManager(ctx, cli, ui.app, ui.playlists)
+	ui.pages.AddPage("playlists", ui.playlists, true, false)
+
+	ui.playlist = NewItemTable(trackColumns, theme, ui.playing)
+	ui.playlist.SetMouseCapture(ui.doubleClickCapture(ui.playlist.Table, 1))
+	ui.pages.AddPage("playlist", ui.playlist, true, false)
+
+	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the playlist
+	// page.
+	ui.playlist.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			ui.showPage("playlists")
 		}
 	})
 
-	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the listing page.
-	// Also triggers if user pressed `Enter` when nothing is selected.
-	listing.SetDoneFunc(func(key tcell.Key) {
+	ui.albums = theme.ApplyTable(tview.NewTable().SetSelectable(true, false).Select(0, 0))
+	ui.albums.SetMouseCapture(ui.doubleClickCapture(ui.albums, 0))
+	go AlbumsManager(ctx, cli, ui.app, ui.albums)
+	ui.pages.AddPage("albums", ui.albums, true, false)
+
+	ui.album = NewItemTable(trackColumns, theme, ui.playing)
+	ui.album.SetMouseCapture(ui.doubleClickCapture(ui.album.Table, 1))
+	ui.pages.AddPage("album", ui.album, true, false)
+
+	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the album
+	// page.
+	ui.album.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			ui.showPage("albums")
+		}
+	})
+
+	ui.shows = theme.ApplyTable(tview.NewTable().SetSelectable(true, false).Select(0, 0))
+	ui.shows.SetMouseCapture(ui.doubleClickCapture(ui.shows, 0))
+	go ShowsManager(ctx, cli, ui.app, ui.shows)
+	ui.pages.AddPage("shows", ui.shows, true, false)
+
+	ui.episodes = NewItemTable(episodeColumns, theme, ui.playing)
+	ui.episodes.SetMouseCapture(ui.doubleClickCapture(ui.episodes.Table, 1))
+	ui.pages.AddPage("episodes", ui.episodes, true, false)
+
+	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the episodes
+	// page.
+	ui.episodes.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			ui.showPage("shows")
+		}
 	})
 
-	listing.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
-		if ev.Key() == tcell.KeyRune {
-			switch ev.Rune() {
+	ui.search = NewSearchPage(theme)
+	ui.search.results.SetMouseCapture(ui.doubleClickCapture(ui.search.results, 0))
+	ui.pages.AddPage("search", ui.search, true, false)
 
-			// End user pressed `p` on the listing.
-			case 'p':
-				tx <- RequestToggle()
+	// End user pressed one of `Escape`, `Enter`, `Tab`, or `Backtab` in the
+	// search field.
+	ui.search.input.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			ui.runSearch(ui.search.input.GetText())
+		case tcell.KeyEscape, tcell.KeyTab, tcell.KeyBacktab:
+			ui.app.SetFocus(ui.search.results)
+		}
+	})
 
-			// End user pressed `f` on the listing.
-			case 'f':
-				tx <- RequestPlayNext()
+	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the search
+	// results.
+	ui.search.results.SetDoneFunc(func(key tcell.Key) {
+		ui.app.SetFocus(ui.search.input)
+	})
 
-			// End user pressed `b` on the listing.
-			case 'b':
-				tx <- RequestPlayPrevious()
+	ui.queue = NewItemTable(trackColumns, theme, ui.playing)
+	ui.queue.SetMouseCapture(ui.doubleClickCapture(ui.queue.Table, 1))
+	go QueueManager(ctx, cli, ui.app, ui.queue, state)
+	ui.pages.AddPage("queue", ui.queue, true, false)
 
-			// End user pressed `Space` on the listing.
-			case ' ':
-				row, _ := listing.GetSelection()
-				uri, ok := listing.GetCell(row, 0).GetReference().(spotify.URI)
-				if !ok {
-					log.Error("invalid URI")
-				} else {
-					tx <- RequestQueueURI(uri)
-				}
+	ui.history = theme.ApplyTable(tview.NewTable().SetSelectable(true, false))
+	ui.history.SetMouseCapture(ui.doubleClickCapture(ui.history, 1))
+	ui.pages.AddPage("history", ui.history, true, false)
 
-			}
+	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the history
+	// page.
+	ui.history.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			ui.leavePage("history", "queue")
+		}
+	})
+
+	ui.stats = theme.ApplyTable(tview.NewTable().SetSelectable(true, false))
+	ui.stats.SetMouseCapture(ui.doubleClickCapture(ui.stats, 0))
+	ui.pages.AddPage("stats", ui.stats, true, false)
+
+	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the stats
+	// page.
+	ui.stats.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			ui.leavePage("stats", "history")
 		}
+	})
 
-		// Pass event back to primitive for other handlers.
-		return ev
+	ui.devices = theme.ApplyTable(tview.NewTable().SetSelectable(true, false).Select(0, 0))
+	ui.devices.SetMouseCapture(ui.doubleClickCapture(ui.devices, 0))
+	ui.pages.AddPage("devices", ui.devices, true, false)
+
+	ui.artist = NewItemTable(trackColumns, theme, ui.playing)
+	ui.artist.SetMouseCapture(ui.doubleClickCapture(ui.artist.Table, 1))
+	ui.pages.AddPage("artist", ui.artist, true, false)
+
+	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the artist
+	// page.
+	ui.artist.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			ui.leavePage("artist", "search")
+		}
 	})
 
-	app.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
-		switch ev.Key() {
+	ui.changes = theme.ApplyTable(tview.NewTable().SetSelectable(true, false))
+	ui.changes.SetMouseCapture(ui.doubleClickCapture(ui.changes, 0))
+	ui.pages.AddPage("changes", ui.changes, true, false)
 
-		// End user pressed `F1` anywhere.
-		case tcell.KeyF1:
-			pages.SwitchToPage("listing")
-			return nil
+	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the changes
+	// page.
+	ui.changes.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			ui.showPage("listing")
+		}
+	})
 
-		// End user pressed `F2` anywhere.
-		case tcell.KeyF2:
-			pages.SwitchToPage("logs")
-			return nil
+	ui.duplicates = theme.ApplyTable(tview.NewTable().SetSelectable(true, false))
+	ui.duplicates.SetMouseCapture(ui.doubleClickCapture(ui.duplicates, 0))
+	ui.pages.AddPage("duplicates", ui.duplicates, true, false)
 
-		// End user pressed `F3` anywhere.
-		case tcell.KeyF3:
-			// TODO: show help
+	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the
+	// duplicates page.
+	ui.duplicates.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			ui.showPage("listing")
+		}
+	})
 
-		case tcell.KeyRune:
+	ui.smart = NewItemTable(trackColumns, theme, ui.playing)
+	ui.smart.SetMouseCapture(ui.doubleClickCapture(ui.smart.Table, 1))
+	ui.pages.AddPage("smart", ui.smart, true, false)
 
-			switch ev.Rune() {
+	// End user pressed one of `Escape`, `Tab`, or `Backtab` on the smart
+	// list page.
+	ui.smart.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			ui.showPage("listing")
+		}
+	})
 
-			// End user pressed `?` anywhere.
-			case '?':
-				// TODO: show help
+	ui.details = NewDetailsPane(theme, ui.selectedReference, ui.followDetailsLink)
+	ui.body = tview.NewFlex().AddItem(ui.pages, 0, 2, true)
 
-			// End user pressed `q` anywhere.
-			case 'q':
-				app.Stop()
-				return nil
-			}
+	// The tab bar starts on whichever page is shown first.
+	first, _ := ui.pages.GetFrontPage()
+	ui.tabs.Visit(first)
+
+	_, bg, _ := theme.Status.Decompose()
+	ui.status = tview.NewTextView().SetTextStyle(theme.Status)
+	ui.status.SetBackgroundColor(bg)
+	seek := NewSeekBar(theme, func(position int) {
+		go func() {
+			tx <- RequestSeek(position)
+		}()
+	})
+	go StatusManager(ctx, ui.app, ui.status, seek, state)
+	go PlayingManager(ctx, ui.app, ui.playing, state)
+
+	ui.messages = tview.NewTextView()
+
+	ui.cmdline = NewCommandLine(ui.completeCommand)
+
+	// End user pressed one of `Escape`, `Enter`, `Tab`, or `Backtab` on the
+	// command line. Completion handles `Tab` and `Backtab` first.
+	ui.cmdline.SetDoneFunc(func(key tcell.Key) {
+		command := ui.cmdline.GetText()
+		ui.closeCommandLine()
+
+		if key != tcell.KeyEnter {
+			return
 		}
 
-		// Pass event back to application, will be routed to focused
-		// primitive.
-		return ev
+		ui.cmdline.Remember(strings.TrimSpace(command))
+		if err := ui.RunCommand(command); err != nil {
+			log.Error(err)
+			ui.message(err.Error())
+		}
 	})
 
+	ui.bar = tview.NewPages().
+		AddPage("message", ui.messages, true, false).
+		AddPage("command", ui.cmdline, true, false).
+		AddPage("status", tview.NewFlex().AddItem(ui.status, 0, 1, false).AddItem(seek, seekBarWidth, 0, false), true, true)
+
+	// Tell the end user where to find the HTTP API token, now that logs
+	// and messages are shown.
+	if cfg.HTTPPort != 0 {
+		source := filepath.Join(cfg.CacheDir, "http-token")
+		if cfg.HTTPToken != "" {
+			source = "-http-token"
+		}
+		log.Infof("serving HTTP API at http://localhost:%d (token from %s)", cfg.HTTPPort, source)
+		ui.message(fmt.Sprintf("HTTP API at http://localhost:%d (token from %s)", cfg.HTTPPort, source))
+	}
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(ui.tabs, 1, 0, false).
+		AddItem(ui.body, 0, 1, true).
+		AddItem(ui.bar, 1, 0, false)
+
+	ui.app.SetInputCapture(ui.inputCapture)
+
+	// End user pasted text into the terminal anywhere. Text pasted into a
+	// text field is typed instead.
+	root := &pasteRoot{
+		Flex: layout,
+		paste: func(text string) {
+			if field, typing := ui.app.GetFocus().(*tview.InputField); typing {
+				field.SetText(field.GetText() + text)
+				return
+			}
+			go playPasted(text, tx)
+		},
+	}
+
 	// This will block until the application dies.
 
-	err := app.SetRoot(pages, true).Run()
+	err = ui.app.SetRoot(root, true).EnablePaste(true).EnableMouse(true).Run()
 	cancel()
 
 	log.SetOutput(os.Stdout)
+	cfg.ConfigureLogging()
 	if err != nil {
 		log.WithError(err).Fatal("application died")
 	}
 
 	// TODO: for long-running programs, maybe need to periodically run cli.RefreshToken?
 }
-
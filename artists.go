@@ -0,0 +1,43 @@
+package main
+
+// Spotify artists interactions.
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+)
+
+// Fetch the top tracks of an artist in the end user's market. The tracks are
+// sent through the channel, and the channel is closed once there are no more
+// tracks or the context is cancelled.
+func FetchArtistTopTracks(ctx context.Context, cli *spotify.Client, artist *spotify.SimpleArtist, ch chan<- *Item) {
+	defer close(ch)
+
+	// Unlike `GetArtistsTopTracks`, the market is taken from the end
+	// user's account.
+	query := url.Values{}
+	query.Set("market", "from_token")
+
+	result := struct {
+		Tracks []spotify.FullTrack `json:"tracks"`
+	}{}
+
+	log.Tracef("fetching top tracks for %s...", artist.Name)
+	err := apiRequest(ctx, cli, http.MethodGet, "artists/"+artist.ID.String()+"/top-tracks", query, &result)
+	if err != nil {
+		log.WithError(err).Errorf("failed to fetch top tracks for %s", artist.Name)
+		return
+	}
+
+	for i := range result.Tracks {
+		select {
+		case ch <- &Item{Track: &result.Tracks[i]}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
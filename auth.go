@@ -6,7 +6,9 @@ import (
 	"context"
 	"net/http"
 	"fmt"
+	"strings"
 
+	"golang.org/x/oauth2"
 	"github.com/zmb3/spotify/v2"
 	auth "github.com/zmb3/spotify/v2/auth"
 	log "github.com/sirupsen/logrus"
@@ -19,40 +21,67 @@ var (
 	CLIENTSECRET = ""
 )
 
-// Pull the cache directory information from the context.
-func cache_info(ctx context.Context) string {
-	dir, ok := ctx.Value("cachedir").(string)
-	if !ok {
-		dir = ""
+// Scope for reading the resume points of episodes. Not defined by the client
+// library.
+const scopeUserReadPlaybackPosition = "user-read-playback-position"
+
+// Scopes requested when authenticating. A cached token that was granted fewer
+// scopes (e.g. by an older version) is discarded.
+var scopes = []string{
+	auth.ScopeUserLibraryRead,
+	auth.ScopeUserLibraryModify,
+	auth.ScopePlaylistReadPrivate,
+	auth.ScopePlaylistReadCollaborative,
+	auth.ScopePlaylistModifyPublic,
+	auth.ScopePlaylistModifyPrivate,
+	auth.ScopeUserReadPlaybackState,
+	auth.ScopeUserModifyPlaybackState,
+	auth.ScopeUserReadRecentlyPlayed,
+	scopeUserReadPlaybackPosition,
+}
+
+// Check if a granted scope (a space-separated list) covers every scope that
+// is requested.
+func scopesCovered(granted string) bool {
+	have := map[string]bool{}
+	for _, scope := range strings.Fields(granted) {
+		have[scope] = true
 	}
 
-	return dir
+	for _, scope := range scopes {
+		if !have[scope] {
+			return false
+		}
+	}
+	return true
 }
 
-// Pull the authentication URI information from the context.
-func uri_info(ctx context.Context) (string, string) {
-	port, ok := ctx.Value("authport").(int)
-	if !ok {
-		log.Warn("authenticating web server port is required; falling back to default :8080")
-		port = 8080
+// Get the scope granted with a token, or else the scopes requested.
+func grantedScope(tok *oauth2.Token) string {
+	if scope, ok := tok.Extra("scope").(string); ok && scope != "" {
+		return scope
 	}
+	return strings.Join(scopes, " ")
+}
 
-	full_uri := fmt.Sprintf("http://localhost:%d", port)
-	short_uri := fmt.Sprintf(":%d", port)
+// Format the authentication URI, both in full and as a listening address.
+func uri_info(cfg *Config) (string, string) {
+	full_uri := fmt.Sprintf("http://localhost:%d", cfg.AuthPort)
+	short_uri := fmt.Sprintf(":%d", cfg.AuthPort)
 
 	return full_uri, short_uri
 }
 
-// Serves the authenticator at `http://localhost:[authport]`. Prints that address
+// Serves the authenticator at `http://localhost:[AuthPort]`. Prints that address
 // and some instructions to STDOUT for the end user.
-func ServeAuthenticator(ctx context.Context, ch chan<- *spotify.Client) *http.Server {
-	full_uri, short_uri := uri_info(ctx)
+func ServeAuthenticator(ctx context.Context, cfg *Config, ch chan<- *spotify.Client) *http.Server {
+	full_uri, short_uri := uri_info(cfg)
 	state := "nspotify"
 	authenticator := auth.New(
 		auth.WithClientID(CLIENTID),
 		auth.WithClientSecret(CLIENTSECRET),
 		auth.WithRedirectURL(full_uri),
-		auth.WithScopes(auth.ScopeUserLibraryRead, auth.ScopeUserReadPlaybackState, auth.ScopeUserModifyPlaybackState))
+		auth.WithScopes(scopes...))
 	srv := &http.Server{Addr: short_uri}
 
 	// Address and instructions for end user.
@@ -74,9 +103,8 @@ func ServeAuthenticator(ctx context.Context, ch chan<- *spotify.Client) *http.Se
 
 		client := spotify.New(authenticator.Client(ctx, tok))
 
-		dir := cache_info(ctx)
-		if dir != "" {
-			WriteCache(dir, tok)
+		if cfg.CacheDir != "" {
+			WriteCache(cfg.CacheDir, tok, grantedScope(tok))
 		}
 
 		ch <- client
@@ -92,34 +120,40 @@ func ServeAuthenticator(ctx context.Context, ch chan<- *spotify.Client) *http.Se
 	return srv
 }
 
-// Authenticate with a cached token from `[cachedir]/token.json`.
-func CachedAuthentication(ctx context.Context) *spotify.Client {
-	dir := cache_info(ctx)
-	if dir == "" {
+// Authenticate with a cached token from `[CacheDir]/token.json`.
+func CachedAuthentication(ctx context.Context, cfg *Config) *spotify.Client {
+	if cfg.CacheDir == "" {
 		return nil
 	}
 
-	tok, err := ReadCache(dir)
+	tok, scope, err := ReadCache(cfg.CacheDir)
 	if err != nil {
 		return nil
 	}
 
-	authenticator := auth.New(auth.WithScopes(auth.ScopeUserLibraryRead))
+	// Without every scope, some requests would fail, so log in again.
+	if !scopesCovered(scope) {
+		log.Info("cached token lacks some permissions, logging in again")
+		DiscardCache(cfg.CacheDir)
+		return nil
+	}
+
+	authenticator := auth.New(auth.WithScopes(scopes...))
 
 	return spotify.New(authenticator.Client(ctx, tok))
 	
 }
 
 // Authenticates the end user.
-func Authenticate(ctx context.Context) *spotify.Client {
+func Authenticate(ctx context.Context, cfg *Config) *spotify.Client {
 	// Try cache first.
-	if cached := CachedAuthentication(ctx); cached != nil {
+	if cached := CachedAuthentication(ctx, cfg); cached != nil {
 		return cached
 	}
 
 	// Start server.
 	ch := make(chan *spotify.Client)
-	srv := ServeAuthenticator(ctx, ch)
+	srv := ServeAuthenticator(ctx, cfg, ch)
 
 	cli := <-ch
 
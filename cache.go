@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"encoding/json"
 
 	"golang.org/x/oauth2"
@@ -20,15 +22,22 @@ func default_cache_dir() string {
 	return filepath.Join(home, ".local", "nspotify")
 }
 
-// Try to cache an access token.
-func WriteCache(dir string, tok *oauth2.Token) error {
+// An access token as cached, with the scope it was granted. Tokens cached by
+// older versions have no scope.
+type cachedToken struct {
+	*oauth2.Token
+	Scope string `json:"scope,omitempty"`
+}
+
+// Try to cache an access token, with the scope it was granted.
+func WriteCache(dir string, tok *oauth2.Token, scope string) error {
 	err := os.Mkdir(dir, 0700)
 	if err != nil && !os.IsExist(err) {
 		log.WithError(err).Warnf("failed to make cache directory: %s", dir)
 		return err
 	}
 
-	data, err := json.Marshal(tok)
+	data, err := json.Marshal(&cachedToken{Token: tok, Scope: scope})
 	if err != nil {
 		log.WithError(err).Warn("failed to marshall token")
 		return err
@@ -45,16 +54,16 @@ func WriteCache(dir string, tok *oauth2.Token) error {
 	return nil
 }
 
-// Try to read a cache file for an access token.
-func ReadCache(dir string) (*oauth2.Token, error) {
-	tok := &oauth2.Token{}
+// Try to read a cache file for an access token, and the scope it was granted.
+func ReadCache(dir string) (*oauth2.Token, string, error) {
+	tok := &cachedToken{Token: &oauth2.Token{}}
 
 	full_path := filepath.Join(dir, "token.json")
 
 	data, err := os.ReadFile(full_path)
 	if err != nil {
 		log.WithError(err).Warnf("failed to read cache file: %s", full_path)
-		return nil, err
+		return nil, "", err
 	}
 
 	log.Debugf("found cache file: %s", full_path)
@@ -62,11 +71,48 @@ func ReadCache(dir string) (*oauth2.Token, error) {
 	err = json.Unmarshal(data, tok)
 	if err != nil {
 		log.WithError(err).Warn("failed to unmarshall token")
-		return nil, err
+		return nil, "", err
 	}
 
 	log.Debug("succeeded in reading cached token")
 
-	return tok, nil
+	return tok.Token, tok.Scope, nil
+}
+
+// Remove a cached access token, e.g. because it lacks some scopes.
+func DiscardCache(dir string) {
+	err := os.Remove(filepath.Join(dir, "token.json"))
+	if err != nil && !os.IsNotExist(err) {
+		log.WithError(err).Warn("failed to remove cache file")
+	}
 }
 
+// Get a token for the HTTP API from `[cachedir]/http-token`, creating one if
+// needed. The token is never printed; the interactive interface tells where
+// to find it.
+func CachedHTTPToken(dir string) (string, error) {
+	full_path := filepath.Join(dir, "http-token")
+
+	// The file may have been edited by hand, e.g. with a trailing newline.
+	data, err := os.ReadFile(full_path)
+	if token := strings.TrimSpace(string(data)); err == nil && token != "" {
+		log.Debugf("found HTTP API token file: %s", full_path)
+		return token, nil
+	}
+
+	token := GenerateToken()
+
+	err = os.Mkdir(dir, 0700)
+	if err != nil && !os.IsExist(err) {
+		return "", fmt.Errorf("failed to make cache directory: %w", err)
+	}
+
+	err = os.WriteFile(full_path, []byte(token), 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to write HTTP API token file: %w", err)
+	}
+
+	log.Debugf("wrote HTTP API token file: %s", full_path)
+
+	return token, nil
+}
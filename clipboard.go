@@ -0,0 +1,80 @@
+package main
+
+// System clipboard interactions.
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Commands that print the contents of the system clipboard, in order of
+// preference.
+var pasteCommands = [][]string{
+	{"wl-paste", "--no-newline"},
+	{"xclip", "-out", "-selection", "clipboard"},
+	{"xsel", "--output", "--clipboard"},
+	{"pbpaste"},
+}
+
+// Commands that replace the contents of the system clipboard with their
+// input, in order of preference.
+var copyCommands = [][]string{
+	{"wl-copy"},
+	{"xclip", "-in", "-selection", "clipboard"},
+	{"xsel", "--input", "--clipboard"},
+	{"pbcopy"},
+}
+
+// Read the contents of the system clipboard with the first available
+// command.
+func ReadClipboard() (string, error) {
+	for _, command := range pasteCommands {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+
+		out, err := exec.Command(command[0], command[1:]...).Output()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", command[0], err)
+		}
+
+		return string(out), nil
+	}
+
+	return "", fmt.Errorf("no clipboard command found")
+}
+
+// Replace the contents of the system clipboard with the first available
+// command.
+func WriteClipboard(text string) error {
+	for _, command := range copyCommands {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %w", command[0], err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("no clipboard command found")
+}
+
+// Format text as an OSC 52 escape sequence, which asks the terminal to set
+// its clipboard. Unlike the clipboard commands, this works over SSH. Inside
+// tmux, the sequence is passed through to the outer terminal.
+func osc52(text string) []byte {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if os.Getenv("TMUX") != "" {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+
+	return []byte(seq)
+}
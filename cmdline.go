@@ -0,0 +1,416 @@
+package main
+
+// Command line of the interactive interface, opened with `:`. Commands run
+// actions by name, e.g. `:volume 50` or `:next 3`.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Maximum number of commands to remember.
+const commandHistory = 100
+
+// Command line, with history and completion.
+type CommandLine struct {
+	*tview.InputField
+
+	// Previously run commands, oldest first.
+	history []string
+
+	// Position in the history while browsing it, and the command that was
+	// being typed before browsing.
+	position int
+	draft    string
+
+	// Candidates for the word being completed, and the text before it.
+	completions []string
+	completion  int
+	before      string
+
+	// Get the candidates for completing the last word of a command.
+	complete func(words []string) []string
+}
+
+// Create a command line.
+func NewCommandLine(complete func(words []string) []string) *CommandLine {
+	cmdline := &CommandLine{
+		InputField: tview.NewInputField().SetLabel(":"),
+		complete: complete,
+	}
+
+	cmdline.SetInputCapture(cmdline.inputCapture)
+	return cmdline
+}
+
+// Clear the command line, ready for a new command.
+func (cmdline *CommandLine) Reset() {
+	cmdline.SetText("")
+	cmdline.position = len(cmdline.history)
+	cmdline.draft = ""
+	cmdline.completions = nil
+}
+
+// Remember a command.
+func (cmdline *CommandLine) Remember(command string) {
+	n := len(cmdline.history)
+	if command == "" || (n != 0 && cmdline.history[n-1] == command) {
+		return
+	}
+
+	cmdline.history = append(cmdline.history, command)
+	if n+1 > commandHistory {
+		cmdline.history = cmdline.history[1:]
+	}
+}
+
+// Browse the history.
+func (cmdline *CommandLine) browse(delta int) {
+	position := cmdline.position + delta
+	if position < 0 || position > len(cmdline.history) {
+		return
+	}
+
+	if cmdline.position == len(cmdline.history) {
+		cmdline.draft = cmdline.GetText()
+	}
+	cmdline.position = position
+
+	if position == len(cmdline.history) {
+		cmdline.SetText(cmdline.draft)
+	} else {
+		cmdline.SetText(cmdline.history[position])
+	}
+}
+
+// Complete the last word, cycling through the candidates on repeated
+// completions.
+func (cmdline *CommandLine) cycle(delta int) {
+	if cmdline.completions == nil {
+		text := cmdline.GetText()
+		words := strings.Fields(text)
+		if len(words) == 0 || strings.HasSuffix(text, " ") {
+			words = append(words, "")
+		}
+
+		word := words[len(words)-1]
+		prefix := strings.ToLower(word)
+		cmdline.before = text[:len(text)-len(word)]
+
+		candidates := []string{}
+		for _, candidate := range cmdline.complete(words) {
+			if strings.HasPrefix(strings.ToLower(candidate), prefix) {
+				candidates = append(candidates, candidate)
+			}
+		}
+		if len(candidates) == 0 {
+			return
+		}
+
+		// A single candidate is completed, ready for the next word.
+		if len(candidates) == 1 {
+			cmdline.SetText(cmdline.before + candidates[0] + " ")
+			return
+		}
+
+		cmdline.completions = candidates
+		cmdline.completion = -1
+		if delta < 0 {
+			cmdline.completion = 0
+		}
+	}
+
+	n := len(cmdline.completions)
+	cmdline.completion = (cmdline.completion + delta + n) % n
+	cmdline.SetText(cmdline.before + cmdline.completions[cmdline.completion])
+}
+
+// Handle history and completion keys.
+func (cmdline *CommandLine) inputCapture(ev *tcell.EventKey) *tcell.EventKey {
+	switch ev.Key() {
+	case tcell.KeyTab:
+		cmdline.cycle(1)
+		return nil
+
+	case tcell.KeyBacktab:
+		cmdline.cycle(-1)
+		return nil
+
+	case tcell.KeyUp:
+		cmdline.completions = nil
+		cmdline.browse(-1)
+		return nil
+
+	case tcell.KeyDown:
+		cmdline.completions = nil
+		cmdline.browse(1)
+		return nil
+	}
+
+	cmdline.completions = nil
+	return ev
+}
+
+// Get the candidates for completing the last word of a command: names of
+// actions for the first word, or else the action's own candidates.
+func (ui *UI) completeCommand(words []string) []string {
+	if len(words) == 1 {
+		names := []string{}
+		for name := range actionsByName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	action, ok := LookupAction(words[0])
+	if !ok || action.Complete == nil {
+		return nil
+	}
+	return action.Complete(ui)
+}
+
+// Open the command line.
+func (ui *UI) openCommandLine() {
+	ui.cmdline.Reset()
+	ui.bar.SwitchToPage("command")
+	ui.app.SetFocus(ui.cmdline)
+
+	go ui.fetchDevices()
+}
+
+// Close the command line, returning focus to the page.
+func (ui *UI) closeCommandLine() {
+	ui.bar.SwitchToPage("status")
+	ui.app.SetFocus(ui.pages)
+}
+
+// Show a message in place of the status bar, until the next key is pressed.
+func (ui *UI) message(text string) {
+	ui.messages.SetText(text)
+	ui.bar.SwitchToPage("message")
+}
+
+// Run a command, e.g. `next 3`.
+func (ui *UI) RunCommand(command string) error {
+	words := strings.Fields(strings.TrimPrefix(strings.TrimSpace(command), ":"))
+	if len(words) == 0 {
+		return nil
+	}
+
+	action, ok := LookupAction(words[0])
+	if !ok {
+		return fmt.Errorf("unknown command: %s", words[0])
+	}
+	args := words[1:]
+
+	if action.Command != nil {
+		return action.Command(ui, args)
+	}
+
+	count := 1
+	if len(args) != 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || len(args) != 1 {
+			return fmt.Errorf("%s: expected a count, not: %s", action.Name, strings.Join(args, " "))
+		}
+		count = n
+	}
+
+	return ui.runAction(action.Name, count)
+}
+
+// Parse URIs (or links) from arguments.
+func parseURIArgs(args []string) ([]*URI, error) {
+	uris, err := ParseURIs(strings.Join(args, " "))
+	if err != nil {
+		return nil, err
+	}
+	if len(uris) == 0 {
+		return nil, fmt.Errorf("missing URI")
+	}
+
+	return uris, nil
+}
+
+// Play some URIs (or links). The first is played and any others are queued.
+func (ui *UI) playURIs(args []string) error {
+	uris, err := parseURIArgs(args)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for _, ev := range RequestPlayURIs(uris) {
+			ui.tx <- ev
+		}
+	}()
+	return nil
+}
+
+// Queue some URIs (or links).
+func (ui *UI) queueURIs(args []string) error {
+	uris, err := parseURIArgs(args)
+	if err != nil {
+		return err
+	}
+
+	for _, uri := range uris {
+		if !uri.IsPlayable() {
+			return fmt.Errorf("cannot queue a %s: %s", uri.Type, uri)
+		}
+	}
+
+	go func() {
+		for _, uri := range uris {
+			ui.tx <- RequestQueueURI(uri.Spotify())
+		}
+	}()
+	return nil
+}
+
+// Set the volume, e.g. `50`, or change it, e.g. `+10`.
+func (ui *UI) setVolume(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("volume: expected a percent")
+	}
+
+	percent, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("volume: invalid percent: %s", args[0])
+	}
+
+	if strings.HasPrefix(args[0], "+") || strings.HasPrefix(args[0], "-") {
+		percent += int(ui.state.Latest().Device.Volume)
+	}
+	percent = max(0, min(100, percent))
+
+	ui.tx <- RequestVolume(percent)
+	return nil
+}
+
+// Fetch the end user's devices, for completion.
+func (ui *UI) fetchDevices() {
+	devices, err := ui.cli.PlayerDevices(ui.ctx)
+	if err != nil {
+		log.WithError(err).Error("failed to fetch devices")
+		return
+	}
+
+	names := []string{}
+	for _, dev := range devices {
+		names = append(names, dev.Name)
+	}
+
+	ui.app.QueueUpdate(func() {
+		ui.deviceNames = names
+	})
+}
+
+// Find a device by ID or name, ignoring case. A name can be abbreviated if it
+// is unambiguous.
+func findDevice(devices []spotify.PlayerDevice, name string) (spotify.ID, error) {
+	matches := []spotify.PlayerDevice{}
+	for _, dev := range devices {
+		if dev.ID.String() == name || strings.EqualFold(dev.Name, name) {
+			return dev.ID, nil
+		}
+		if strings.HasPrefix(strings.ToLower(dev.Name), strings.ToLower(name)) {
+			matches = append(matches, dev)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no device: %s", name)
+	case 1:
+		return matches[0].ID, nil
+	}
+	return "", fmt.Errorf("ambiguous device: %s", name)
+}
+
+// Move playback to a device, by ID or name.
+func (ui *UI) transferPlayback(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("device: expected a name")
+	}
+	name := strings.Join(args, " ")
+
+	// Fetching devices takes a request, so do not block the application.
+	go func() {
+		devices, err := ui.cli.PlayerDevices(ui.ctx)
+		if err != nil {
+			log.WithError(err).Error("failed to fetch devices")
+			return
+		}
+
+		dev, err := findDevice(devices, name)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		ui.tx <- RequestTransfer(dev)
+	}()
+	return nil
+}
+
+// Sort the items on the current page, e.g. `artist` or `added desc`.
+func (ui *UI) sortItems(args []string) error {
+	table, ok := ui.currentItems()
+	if !ok {
+		return fmt.Errorf("sort: nothing to sort on this page")
+	}
+
+	order, desc := "loaded", false
+	switch len(args) {
+	case 0:
+	case 2:
+		if args[1] != "desc" && args[1] != "asc" {
+			return fmt.Errorf("sort: expected asc or desc, not: %s", args[1])
+		}
+		desc = args[1] == "desc"
+		fallthrough
+	case 1:
+		order = args[0]
+	default:
+		return fmt.Errorf("sort: too many arguments")
+	}
+
+	return table.Sort(order, desc)
+}
+
+// Filter the items on the current page.
+func (ui *UI) filterItems(args []string) error {
+	table, ok := ui.currentItems()
+	if !ok {
+		return fmt.Errorf("filter: nothing to filter on this page")
+	}
+
+	table.Filter(strings.Join(args, " "))
+	return nil
+}
+
+// Group the items on the current page, e.g. `artist`. Without an argument,
+// switch to the next grouping.
+func (ui *UI) groupItems(args []string) error {
+	table, ok := ui.currentItems()
+	if !ok {
+		return fmt.Errorf("view: nothing to group on this page")
+	}
+
+	switch len(args) {
+	case 0:
+		return table.Group(nextGrouping(table.Grouping()))
+	case 1:
+		return table.Group(args[0])
+	}
+	return fmt.Errorf("view: too many arguments")
+}
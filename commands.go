@@ -0,0 +1,480 @@
+package main
+
+// Non-interactive subcommands, e.g. `nspotify play [URI]`. Each runs against
+// the configured device (or the active device, if none is configured) and then
+// exits.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Exit codes for subcommands.
+const (
+	// Subcommand succeeded.
+	exitSuccess = 0
+
+	// A request to Spotify failed.
+	exitFailure = 1
+
+	// Subcommand was misused, e.g. given an invalid URI.
+	exitUsage = 2
+
+	// The `status` subcommand found that nothing is playing.
+	exitNotPlaying = 3
+)
+
+// Error returned by the `status` subcommand if nothing is playing.
+var errNotPlaying = errors.New("not playing")
+
+// Error for a misused subcommand.
+type usageError struct {
+	msg string
+}
+
+func (err *usageError) Error() string {
+	return err.msg
+}
+
+// Specification of a subcommand.
+type commandSpec struct {
+	// Arguments, for the usage message.
+	args string
+
+	// Description, for the usage message.
+	desc string
+
+	// Range of acceptable argument counts. A negative maximum means any
+	// number of arguments.
+	min int
+	max int
+
+	// Validates arguments before authenticating. Optional.
+	check func(args []string) error
+
+	// Runs without authenticating, i.e. `cli` is nil.
+	local bool
+
+	run func(ctx context.Context, cli *spotify.Client, cfg *Config, args []string) error
+}
+
+// Available subcommands.
+var commands = map[string]commandSpec{
+	"play": {
+		args: "[URI...]",
+		desc: "Play URIs or links (read from STDIN if piped), or resume playback",
+		min: 0, max: -1,
+		check: checkURIs,
+		run: runPlay,
+	},
+	"pause": {
+		desc: "Pause playback",
+		run: runEvent(RequestPause),
+	},
+	"toggle": {
+		desc: "Toggle playback",
+		run: runEvent(RequestToggle),
+	},
+	"next": {
+		desc: "Play the next item",
+		run: runEvent(RequestPlayNext),
+	},
+	"prev": {
+		desc: "Play the previous item",
+		run: runEvent(RequestPlayPrevious),
+	},
+	"queue": {
+		args: "URI...",
+		desc: "Enqueue tracks or episodes",
+		min: 1, max: -1,
+		check: checkPlayableURIs,
+		run: runQueue,
+	},
+	"status": {
+		desc: "Report what is playing; exits 3 if nothing is playing",
+		run: runStatus,
+	},
+	"devices": {
+		desc: "List available devices",
+		run: runDevices,
+	},
+	"ctl": {
+		args: "COMMAND [ARG]",
+		desc: "Control a running instance: play [URI], pause, toggle, next, prev, queue URI, seek POSITION, volume PERCENT, or status",
+		min: 1, max: 2,
+		check: checkControlArgs,
+		local: true,
+		run: runControl,
+	},
+	"search": {
+		args: "QUERY...",
+		desc: "Search for items, reporting one URI and description per line",
+		min: 1, max: -1,
+		run: runSearch,
+	},
+	"snapshot": {
+		desc: "Save a snapshot of the saved tracks, reporting its path",
+		run: runSnapshot,
+	},
+	"diff": {
+		args: "[OLD [NEW]]",
+		desc: "Compare two snapshots, or a snapshot (by default the latest) with the saved tracks",
+		min: 0, max: 2,
+		run: runDiff,
+	},
+	"history": {
+		args: "[SINCE [UNTIL]]",
+		desc: "List plays from the play history, e.g. since 2024-01-31, today, or 7d",
+		min: 0, max: 2,
+		check: checkHistoryRange,
+		run: runHistory,
+	},
+	"stats": {
+		args: "[SINCE [UNTIL]]",
+		desc: "Report listening statistics of the play history and the saved tracks, e.g. since 2024-01-01 or 30d",
+		min: 0, max: 2,
+		check: checkHistoryRange,
+		run: runStats,
+	},
+}
+
+// A parsed subcommand.
+type Command struct {
+	Name string
+	Args []string
+	spec commandSpec
+}
+
+// Parse and validate a subcommand from the command line arguments.
+func ParseCommand(args []string) (*Command, error) {
+	spec, ok := commands[args[0]]
+	if !ok {
+		return nil, &usageError{fmt.Sprintf("unknown command: %s", args[0])}
+	}
+
+	cmd := &Command{Name: args[0], Args: args[1:], spec: spec}
+
+	if len(cmd.Args) < spec.min || (spec.max >= 0 && len(cmd.Args) > spec.max) {
+		return nil, &usageError{fmt.Sprintf("usage: nspotify %s %s", cmd.Name, spec.args)}
+	}
+
+	if spec.check != nil {
+		if err := spec.check(cmd.Args); err != nil {
+			return nil, &usageError{err.Error()}
+		}
+	}
+
+	return cmd, nil
+}
+
+// Check if a subcommand runs without authenticating.
+func (cmd *Command) Local() bool {
+	return cmd.spec.local
+}
+
+// Run a subcommand and return its exit code.
+func (cmd *Command) Run(ctx context.Context, cli *spotify.Client, cfg *Config) int {
+	err := cmd.spec.run(ctx, cli, cfg, cmd.Args)
+	return ExitCode(err)
+}
+
+// Report an error (if any) from a subcommand and convert it to an exit code.
+func ExitCode(err error) int {
+	if err == nil {
+		return exitSuccess
+	}
+	if errors.Is(err, errNotPlaying) {
+		return exitNotPlaying
+	}
+
+	fmt.Fprintln(os.Stderr, "nspotify:", err)
+
+	var usage *usageError
+	if errors.As(err, &usage) {
+		return exitUsage
+	}
+	return exitFailure
+}
+
+// Print the available subcommands.
+func PrintCommands(w io.Writer) {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		spec := commands[name]
+		fmt.Fprintf(w, "  %-20s %s\n", strings.TrimSpace(name+" "+spec.args), spec.desc)
+	}
+}
+
+// Check if STDIN is piped (or redirected) rather than a terminal.
+func PipedStdin() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// Check that arguments are valid URIs or links.
+func checkURIs(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	_, err := ParseURIs(strings.Join(args, " "))
+	return err
+}
+
+// Check that arguments are valid URIs or links of tracks or episodes.
+func checkPlayableURIs(args []string) error {
+	uris, err := ParseURIs(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	for _, uri := range uris {
+		if !uri.IsPlayable() {
+			return fmt.Errorf("cannot queue a URI of type %s", uri.Type)
+		}
+	}
+
+	return nil
+}
+
+// Check the arguments of the `ctl` subcommand.
+func checkControlArgs(args []string) error {
+	_, err := ParseControlArgs(args)
+	return err
+}
+
+// Handle events in order, stopping at the first failure.
+func handleEvents(ctx context.Context, cli *spotify.Client, dev spotify.ID, evs ...*Event) error {
+	for _, ev := range evs {
+		if err := HandleEvent(ctx, cli, dev, ev); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Create a subcommand that handles a single event.
+func runEvent(request func() *Event) func(context.Context, *spotify.Client, *Config, []string) error {
+	return func(ctx context.Context, cli *spotify.Client, cfg *Config, _ []string) error {
+		return handleEvents(ctx, cli, cfg.Device, request())
+	}
+}
+
+// Subcommand `play [URI...]`.
+func runPlay(ctx context.Context, cli *spotify.Client, cfg *Config, args []string) error {
+	text := strings.Join(args, " ")
+
+	if len(args) == 0 {
+		if !PipedStdin() {
+			return handleEvents(ctx, cli, cfg.Device, RequestPlay())
+		}
+
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read STDIN: %w", err)
+		}
+		text = string(data)
+	}
+
+	uris, err := ParseURIs(text)
+	if err != nil {
+		return &usageError{err.Error()}
+	}
+
+	return handleEvents(ctx, cli, cfg.Device, RequestPlayURIs(uris)...)
+}
+
+// Subcommand `queue URI...`.
+func runQueue(ctx context.Context, cli *spotify.Client, cfg *Config, args []string) error {
+	uris, err := ParseURIs(strings.Join(args, " "))
+	if err != nil {
+		return &usageError{err.Error()}
+	}
+
+	evs := []*Event{}
+	for _, uri := range uris {
+		evs = append(evs, RequestQueueURI(uri.Spotify()))
+	}
+
+	return handleEvents(ctx, cli, cfg.Device, evs...)
+}
+
+// Subcommand `status`.
+func runStatus(ctx context.Context, cli *spotify.Client, _ *Config, _ []string) error {
+	np, err := FetchNowPlaying(ctx, cli)
+	if err != nil {
+		return fmt.Errorf("failed to fetch player state: %w", err)
+	}
+
+	fmt.Println(FormatNowPlaying(np))
+
+	if !np.Playing {
+		return errNotPlaying
+	}
+
+	return nil
+}
+
+// Subcommand `devices`.
+func runDevices(ctx context.Context, cli *spotify.Client, _ *Config, _ []string) error {
+	return ListDevices(ctx, cli)
+}
+
+// Subcommand `search QUERY...`.
+func runSearch(ctx context.Context, cli *spotify.Client, _ *Config, args []string) error {
+	results, err := Search(ctx, cli, strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		fmt.Printf("%s\t%s\n", result.URI, result.Description)
+	}
+
+	return nil
+}
+
+// Subcommand `ctl COMMAND [ARG]`.
+func runControl(_ context.Context, _ *spotify.Client, cfg *Config, args []string) error {
+	req, err := ParseControlArgs(args)
+	if err != nil {
+		return &usageError{err.Error()}
+	}
+
+	if cfg.Socket == "" {
+		return &usageError{"control socket is disabled"}
+	}
+
+	resp, err := SendControlRequest(cfg.Socket, req)
+	if err != nil {
+		return err
+	}
+	if !resp.OK {
+		return errors.New(resp.Error)
+	}
+
+	if resp.Status != nil {
+		fmt.Println(resp.Status)
+		if !resp.Status.Playing {
+			return errNotPlaying
+		}
+	}
+
+	return nil
+}
+
+// Subcommand `snapshot`.
+func runSnapshot(ctx context.Context, cli *spotify.Client, cfg *Config, _ []string) error {
+	if cfg.SnapshotDir == "" {
+		return &usageError{errSnapshotsDisabled.Error()}
+	}
+
+	snapshot, err := FetchSnapshot(ctx, cli)
+	if err != nil {
+		return err
+	}
+
+	path, err := SaveSnapshot(cfg.SnapshotDir, snapshot)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(path)
+	return nil
+}
+
+// Subcommand `diff [OLD [NEW]]`.
+func runDiff(ctx context.Context, cli *spotify.Client, cfg *Config, args []string) error {
+	diff, err := LoadDiff(ctx, cli, cfg.SnapshotDir, args)
+	if err != nil {
+		return err
+	}
+
+	if cfg.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+	return WriteDiff(os.Stdout, diff)
+}
+
+// Check the arguments of subcommand `history`.
+func checkHistoryRange(args []string) error {
+	_, err := ParseHistoryRange(args, time.Now())
+	return err
+}
+
+// Subcommand `history [SINCE [UNTIL]]`. Plays missed while nspotify was not
+// running are filled in first.
+func runHistory(ctx context.Context, cli *spotify.Client, cfg *Config, args []string) error {
+	if cfg.History == "" {
+		return &usageError{"play history is disabled"}
+	}
+
+	if _, err := BackfillHistory(ctx, cli, cfg.History); err != nil {
+		fmt.Fprintln(os.Stderr, "nspotify:", err)
+	}
+
+	r, err := ParseHistoryRange(args, time.Now())
+	if err != nil {
+		return err
+	}
+	entries, err := ReadHistory(cfg.History)
+	if err != nil {
+		return err
+	}
+	entries = FilterHistory(entries, r)
+
+	if cfg.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	return WriteHistory(os.Stdout, entries)
+}
+
+// Subcommand `stats [SINCE [UNTIL]]`. Plays missed while nspotify was not
+// running are filled in first.
+func runStats(ctx context.Context, cli *spotify.Client, cfg *Config, args []string) error {
+	if cfg.History == "" {
+		return &usageError{"play history is disabled"}
+	}
+
+	if _, err := BackfillHistory(ctx, cli, cfg.History); err != nil {
+		fmt.Fprintln(os.Stderr, "nspotify:", err)
+	}
+
+	library, err := FetchLibrary(ctx, cli)
+	if err != nil {
+		return err
+	}
+
+	stats, err := LoadStats(ctx, cli, cfg.History, args, library)
+	if err != nil {
+		return err
+	}
+
+	if cfg.JSON {
+		return WriteStatsJSON(os.Stdout, stats)
+	}
+	return WriteStats(os.Stdout, stats)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		args  []string
+		valid bool
+	}{
+		{[]string{"play"}, true},
+		{[]string{"play", "spotify:track:6rqhFgbbKwnb9MLmUQDhG6", "spotify:album:4aawyAB9vmqN3uQ7FjRGTy"}, true},
+		{[]string{"pause"}, true},
+		{[]string{"queue", "spotify:track:6rqhFgbbKwnb9MLmUQDhG6"}, true},
+		{[]string{"search", "some", "song"}, true},
+		{[]string{"diff", "old.json", "new.json"}, true},
+		{[]string{"history", "7d"}, true},
+		{[]string{"stats", "2024-01-01", "today"}, true},
+
+		// Invalid
+		{[]string{"rewind"}, false},
+		{[]string{"play", "not-a-uri"}, false},
+		{[]string{"pause", "now"}, false},
+		{[]string{"queue"}, false},
+		{[]string{"queue", "spotify:album:4aawyAB9vmqN3uQ7FjRGTy"}, false},
+		{[]string{"search"}, false},
+		{[]string{"diff", "a.json", "b.json", "c.json"}, false},
+		{[]string{"history", "7x"}, false},
+		{[]string{"stats", "today", "yesterday", "7d"}, false},
+	}
+
+	for _, tt := range tests {
+		cmd, err := ParseCommand(tt.args)
+		if !tt.valid {
+			var usage *usageError
+			if !errors.As(err, &usage) {
+				t.Errorf("ParseCommand(%q) = %v, %v, expected a usage error", tt.args, cmd, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseCommand(%q) failed: %v", tt.args, err)
+		} else if cmd.Name != tt.args[0] || len(cmd.Args) != len(tt.args)-1 {
+			t.Errorf("ParseCommand(%q) = %s %q", tt.args, cmd.Name, cmd.Args)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitSuccess},
+		{errors.New("request failed"), exitFailure},
+		{&usageError{"usage: nspotify queue URI..."}, exitUsage},
+		{fmt.Errorf("queue: %w", &usageError{"invalid URI"}), exitUsage},
+		{errNotPlaying, exitNotPlaying},
+	}
+
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("ExitCode(%v) = %d, expected %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
-	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"path/filepath"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
 )
 
 // Compile-time configurations.
@@ -24,6 +27,12 @@ const (
 	// Number of seconds to wait before rechecking how many trackers are loaded
 	// ahead of the cursor.
 	loadTimeout = 3
+
+	// Number of results to fetch per type when searching.
+	searchLimit = 10
+
+	// Number of seconds to wait before polling the player state again.
+	stateTimeout = 2
 )
 
 // TODO: Compile-time variables.
@@ -44,78 +53,326 @@ var (
 	cache = flag.String("cache", "", "Cache `directory`")
 	no_cache = flag.Bool("no-cache", false, "Do not use cached authentication, do not cache authentication")
 	device = flag.String("device", "", "Spotify device `ID`")
+	socket = flag.String("socket", "", "Control socket `path`")
+	no_socket = flag.Bool("no-socket", false, "Do not listen on a control socket")
+	no_mpris = flag.Bool("no-mpris", false, "Do not expose an MPRIS interface on the D-Bus session bus")
+	http_port = flag.Int("http-port", 0, "Serve the HTTP API on localhost at `port` (0 to disable)")
+	http_token = flag.String("http-token", "", "Require `token` for the HTTP API (random if not set)")
+	theme = flag.String("theme", defaultTheme, "Color `theme` [dark|light|monochrome|...]")
+	keymap = flag.String("keymap", "default", "Key bindings `preset` [default|vim|emacs]")
 	list_devices = flag.Bool("list-devices", false, "List available Spotify devices and exit")
+	export = flag.String("export", "", "Export the saved tracks in `format` [csv|jsonl|m3u|xspf] and exit")
+	output = flag.String("o", "-", "Export to `file`")
+	playlist = flag.String("playlist", "", "Export a playlist (`URI`, link, or ID) instead of the saved tracks")
+	import_file = flag.String("import", "", "Import tracks from a CSV, M3U, or XSPF `file`, or a list of URIs, and exit")
+	snapshots = flag.String("snapshots", "", "Library snapshot `directory`")
+	history = flag.String("history", "", "Play history `file`")
+	no_history = flag.Bool("no-history", false, "Do not record play history")
+	json_output = flag.Bool("json", false, "Report in JSON (for the diff, history, and stats subcommands)")
+	import_to = flag.String("import-to", "", "Save imported tracks to `target` [library|playlist:NAME] (without this, a dry run)")
+	config = flag.String("config", default_config_path(), "Configuration `file`")
+	print_config = flag.Bool("print-config", false, "Print the effective configuration and exit")
 	// TODO: version = flag.Bool("version", false, "List version and exit")
 )
 
-// Create a pointer to a value, especially a string.
-// Credit to `https://stackoverflow.com/a/30716481`:
-func Pointer[T any](v T) *T {
-	return &v
+// Print usage, including subcommands.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Usage: nspotify [options] [command [args]]")
+	fmt.Fprintln(out, "\nWithout a command, runs the interactive interface.")
+	fmt.Fprintln(out, "\nCommands:")
+	PrintCommands(out)
+	fmt.Fprintln(out, "\nOptions:")
+	flag.PrintDefaults()
 }
 
-// Create a context with configurations applied.
-func ConfiguredContext() context.Context {
-	ctx := context.Background()
+// Validated run-time configuration. Built once from the flags (and the
+// configuration file and environment) by `LoadConfig`.
+type Config struct {
+	LogLevel log.Level
+	Color    bool
 
-	flag.Parse()
+	// Port of the Spotify authenticator.
+	AuthPort int
+
+	// Cache directory, or empty if caching is disabled.
+	CacheDir string
+
+	// Device to control. If empty, the interactive interface lists
+	// devices instead, and subcommands control the active device.
+	Device spotify.ID
+
+	// Control socket path, or empty if the control socket is disabled.
+	Socket string
+
+	// If the MPRIS interface is exposed.
+	MPRIS bool
+
+	// Port of the HTTP API, or 0 if the HTTP API is disabled.
+	HTTPPort int
+
+	// Token required by the HTTP API, or empty to use the token in the
+	// cache directory.
+	HTTPToken string
+
+	// Key bindings preset, and bindings from the configuration file that
+	// override it.
+	Keymap string
+	Keys   map[string]string
+
+	// Theme, and themes from the configuration file.
+	Theme  string
+	Themes map[string]map[string]string
+
+	// Rules of smart lists from the configuration file, by name.
+	Lists map[string]map[string]string
+
+	// Export format, or empty if not exporting. Exports go to a file (or
+	// STDOUT if `-`), from a playlist if set or else the saved tracks.
+	Export         string
+	ExportFile     string
+	ExportPlaylist spotify.ID
+
+	// File to import, or empty if not importing. Imports are saved to a
+	// target (`library` or `playlist:NAME`), or else only reported.
+	Import   string
+	ImportTo string
+
+	// Directory of library snapshots, or empty if snapshots are disabled.
+	SnapshotDir string
+
+	// File of play history, or empty if play history is not recorded.
+	History string
+
+	// If subcommands report in JSON rather than text.
+	JSON bool
+
+	// If the configuration is printed (see `PrintConfig`) instead of
+	// running.
+	PrintConfig bool
+}
+
+// Parse a logging level.
+func parseLogLevel(level string) (log.Level, error) {
+	switch level {
+	case "trace":
+		return log.TraceLevel, nil
+	case "debug":
+		return log.DebugLevel, nil
+	case "info":
+		return log.InfoLevel, nil
+	case "warn", "warning":
+		return log.WarnLevel, nil
+	case "error":
+		return log.ErrorLevel, nil
+	case "fatal":
+		return log.FatalLevel, nil
+	case "panic":
+		return log.PanicLevel, nil
+	}
+
+	return log.ErrorLevel, fmt.Errorf("invalid log level: %s", level)
+}
+
+// Check that a port is valid.
+func validPort(port int) bool {
+	return 0 < port && port < 65536
+}
+
+// Check that a configuration is valid.
+func (cfg *Config) Validate() error {
+	var errs []error
+
+	if !validPort(cfg.AuthPort) {
+		errs = append(errs, fmt.Errorf("invalid authenticator port: %d", cfg.AuthPort))
+	}
+
+	if cfg.Device != "" && !validID(cfg.Device.String()) {
+		errs = append(errs, fmt.Errorf("invalid device: %s", cfg.Device))
+	}
+
+	if cfg.HTTPPort != 0 {
+		if !validPort(cfg.HTTPPort) {
+			errs = append(errs, fmt.Errorf("invalid HTTP API port: %d", cfg.HTTPPort))
+		} else if cfg.HTTPPort == cfg.AuthPort {
+			errs = append(errs, fmt.Errorf("HTTP API port conflicts with authenticator port: %d", cfg.HTTPPort))
+		}
+
+		// Without the cache, a random token could never be found.
+		if cfg.HTTPToken == "" && cfg.CacheDir == "" {
+			errs = append(errs, fmt.Errorf("HTTP API needs -http-token when caching is disabled"))
+		}
+	}
+
+	if cfg.Export != "" {
+		if err := checkExportFormat(cfg.Export); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if cfg.Import != "" && cfg.Export != "" {
+		errs = append(errs, fmt.Errorf("cannot both import and export"))
+	}
+
+	if cfg.ImportTo != "" {
+		if cfg.Import == "" {
+			errs = append(errs, fmt.Errorf("nothing to import to %s", cfg.ImportTo))
+		} else if err := checkImportTarget(cfg.ImportTo); err != nil {
+			errs = append(errs, err)
+		}
+	}
 
+	if _, err := cfg.NewKeymap(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := NewTheme(cfg.Theme, cfg.Themes); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := ParseSmartLists(cfg.Lists); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Create the configured keymap.
+func (cfg *Config) NewKeymap() (*Keymap, error) {
+	return NewKeymap(cfg.Keymap, cfg.Keys, KnownAction)
+}
+
+// Create the configured theme. Without color, the theme is always
+// monochrome.
+func (cfg *Config) NewTheme() (*Theme, error) {
+	if !cfg.Color {
+		return NewTheme("monochrome", nil)
+	}
+	return NewTheme(cfg.Theme, cfg.Themes)
+}
+
+// Apply the logging configuration.
+func (cfg *Config) ConfigureLogging() {
 	// If `-color` or `-color=true:
-	if *color {
+	if cfg.Color {
 		log.SetFormatter(&log.TextFormatter{ForceColors: true, FullTimestamp: false})
 	} else {
 		log.SetFormatter(&log.TextFormatter{DisableColors: true, FullTimestamp: false})
 	}
 
+	log.SetLevel(cfg.LogLevel)
+}
+
+// Build and validate the configuration from the flags, configuration file,
+// and environment.
+func LoadConfig() (*Config, error) {
+	flag.Usage = usage
+	flag.Parse()
+
+	tables, err := applyConfigLayers(*config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in defaults that depend on the environment.
+	if *cache == "" {
+		flag.Set("cache", default_cache_dir())
+	}
+	if *socket == "" {
+		flag.Set("socket", default_socket_path())
+	}
+
+	cfg := &Config{
+		Color: *color,
+		AuthPort: *port,
+		CacheDir: *cache,
+		Device: spotify.ID(*device),
+		Socket: *socket,
+		MPRIS: !*no_mpris,
+		HTTPPort: *http_port,
+		HTTPToken: *http_token,
+		Keymap: *keymap,
+		Keys: tables.Keys,
+		Theme: *theme,
+		Themes: tables.Themes,
+		Lists: tables.Lists,
+		Export: *export,
+		ExportFile: *output,
+		Import: *import_file,
+		ImportTo: *import_to,
+		SnapshotDir: *snapshots,
+		History: *history,
+		JSON: *json_output,
+		PrintConfig: *print_config,
+	}
+
 	// Prioritize explicit `-log-level`, then `-quiet`, then `-verbose`.
-	if *log_level == "" {
+	level := *log_level
+	if level == "" {
+		level = "error"
 		if *verbose {
-			log_level = Pointer("trace")
+			level = "trace"
 		}
 		if *quiet {
-			log_level = Pointer("panic")
+			level = "panic"
 		}
 	}
-	switch *log_level {
-	case "trace":
-		log.SetLevel(log.TraceLevel)
-	case "debug":
-		log.SetLevel(log.DebugLevel)
-	case "info":
-		log.SetLevel(log.InfoLevel)
-	case "warn":
-		log.SetLevel(log.WarnLevel)
-	case "error":
-		log.SetLevel(log.ErrorLevel)
-	case "fatal":
-		log.SetLevel(log.FatalLevel)
-	case "panic":
-		log.SetLevel(log.PanicLevel)
-	default:
-		log.SetLevel(log.ErrorLevel)
-	}
 
-	ctx = context.WithValue(ctx, "authport", *port)
+	var errs []error
 
-	if *cache == "" {
-		cache = Pointer(default_cache_dir())
+	cfg.LogLevel, err = parseLogLevel(level)
+	if err != nil {
+		errs = append(errs, err)
 	}
 
-	// Signal `-no-cache` by forcing `-cache=''`.
+	// Signal `-no-cache` by forcing an empty cache directory.
 	if *no_cache {
-		cache = Pointer("")
+		cfg.CacheDir = ""
+	}
+
+	// Snapshots and play history are kept in the cache directory unless
+	// set explicitly, so are disabled with the cache.
+	if cfg.SnapshotDir == "" && cfg.CacheDir != "" {
+		cfg.SnapshotDir = filepath.Join(cfg.CacheDir, "snapshots")
+	}
+	if cfg.History == "" && cfg.CacheDir != "" {
+		cfg.History = filepath.Join(cfg.CacheDir, "history.jsonl")
 	}
-	ctx = context.WithValue(ctx, "cachedir", *cache)
 
-	// Signal `-list-devices` by forcing `-device=''`.
+	// Signal `-no-socket` by forcing an empty socket path.
+	if *no_socket {
+		cfg.Socket = ""
+	}
+
+	// Signal `-no-history` by forcing an empty history file.
+	if *no_history {
+		cfg.History = ""
+	}
+
+	// Signal `-list-devices` by forcing an empty device.
 	if *list_devices {
-		device = Pointer("")
+		cfg.Device = ""
+	}
+
+	// `-playlist` is a URI, a link, or an ID.
+	if *playlist != "" {
+		id, err := parsePlaylistID(*playlist)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		cfg.ExportPlaylist = id
 	}
-	ctx = context.WithValue(ctx, "device", *device)
 
 	// TODO: Signal `-version` by setting the version variable.
-	// ctx = context.WithValue(ctx, "version", VERSION)
 
-	return ctx
-}
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	cfg.ConfigureLogging()
 
+	return cfg, nil
+}
@@ -0,0 +1,258 @@
+package main
+
+// Layered run-time configuration. Every flag can also be set in a TOML
+// configuration file (e.g. `log-level = "debug"`) or in an environment
+// variable (e.g. `NSPOTIFY_LOG_LEVEL=debug`). Flags override the file, which
+// overrides the environment, which overrides the defaults.
+//
+// Key bindings, themes, and smart lists can only be set in the file: a
+// `[keys]` table maps key sequences to actions (e.g. `"g g" = "top"`), each
+// `[themes.NAME]` table maps elements to styles (e.g. `header = "red bold"`),
+// and each `[lists.NAME]` table maps rules to values (e.g. `year = "1990-1999"`).
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Flags that only make sense on the command line.
+var commandLineOnly = map[string]bool{
+	"config": true,
+	"print-config": true,
+	"export": true,
+	"o": true,
+	"playlist": true,
+	"import": true,
+	"import-to": true,
+}
+
+// Get the default configuration file path.
+func default_config_path() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "nspotify", "config.toml")
+}
+
+// Get the environment variable corresponding to a flag, e.g.
+// `NSPOTIFY_LOG_LEVEL` for `-log-level`.
+func envName(name string) string {
+	return "NSPOTIFY_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Settings that are tables in the configuration file, rather than flags.
+type configTables struct {
+	Keys   map[string]string            `toml:"keys,omitempty"`
+	Themes map[string]map[string]string `toml:"themes,omitempty"`
+	Lists  map[string]map[string]string `toml:"lists,omitempty"`
+}
+
+// Read a table of strings from a configuration file.
+func readStringTable(path, name string, value any) (map[string]string, error) {
+	table, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid value for %s: %v", path, name, value)
+	}
+
+	values := map[string]string{}
+	for key, value := range table {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: invalid value for %s.%s: %v", path, name, key, value)
+		}
+		values[key] = s
+	}
+
+	return values, nil
+}
+
+// Read a table of scalars from a configuration file, as strings.
+func readScalarTable(path, name string, value any) (map[string]string, error) {
+	table, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid value for %s: %v", path, name, value)
+	}
+
+	values := map[string]string{}
+	for key, value := range table {
+		switch value.(type) {
+		case string, bool, int64:
+			values[key] = fmt.Sprint(value)
+		default:
+			return nil, fmt.Errorf("%s: invalid value for %s.%s: %v", path, name, key, value)
+		}
+	}
+
+	return values, nil
+}
+
+// Read the tables of a configuration file.
+func readConfigTable(path, name string, value any, tables *configTables) error {
+	var err error
+
+	switch name {
+	case "keys":
+		tables.Keys, err = readStringTable(path, name, value)
+
+	case "themes":
+		themes, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: invalid value for %s: %v", path, name, value)
+		}
+		for theme, value := range themes {
+			tables.Themes[theme], err = readStringTable(path, name+"."+theme, value)
+			if err != nil {
+				break
+			}
+		}
+
+	case "lists":
+		lists, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: invalid value for %s: %v", path, name, value)
+		}
+		for list, value := range lists {
+			tables.Lists[list], err = readScalarTable(path, name+"."+list, value)
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	return err
+}
+
+// Read a configuration file into a map of flag names to values, and the
+// tables. A missing file is only an error if `required`.
+func readConfigFile(path string, required bool) (map[string]string, *configTables, error) {
+	values := map[string]string{}
+	tables := &configTables{
+		Keys: map[string]string{},
+		Themes: map[string]map[string]string{},
+		Lists: map[string]map[string]string{},
+	}
+	if path == "" {
+		return values, tables, nil
+	}
+
+	raw := map[string]any{}
+	_, err := toml.DecodeFile(path, &raw)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return values, tables, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	for name, value := range raw {
+		if name == "keys" || name == "themes" || name == "lists" {
+			if err := readConfigTable(path, name, value, tables); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		if flag.Lookup(name) == nil || commandLineOnly[name] {
+			return nil, nil, fmt.Errorf("%s: unknown setting: %s", path, name)
+		}
+
+		switch value.(type) {
+		case string, bool, int64:
+			values[name] = fmt.Sprint(value)
+		default:
+			return nil, nil, fmt.Errorf("%s: invalid value for %s: %v", path, name, value)
+		}
+	}
+
+	return values, tables, nil
+}
+
+// Apply the configuration file and environment variables to every flag that
+// was not set on the command line, and return the tables from the
+// configuration file. Must be called after `flag.Parse`.
+func applyConfigLayers(config_path string) (*configTables, error) {
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	// The configuration file itself can only be chosen by flag or by the
+	// environment.
+	required := explicit["config"]
+	if !required {
+		if path, ok := os.LookupEnv(envName("config")); ok {
+			config_path = path
+			required = true
+		}
+	}
+
+	file, tables, err := readConfigFile(config_path, required)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	flag.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] || commandLineOnly[f.Name] {
+			return
+		}
+
+		source := config_path
+		value, ok := file[f.Name]
+		if !ok {
+			source = envName(f.Name)
+			value, ok = os.LookupEnv(source)
+		}
+		if !ok {
+			return
+		}
+
+		if err := f.Value.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid value for %s: %w", source, f.Name, err))
+		}
+	})
+
+	return tables, errors.Join(errs...)
+}
+
+// Print the effective configuration as a configuration file: the value of
+// every flag, as overridden by the flags that disable a feature (e.g.
+// `-no-cache`), and the tables. The HTTP API token is redacted.
+func PrintConfig(w io.Writer, cfg *Config) error {
+	values := map[string]any{}
+	flag.VisitAll(func(f *flag.Flag) {
+		if commandLineOnly[f.Name] {
+			return
+		}
+		if getter, ok := f.Value.(flag.Getter); ok {
+			values[f.Name] = getter.Get()
+		}
+	})
+
+	values["log-level"] = cfg.LogLevel.String()
+	values["cache"] = cfg.CacheDir
+	values["device"] = string(cfg.Device)
+	values["socket"] = cfg.Socket
+	values["snapshots"] = cfg.SnapshotDir
+	values["history"] = cfg.History
+	if cfg.HTTPToken != "" {
+		values["http-token"] = "REDACTED"
+	}
+
+	if err := toml.NewEncoder(w).Encode(values); err != nil {
+		return err
+	}
+
+	tables := &configTables{Keys: cfg.Keys, Themes: cfg.Themes, Lists: cfg.Lists}
+	return toml.NewEncoder(w).Encode(tables)
+}
@@ -0,0 +1,313 @@
+package main
+
+// Control socket for driving a running instance, e.g.
+// `echo '{"command":"toggle"}' | socat - UNIX-CONNECT:[path]`.
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// A request to control the player. Used by the control socket, one request
+// per line.
+type ControlRequest struct {
+	// One of `play`, `pause`, `toggle`, `next`, `prev`, `queue`, `seek`,
+	// `volume`, or `status`.
+	Command string `json:"command"`
+
+	// URI or link for `play` (optional) and `queue` (required).
+	URI string `json:"uri,omitempty"`
+
+	// Position (in milliseconds) for `seek`.
+	Position int `json:"position_ms,omitempty"`
+
+	// Volume (as a percent) for `volume`.
+	Volume int `json:"volume,omitempty"`
+}
+
+// A response to a `ControlRequest`.
+type ControlResponse struct {
+	OK     bool    `json:"ok"`
+	Error  string  `json:"error,omitempty"`
+	Status *Status `json:"status,omitempty"`
+}
+
+// Convert a request to a player event. Returns nil for `status`, which is
+// not an event.
+func (req *ControlRequest) Event() (*Event, error) {
+	switch req.Command {
+	case "play":
+		if req.URI == "" {
+			return RequestPlay(), nil
+		}
+		uri, err := ParseURI(req.URI)
+		if err != nil {
+			return nil, err
+		}
+		return RequestPlayURI(uri.Spotify()), nil
+
+	case "queue":
+		uri, err := ParseURI(req.URI)
+		if err != nil {
+			return nil, err
+		}
+		if !uri.IsPlayable() {
+			return nil, fmt.Errorf("cannot queue a URI of type %s", uri.Type)
+		}
+		return RequestQueueURI(uri.Spotify()), nil
+
+	case "pause":
+		return RequestPause(), nil
+
+	case "toggle":
+		return RequestToggle(), nil
+
+	case "next":
+		return RequestPlayNext(), nil
+
+	case "prev":
+		return RequestPlayPrevious(), nil
+
+	case "seek":
+		if req.Position < 0 {
+			return nil, fmt.Errorf("invalid position: %d", req.Position)
+		}
+		return RequestSeek(req.Position), nil
+
+	case "volume":
+		if req.Volume < 0 || 100 < req.Volume {
+			return nil, fmt.Errorf("invalid volume: %d", req.Volume)
+		}
+		return RequestVolume(req.Volume), nil
+
+	case "status":
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown command: %s", req.Command)
+}
+
+// Get the directory for the control socket if `XDG_RUNTIME_DIR` is unset.
+func fallback_socket_dir() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("nspotify-%d", os.Getuid()))
+}
+
+// Get the default control socket path.
+func default_socket_path() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = fallback_socket_dir()
+	}
+
+	return filepath.Join(dir, "nspotify.sock")
+}
+
+// Check that the directory of a control socket cannot have been made by
+// another user. Only the fallback directory is checked, since anyone can
+// create it in advance to intercept requests.
+func checkSocketDir(path string) error {
+	dir := filepath.Dir(path)
+	if dir != fallback_socket_dir() {
+		return nil
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("control socket directory is not a directory owned by the current user: %s", dir)
+	}
+	if info.Mode().Perm() != 0700 {
+		return fmt.Errorf("control socket directory is accessible to other users: %s", dir)
+	}
+
+	return nil
+}
+
+// Handle a single request.
+func handleControlRequest(req *ControlRequest, tx chan<- *Event, state *StateWatcher) *ControlResponse {
+	ev, err := req.Event()
+	if err != nil {
+		return &ControlResponse{Error: err.Error()}
+	}
+
+	if ev == nil {
+		return &ControlResponse{OK: true, Status: state.Latest().Status()}
+	}
+
+	tx <- ev
+	return &ControlResponse{OK: true}
+}
+
+// Serve requests from a single connection until it closes.
+func controlWorker(conn net.Conn, tx chan<- *Event, state *StateWatcher) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var resp *ControlResponse
+		req := &ControlRequest{}
+		if err := json.Unmarshal([]byte(line), req); err != nil {
+			resp = &ControlResponse{Error: fmt.Sprintf("invalid request: %s", err)}
+		} else {
+			log.Debugf("control request: %s", req.Command)
+			resp = handleControlRequest(req, tx, state)
+		}
+
+		if err := encoder.Encode(resp); err != nil {
+			log.WithError(err).Debug("failed to write control response")
+			return
+		}
+	}
+}
+
+// Manager for the control socket. Terminates once the context is cancelled.
+func ControlManager(ctx context.Context, path string, tx chan<- *Event, state *StateWatcher) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		log.WithError(err).Errorf("failed to make control socket directory: %s", path)
+		return
+	}
+	if err := checkSocketDir(path); err != nil {
+		log.WithError(err).Error("refusing to listen on control socket")
+		return
+	}
+
+	// A socket file left behind by a dead instance can be removed, but one
+	// that is still accepting connections belongs to another instance.
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		log.Errorf("control socket is in use by another instance: %s", path)
+		return
+	}
+	os.Remove(path)
+
+	// Create the socket file without access for other users, rather than
+	// restricting it after the fact.
+	umask := syscall.Umask(0077)
+	listener, err := net.Listen("unix", path)
+	syscall.Umask(umask)
+	if err != nil {
+		log.WithError(err).Errorf("failed to listen on control socket: %s", path)
+		return
+	}
+	log.Debugf("listening on control socket: %s", path)
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.WithError(err).Error("control socket died")
+			}
+			break
+		}
+
+		go controlWorker(conn, tx, state)
+	}
+
+	// Closing the listener also removes the socket file.
+	log.Trace("context closed, terminating control manager")
+}
+
+// Parse the arguments of the `ctl` subcommand into a request.
+func ParseControlArgs(args []string) (*ControlRequest, error) {
+	req := &ControlRequest{Command: args[0]}
+
+	switch req.Command {
+	case "play":
+		if len(args) > 2 {
+			return nil, fmt.Errorf("usage: nspotify ctl play [URI]")
+		}
+		if len(args) == 2 {
+			req.URI = args[1]
+		}
+
+	case "queue":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("usage: nspotify ctl queue URI")
+		}
+		req.URI = args[1]
+
+	case "seek":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("usage: nspotify ctl seek [[HH:]MM:]SS")
+		}
+		position, err := ParseDuration(args[1])
+		if err != nil {
+			return nil, err
+		}
+		req.Position = position
+
+	case "volume":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("usage: nspotify ctl volume PERCENT")
+		}
+		volume, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid volume: %s", args[1])
+		}
+		req.Volume = volume
+
+	default:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("usage: nspotify ctl %s", req.Command)
+		}
+	}
+
+	// Validate before sending.
+	if _, err := req.Event(); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// Send a request to a running instance through the control socket.
+func SendControlRequest(path string, req *ControlRequest) (*ControlResponse, error) {
+	if err := checkSocketDir(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to control socket: %w", err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send control request: %w", err)
+	}
+
+	resp := &ControlResponse{}
+	if err := json.NewDecoder(conn).Decode(resp); err != nil {
+		return nil, fmt.Errorf("failed to read control response: %w", err)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+// Copying rows to the clipboard, as URIs, links, or text.
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+)
+
+// Formats of copied rows, by name.
+var copyFormats = map[string]func(ref any) (string, bool){
+	"uri": func(ref any) (string, bool) {
+		uri, ok := referenceURI(ref)
+		if !ok {
+			return "", false
+		}
+		return uri.String(), true
+	},
+	"link": func(ref any) (string, bool) {
+		uri, ok := referenceURI(ref)
+		if !ok {
+			return "", false
+		}
+		return uri.Link(), true
+	},
+	"text": referenceTitle,
+	"markdown": func(ref any) (string, bool) {
+		title, ok := referenceTitle(ref)
+		uri, hasURI := referenceURI(ref)
+		if !ok || !hasURI {
+			return "", false
+		}
+		return fmt.Sprintf("[%s](%s)", escapeMarkdown(title), uri.Link()), true
+	},
+}
+
+// Describe the reference of a row as text, e.g. `Artist – Title`.
+func referenceTitle(ref any) (string, bool) {
+	switch ref := ref.(type) {
+	case *Item:
+		return ref.Artist() + " – " + ref.Name(), true
+	case *spotify.SimplePlaylist:
+		return ref.Name, true
+	case *spotify.SimpleAlbum:
+		return FormatArtists(ref.Artists) + " – " + ref.Name, true
+	case *spotify.SimpleShow:
+		return ref.Name, true
+	case *SearchResult:
+		return ref.Description, true
+	case *SnapshotTrack:
+		return strings.Join(ref.Artists, ", ") + " – " + ref.Name, true
+	case *HistoryEntry:
+		return ref.String(), true
+	case *StatsRank:
+		if ref.By != "" {
+			return ref.By + " – " + ref.Name, true
+		}
+		return ref.Name, true
+	}
+	return "", false
+}
+
+// Escape the characters of text that are special inside a Markdown link.
+func escapeMarkdown(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`).Replace(text)
+}
+
+// Format the references of rows to copy, one per line. References that have
+// nothing to copy are skipped.
+func FormatCopy(refs []any, format string) (string, error) {
+	by, ok := copyFormats[format]
+	if !ok {
+		return "", fmt.Errorf("unknown copy format: %s", format)
+	}
+
+	lines := []string{}
+	for _, ref := range refs {
+		if line, ok := by(ref); ok {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return "", fmt.Errorf("nothing to copy")
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Get the references of the rows to copy: the marked items on the current
+// page, or else the selected row.
+func (ui *UI) copyTargets() []any {
+	if table, ok := ui.currentItems(); ok {
+		if marked := table.Marked(); len(marked) != 0 {
+			refs := []any{}
+			for _, item := range marked {
+				refs = append(refs, item)
+			}
+			return refs
+		}
+	}
+
+	return []any{ui.selectedReference()}
+}
+
+// Copy the references of rows to the clipboard in a format (see
+// `copyFormats`).
+func (ui *UI) copyReferences(refs []any, format string) {
+	text, err := FormatCopy(refs, format)
+	if err != nil {
+		log.Info(err)
+		return
+	}
+
+	description := text
+	if len(refs) > 1 {
+		description = fmt.Sprintf("%d rows", len(refs))
+	}
+
+	if tty, ok := ui.screen.Tty(); ok {
+		_, err := tty.Write(osc52(text))
+		if err == nil {
+			ui.message("copied " + description)
+			return
+		}
+		log.WithError(err).Debug("failed to copy through the terminal, falling back to a command")
+	}
+
+	// Copying with a command does not block the application.
+	go func() {
+		if err := WriteClipboard(text); err != nil {
+			log.WithError(err).Error("failed to copy")
+			return
+		}
+
+		ui.app.QueueUpdateDraw(func() {
+			ui.message("copied " + description)
+		})
+	}()
+}
@@ -0,0 +1,236 @@
+package main
+
+// Details pane, showing every field of the selected row. Artists, the album
+// (or show), and the copy links can be clicked.
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Details pane. The pane follows the selection of the current page, so it
+// never takes focus.
+type DetailsPane struct {
+	*tview.TextView
+	theme *Theme
+
+	// Get the reference of the selected row, if any.
+	selected func() any
+
+	// Reference shown.
+	shown any
+}
+
+// Create the details pane, styled by a theme. `selected` gets the reference
+// of the selected row, and `clicked` is called with the region of a clicked
+// link: `artist-N`, `album`, `copy-uri`, or `copy-link`.
+func NewDetailsPane(theme *Theme, selected func() any, clicked func(region string)) *DetailsPane {
+	pane := &DetailsPane{
+		TextView: tview.NewTextView().SetDynamicColors(true).SetRegions(true).SetWrap(true).SetWordWrap(true),
+		theme: theme,
+		selected: selected,
+	}
+	pane.SetBorder(true).SetTitle(" Details ")
+
+	// Links act once, rather than staying highlighted.
+	pane.SetHighlightedFunc(func(added, removed, remaining []string) {
+		if len(added) == 0 {
+			return
+		}
+		pane.Highlight()
+		clicked(added[0])
+	})
+
+	pane.SetMouseCapture(func(action tview.MouseAction, ev *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		if action == tview.MouseLeftDown {
+			return action, nil
+		}
+		return action, ev
+	})
+
+	return pane
+}
+
+// Show the details of the selected row, if it changed, before drawing.
+func (pane *DetailsPane) Draw(screen tcell.Screen) {
+	if ref := pane.selected(); ref != pane.shown {
+		pane.shown = ref
+		pane.SetText(FormatDetails(ref, pane.theme)).ScrollToBeginning()
+	}
+
+	pane.TextView.Draw(screen)
+}
+
+// Builder of the text of the details pane.
+type detailsText struct {
+	strings.Builder
+	label string
+}
+
+// Add a field, skipping empty values. The value is escaped.
+func (d *detailsText) field(name, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(&d.Builder, "%s%s:[-:-:-] %s\n", d.label, name, tview.Escape(value))
+}
+
+// Add a field whose value is a clickable link to a region.
+func (d *detailsText) link(name, region, value string) {
+	fmt.Fprintf(&d.Builder, "%s%s:[-:-:-] [\"%s\"]%s[\"\"]\n", d.label, name, region, tview.Escape(value))
+}
+
+// Add the URI of a row, its link, and the links to copy them.
+func (d *detailsText) uri(uri spotify.URI) {
+	parsed, err := ParseURI(string(uri))
+	if err != nil {
+		d.field("URI", string(uri))
+		return
+	}
+
+	d.field("ID", parsed.ID.String())
+	d.field("URI", parsed.String())
+	d.field("Link", parsed.Link())
+	fmt.Fprintf(&d.Builder, "\n[\"copy-uri\"]%s[\"\"]  [\"copy-link\"]%s[\"\"]\n", tview.Escape("[Copy URI]"), tview.Escape("[Copy link]"))
+}
+
+// Format a flag as `yes` or `no`.
+func formatFlag(flag bool) string {
+	if flag {
+		return "yes"
+	}
+	return "no"
+}
+
+// Format the details of a track.
+func (d *detailsText) track(item *Item) {
+	track := item.Track
+
+	d.field("Name", track.Name)
+	for i, artist := range track.Artists {
+		d.link("Artist", fmt.Sprintf("artist-%d", i), artist.Name)
+		d.field("  URI", string(artist.URI))
+	}
+	d.link("Album", "album", track.Album.Name)
+	d.field("  URI", string(track.Album.URI))
+	d.field("Track", fmt.Sprintf("%d (disc %d)", track.TrackNumber, track.DiscNumber))
+	d.field("Released", track.Album.ReleaseDate)
+	d.field("Duration", FormatDuration(track.Duration))
+	d.field("Popularity", fmt.Sprintf("%d/100", track.Popularity))
+	d.field("Explicit", formatFlag(track.Explicit))
+	d.field("ISRC", item.ISRC())
+	d.field("Added", item.AddedAt)
+	if len(track.AvailableMarkets) != 0 {
+		d.field("Markets", fmt.Sprintf("%d: %s", len(track.AvailableMarkets), strings.Join(track.AvailableMarkets, " ")))
+	}
+	d.uri(track.URI)
+}
+
+// Format the details of an episode.
+func (d *detailsText) episode(item *Item) {
+	episode := item.Episode
+
+	d.field("Name", episode.Name)
+	d.link("Show", "album", episode.Show.Name)
+	d.field("  URI", string(episode.Show.URI))
+	d.field("Released", episode.ReleaseDate)
+	d.field("Duration", FormatDuration(episode.Duration_ms))
+	d.field("Resume", FormatResumePoint(episode))
+	d.field("Explicit", formatFlag(episode.Explicit))
+	d.field("Added", item.AddedAt)
+	d.uri(episode.URI)
+	if episode.Description != "" {
+		d.WriteString("\n" + tview.Escape(episode.Description) + "\n")
+	}
+}
+
+// Format the details of the reference of a row, e.g. an item or a
+// playlist.
+func FormatDetails(ref any, theme *Theme) string {
+	d := &detailsText{label: StyleTag(theme.Header)}
+
+	switch ref := ref.(type) {
+	case *Item:
+		if ref.Episode != nil {
+			d.episode(ref)
+		} else {
+			d.track(ref)
+		}
+
+	case *itemGroup:
+		d.field("Group", ref.title)
+		d.field("Tracks", fmt.Sprint(ref.count))
+		d.field("Duration", FormatDuration(ref.duration))
+
+	case *spotify.SimplePlaylist:
+		d.field("Playlist", ref.Name)
+		d.field("Owner", ref.Owner.DisplayName)
+		d.field("Tracks", fmt.Sprint(ref.Tracks.Total))
+		d.field("Description", ref.Description)
+		d.uri(ref.URI)
+
+	case *spotify.SimpleAlbum:
+		d.field("Album", ref.Name)
+		d.field("Artists", FormatArtists(ref.Artists))
+		d.field("Released", ref.ReleaseDate)
+		d.field("Type", ref.AlbumType)
+		d.uri(ref.URI)
+
+	case *spotify.SimpleShow:
+		d.field("Show", ref.Name)
+		d.field("Publisher", ref.Publisher)
+		d.field("Description", ref.Description)
+		d.uri(ref.URI)
+
+	case *spotify.PlayerDevice:
+		d.field("Device", ref.Name)
+		d.field("ID", ref.ID.String())
+		d.field("Type", ref.Type)
+		d.field("Active", formatFlag(ref.Active))
+		d.field("Volume", fmt.Sprintf("%d%%", ref.Volume))
+
+	case *SearchResult:
+		d.field("Result", ref.Description)
+		d.uri(ref.URI)
+
+	case *SnapshotTrack:
+		d.field("Name", ref.Name)
+		d.field("Artists", strings.Join(ref.Artists, ", "))
+		d.field("Album", ref.Album)
+		d.field("Duration", FormatDuration(ref.Duration))
+		d.field("ISRC", ref.ISRC)
+		d.field("Added", ref.AddedAt)
+		d.field("Playable", formatFlag(!ref.Unplayable))
+		d.field("Relinked", ref.RelinkedTo)
+		d.uri(spotify.URI(ref.URI))
+
+	case *HistoryEntry:
+		d.field("Name", ref.Name)
+		d.field("Artists", strings.Join(ref.Artists, ", "))
+		d.field("Album", ref.Album)
+		d.field("Show", ref.Show)
+		d.field("Started", ref.Started.Format(time.DateTime))
+		d.field("Heard", FormatDuration(ref.Heard)+" of "+FormatDuration(ref.Duration))
+		d.field("Device", ref.Device)
+		d.field("Context", string(ref.Context))
+		d.field("Source", ref.Source)
+		d.uri(ref.URI)
+
+	case *StatsRank:
+		d.field("Name", ref.Name)
+		d.field("By", ref.By)
+		d.field("Plays", fmt.Sprint(ref.Plays))
+		d.field("Heard", FormatDuration(ref.Heard))
+		d.uri(ref.URI)
+
+	default:
+		d.WriteString("Nothing selected.\n")
+	}
+
+	return d.String()
+}
@@ -8,13 +8,14 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
 )
 
 // Fetch and report the devices available to Spotify.
-func ListDevices(ctx context.Context, cli *spotify.Client) {
+func ListDevices(ctx context.Context, cli *spotify.Client) error {
 	dev, err := cli.PlayerDevices(ctx)
 	if err != nil {
-		log.WithError(err).Fatal("failed to list devices")
+		return fmt.Errorf("failed to list devices: %w", err)
 	}
 
 	fmt.Printf("%-30s %s\n", "Device (*=active)", "ID (pass this with `-device=ID`)")
@@ -35,5 +36,40 @@ func ListDevices(ctx context.Context, cli *spotify.Client) {
 
 		fmt.Printf("%-30s %s\n", name, id)
 	}
+
+	return nil
+}
+
+// Create a row of table cells from a Spotify device. The first cell
+// references the device.
+func DeviceIntoCells(device *spotify.PlayerDevice) []*tview.TableCell {
+	label := device.Name
+	if device.Active {
+		label += " (*)"
+	}
+
+	name := tview.NewTableCell(label).SetReference(device)
+	kind := tview.NewTableCell(device.Type)
+	volume := tview.NewTableCell(fmt.Sprintf("%d%%", device.Volume)).SetAlign(tview.AlignRight)
+
+	return []*tview.TableCell{name, kind, volume}
 }
 
+// Replace the listing of devices.
+func LoadDevices(ctx context.Context, cli *spotify.Client, app *tview.Application, devices *tview.Table) {
+	dev, err := cli.PlayerDevices(ctx)
+	if err != nil {
+		log.WithError(err).Error("failed to fetch devices")
+		return
+	}
+	log.Tracef("fetched %d devices", len(dev))
+
+	app.QueueUpdateDraw(func() {
+		devices.Clear()
+		for row := range dev {
+			for col, cell := range DeviceIntoCells(&dev[row]) {
+				devices.SetCell(row, col, cell)
+			}
+		}
+	})
+}
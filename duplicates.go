@@ -0,0 +1,332 @@
+package main
+
+// Duplicates among the saved tracks, e.g. the same song saved from a single,
+// an album, and a compilation. Copies are found by ISRC, and by artist, name,
+// and duration. One copy of each is kept, and the others can be removed.
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
+)
+
+// A group of copies of a saved track.
+type duplicateGroup struct {
+	items []*Item
+
+	// How the copies were found, e.g. `ISRC`.
+	reasons []string
+
+	// Index of the copy to keep.
+	keep int
+}
+
+// Describe a group, like `Artist - Title (3 copies, by ISRC)`.
+func (group *duplicateGroup) String() string {
+	item := group.items[group.keep]
+	return fmt.Sprintf("%s - %s (%d copies, by %s)", item.Artist(), item.Name(), len(group.items), strings.Join(group.reasons, " and "))
+}
+
+// Get the copies to remove.
+func (group *duplicateGroup) removed() []*Item {
+	items := []*Item{}
+	for i, item := range group.items {
+		if i != group.keep {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Rank of an album type, when choosing a copy to keep. Albums are preferred
+// over singles, and singles over compilations.
+func albumTypeRank(albumType string) int {
+	switch strings.ToLower(albumType) {
+	case "album":
+		return 0
+	case "single":
+		return 1
+	case "compilation":
+		return 2
+	}
+	return 3
+}
+
+// Choose the copy to keep: from an album if possible, and else the copy saved
+// first.
+func (group *duplicateGroup) chooseKeep() {
+	best := 0
+	for i, item := range group.items {
+		a, b := item.Track.Album.AlbumType, group.items[best].Track.Album.AlbumType
+		if rank := cmp.Compare(albumTypeRank(a), albumTypeRank(b)); rank < 0 || (rank == 0 && item.AddedAt < group.items[best].AddedAt) {
+			best = i
+		}
+	}
+	group.keep = best
+}
+
+// Disjoint sets of items, for merging groups found in different ways.
+type itemSets map[*Item]*Item
+
+// Find the representative of an item's set.
+func (sets itemSets) find(item *Item) *Item {
+	parent, ok := sets[item]
+	if !ok || parent == item {
+		sets[item] = item
+		return item
+	}
+
+	root := sets.find(parent)
+	sets[item] = root
+	return root
+}
+
+// Merge the sets of some items.
+func (sets itemSets) union(items []*Item) {
+	for _, item := range items[1:] {
+		sets[sets.find(item)] = sets.find(items[0])
+	}
+}
+
+// Key of a track by its first artist and name. Qualifiers like `(Live)` are
+// kept, since they name a distinct recording.
+func nameKey(item *Item) string {
+	artist := ""
+	if len(item.Track.Artists) != 0 {
+		artist = item.Track.Artists[0].Name
+	}
+	return normalizeArtist(artist) + "\n" + normalizeRecording(item.Name())
+}
+
+// Find the groups of duplicate tracks among some items. Tracks with the same
+// ISRC are duplicates, as are tracks with the same artist and name whose
+// durations are within 3 seconds. Episodes are never duplicates.
+func FindDuplicates(items []*Item) []*duplicateGroup {
+	byISRC := map[string][]*Item{}
+	byName := map[string][]*Item{}
+	for _, item := range items {
+		if item.Track == nil {
+			continue
+		}
+		if isrc := strings.ToUpper(item.ISRC()); isrc != "" {
+			byISRC[isrc] = append(byISRC[isrc], item)
+		}
+		byName[nameKey(item)] = append(byName[nameKey(item)], item)
+	}
+
+	sets := itemSets{}
+	reasons := map[*Item]map[string]bool{}
+	merge := func(copies []*Item, reason string) {
+		if len(copies) < 2 {
+			return
+		}
+		sets.union(copies)
+		for _, item := range copies {
+			if reasons[item] == nil {
+				reasons[item] = map[string]bool{}
+			}
+			reasons[item][reason] = true
+		}
+	}
+
+	for _, copies := range byISRC {
+		merge(copies, "ISRC")
+	}
+
+	// Copies with the same name are split where their durations differ,
+	// e.g. an extended recording. Durations are compared with the shortest
+	// copy of each group, so that groups never chain.
+	for _, copies := range byName {
+		slices.SortFunc(copies, func(a, b *Item) int {
+			return cmp.Compare(a.Duration(), b.Duration())
+		})
+
+		start := 0
+		for i := 1; i <= len(copies); i++ {
+			if i == len(copies) || durationCloseness(copies[start].Duration(), copies[i].Duration()) < 1 {
+				merge(copies[start:i], "name")
+				start = i
+			}
+		}
+	}
+
+	// Collect the sets in the order that their items were loaded.
+	groups := []*duplicateGroup{}
+	byRoot := map[*Item]*duplicateGroup{}
+	for _, item := range items {
+		if _, ok := sets[item]; !ok {
+			continue
+		}
+
+		root := sets.find(item)
+		group, ok := byRoot[root]
+		if !ok {
+			group = &duplicateGroup{}
+			byRoot[root] = group
+			groups = append(groups, group)
+		}
+		group.items = append(group.items, item)
+	}
+
+	for _, group := range groups {
+		found := map[string]bool{}
+		for _, item := range group.items {
+			for reason := range reasons[item] {
+				found[reason] = true
+			}
+		}
+		for _, reason := range []string{"ISRC", "name"} {
+			if found[reason] {
+				group.reasons = append(group.reasons, reason)
+			}
+		}
+
+		slices.SortStableFunc(group.items, func(a, b *Item) int {
+			return cmp.Compare(a.AddedAt, b.AddedAt)
+		})
+		group.chooseKeep()
+	}
+
+	slices.SortStableFunc(groups, func(a, b *duplicateGroup) int {
+		return compareFold(a.String(), b.String())
+	})
+
+	return groups
+}
+
+// Show groups of duplicates in a table, with every copy under its group and
+// marked as kept or removed. Rows of copies reference the item.
+func ShowDuplicates(table *tview.Table, groups []*duplicateGroup, theme *Theme) {
+	selected, _ := table.GetSelection()
+	table.Clear()
+
+	removed := 0
+	for _, group := range groups {
+		removed += len(group.items) - 1
+	}
+
+	summary := fmt.Sprintf("%d duplicated tracks, %d copies to remove (:keep keeps the selected copy instead, :remove-duplicates removes the others)", len(groups), removed)
+	table.SetCell(0, 0, tview.NewTableCell(summary).SetStyle(theme.Header).SetSelectable(false))
+
+	row := 1
+	for _, group := range groups {
+		table.SetCell(row, 0, tview.NewTableCell("").SetSelectable(false))
+		table.SetCell(row+1, 0, tview.NewTableCell(group.String()).SetStyle(theme.Header).SetSelectable(false))
+		row += 2
+
+		for i, item := range group.items {
+			action := "remove"
+			style := theme.Text
+			if i == group.keep {
+				action = "keep"
+				style = theme.Marked
+			}
+
+			table.SetCell(row, 0, tview.NewTableCell(action).SetReference(item).SetStyle(style))
+			table.SetCell(row, 1, tview.NewTableCell(item.Name()).SetExpansion(2))
+			table.SetCell(row, 2, tview.NewTableCell(item.Album()).SetExpansion(2))
+			table.SetCell(row, 3, tview.NewTableCell(item.Track.Album.AlbumType))
+			table.SetCell(row, 4, tview.NewTableCell(item.AddedAt))
+			table.SetCell(row, 5, tview.NewTableCell(FormatDuration(item.Duration())).SetAlign(tview.AlignRight))
+			row++
+		}
+	}
+
+	if selected < 1 || selected >= row {
+		selected = 3
+	}
+	table.Select(selected, 0)
+}
+
+// Remove items from the saved tracks, in batches.
+func RemoveFromLibrary(ctx context.Context, cli *spotify.Client, items []*Item) error {
+	ids := []spotify.ID{}
+	for _, item := range items {
+		ids = append(ids, item.Track.ID)
+	}
+
+	for start := 0; start < len(ids); start += 50 {
+		if err := cli.RemoveTracksFromLibrary(ctx, ids[start:min(start+50, len(ids))]...); err != nil {
+			return fmt.Errorf("failed to remove tracks: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Fetch every saved track, find the duplicates, and show the duplicates
+// page.
+func (ui *UI) findDuplicates() {
+	ui.app.QueueUpdateDraw(func() {
+		ui.message("fetching the saved tracks to find duplicates...")
+	})
+
+	library, err := FetchLibrary(ui.ctx, ui.cli)
+	if err != nil {
+		log.WithError(err).Error("failed to find duplicates")
+		ui.app.QueueUpdateDraw(func() {
+			ui.message("failed to fetch the saved tracks to find duplicates")
+		})
+		return
+	}
+	groups := FindDuplicates(library)
+
+	ui.app.QueueUpdateDraw(func() {
+		ui.duplicateGroups = groups
+		ShowDuplicates(ui.duplicates, groups, ui.theme)
+		ui.showPage("duplicates")
+		ui.message(fmt.Sprintf("found %d duplicated tracks", len(groups)))
+	})
+}
+
+// Keep the copy of a duplicate in a row of the duplicates page, instead of
+// the others.
+func (ui *UI) keepDuplicate(row int) {
+	item, ok := ItemAt(ui.duplicates, row)
+	if !ok {
+		log.Info("no copy selected")
+		return
+	}
+
+	for _, group := range ui.duplicateGroups {
+		if i := slices.Index(group.items, item); i >= 0 {
+			group.keep = i
+		}
+	}
+	ShowDuplicates(ui.duplicates, ui.duplicateGroups, ui.theme)
+}
+
+// Remove every copy of a duplicate that is not kept, after confirming.
+func (ui *UI) removeDuplicates() {
+	removed := []*Item{}
+	for _, group := range ui.duplicateGroups {
+		removed = append(removed, group.removed()...)
+	}
+	if len(removed) == 0 {
+		ui.message("no duplicates to remove (try :duplicates)")
+		return
+	}
+
+	text := fmt.Sprintf("Remove %d copies of %d duplicated tracks from the saved tracks?", len(removed), len(ui.duplicateGroups))
+	ui.confirm(text, "Remove", func() {
+		go func() {
+			if err := RemoveFromLibrary(ui.ctx, ui.cli, removed); err != nil {
+				log.WithError(err).Error("failed to remove duplicates")
+				return
+			}
+
+			ui.app.QueueUpdateDraw(func() {
+				ui.duplicateGroups = nil
+				ShowDuplicates(ui.duplicates, nil, ui.theme)
+				ui.message(fmt.Sprintf("removed %d copies from the saved tracks", len(removed)))
+			})
+		}()
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+// Spotify shows and episodes interactions.
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
+)
+
+// Format the resume point of an episode. Episodes that have not been started
+// are blank, and episodes that have been finished are `played`.
+func FormatResumePoint(episode *spotify.EpisodePage) string {
+	if episode.ResumePoint.FullyPlayed {
+		return "played"
+	}
+	if episode.ResumePoint.ResumePositionMs == 0 {
+		return ""
+	}
+	return FormatDuration(episode.ResumePoint.ResumePositionMs)
+}
+
+// Columns of a table of episodes.
+var episodeColumns = []Column{
+	{"Name", "name"},
+	{"Show", "artist"},
+	{"Released", "released"},
+	{"Resume", ""},
+	{"Duration", "duration"},
+}
+
+// Create a row of table cells from a Spotify episode.
+func EpisodeIntoCells(episode *spotify.EpisodePage) []*tview.TableCell {
+	name := tview.NewTableCell(episode.Name)
+	show := tview.NewTableCell(episode.Show.Name)
+	released := tview.NewTableCell(episode.ReleaseDate)
+	resume := tview.NewTableCell(FormatResumePoint(episode)).SetAlign(tview.AlignRight)
+	duration := tview.NewTableCell(FormatDuration(episode.Duration_ms)).SetAlign(tview.AlignRight)
+
+	return []*tview.TableCell{name, show, released, resume, duration}
+}
+
+// Create a row of table cells from a saved Spotify show. The first cell
+// references the show.
+func ShowIntoCells(show *spotify.SavedShow) []*tview.TableCell {
+	name := tview.NewTableCell(show.Name).SetReference(&show.SimpleShow)
+	publisher := tview.NewTableCell(show.Publisher)
+
+	return []*tview.TableCell{name, publisher}
+}
+
+// Fetch all of the end user's saved shows.
+func FetchShows(ctx context.Context, cli *spotify.Client) ([]spotify.SavedShow, error) {
+	log.Trace("fetching first page of shows...")
+	page, err := cli.CurrentUsersShows(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	shows := page.Shows
+	for {
+		log.Trace("fetching a new page of shows...")
+		err = cli.NextPage(ctx, page)
+		if err == spotify.ErrNoMorePages {
+			return shows, nil
+		}
+		if err != nil {
+			return shows, err
+		}
+
+		shows = append(shows, page.Shows...)
+	}
+}
+
+// Fetch the episodes of a show, newest first. The episodes are sent through
+// the channel as they are fetched, and the channel is closed once there are
+// no more episodes or the context is cancelled.
+func FetchEpisodes(ctx context.Context, cli *spotify.Client, show *spotify.SimpleShow, ch chan<- *Item) {
+	defer close(ch)
+
+	log.Tracef("fetching first page of episodes for %s...", show.Name)
+	page, err := cli.GetShowEpisodes(ctx, show.ID.String())
+	if err != nil {
+		log.WithError(err).Errorf("failed to fetch episodes for %s", show.Name)
+		return
+	}
+
+	for {
+		for i := range page.Episodes {
+			episode := &page.Episodes[i]
+
+			// The episodes endpoint omits the show, but it is
+			// needed for display.
+			episode.Show = *show
+
+			select {
+			case ch <- EpisodeItem(episode):
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		log.Trace("fetching a new page of episodes...")
+		err = cli.NextPage(ctx, page)
+		if err == spotify.ErrNoMorePages {
+			log.Debug("no more pages of episodes")
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("failed to fetch a page of episodes")
+			return
+		}
+	}
+}
@@ -5,7 +5,6 @@ package main
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zmb3/spotify/v2"
@@ -36,6 +35,19 @@ const (
 
 	// Player event requesting that the next song play.
 	PlayNext EventType = 6
+
+	// Player event requesting that playback seek to a position.
+	Seek EventType = 7
+
+	// Player event requesting that the volume be set.
+	Volume EventType = 8
+
+	// Player event requesting that playback move to another device.
+	Transfer EventType = 9
+
+	// Player event requesting that playback begin with a list of URIs,
+	// played in order.
+	PlayURIList EventType = 10
 )
 
 // Convert a player event to a printable (debug-able) string.
@@ -61,6 +73,18 @@ func debugEvent(ev EventType) string {
 
 	case PlayNext:
 		return "PlayNext"
+
+	case Seek:
+		return "Seek"
+
+	case Volume:
+		return "Volume"
+
+	case Transfer:
+		return "Transfer"
+
+	case PlayURIList:
+		return "PlayURIList"
 	}
 	
 	return fmt.Sprintf("%d", ev)
@@ -70,6 +94,18 @@ func debugEvent(ev EventType) string {
 type Event struct {
 	Type EventType
 	URI  spotify.URI
+
+	// Position (in milliseconds) to begin playback from, or to seek to.
+	Position int
+
+	// Volume (as a percent) to set.
+	Volume int
+
+	// Device to move playback to.
+	Device spotify.ID
+
+	// URIs to play in order.
+	URIs []spotify.URI
 }
 
 // Creates an `Event` of type `PlayURI`.
@@ -80,6 +116,16 @@ func RequestPlayURI(uri spotify.URI) *Event {
 	}
 }
 
+// Creates an `Event` of type `PlayURI` that begins playback partway through
+// the URI, e.g. to resume an episode.
+func RequestResumeURI(uri spotify.URI, position int) *Event {
+	return &Event{
+		Type: PlayURI,
+		URI: uri,
+		Position: position,
+	}
+}
+
 // Creates an `Event` of type `QueueURI`.
 func RequestQueueURI(uri spotify.URI) *Event {
 	return &Event{
@@ -128,88 +174,208 @@ func RequestPlayNext() *Event {
 	}
 }
 
+// Creates an `Event` of type `Seek`.
+func RequestSeek(position int) *Event {
+	return &Event{
+		Type: Seek,
+		URI: spotify.URI(""),
+		Position: position,
+	}
+}
+
+// Creates an `Event` of type `Volume`.
+func RequestVolume(percent int) *Event {
+	return &Event{
+		Type: Volume,
+		URI: spotify.URI(""),
+		Volume: percent,
+	}
+}
+
+// Creates an `Event` of type `Transfer`.
+func RequestTransfer(dev spotify.ID) *Event {
+	return &Event{
+		Type: Transfer,
+		URI: spotify.URI(""),
+		Device: dev,
+	}
+}
+
+// Creates an `Event` of type `PlayURIList`.
+func RequestPlayURIList(uris []spotify.URI) *Event {
+	return &Event{
+		Type: PlayURIList,
+		URI: spotify.URI(""),
+		URIs: uris,
+	}
+}
+
+// Creates the `Event`s to play some URIs, e.g. ones pasted by the end user.
+// The first URI is played and any others are enqueued after it.
+func RequestPlayURIs(uris []*URI) []*Event {
+	evs := []*Event{RequestPlayURI(uris[0].Spotify())}
+	for _, uri := range uris[1:] {
+		evs = append(evs, RequestQueueURI(uri.Spotify()))
+	}
+
+	return evs
+}
+
+// Build the options for targeting a device. If no device is configured, the
+// end user's currently active device is targeted.
+func deviceOptions(dev spotify.ID) *spotify.PlayOptions {
+	opts := &spotify.PlayOptions{}
+	if dev != "" {
+		opts.DeviceID = &dev
+	}
+
+	return opts
+}
+
+// Build the options for playing a URI on a device. Contexts (e.g. albums and
+// playlists) are played as a context, while tracks and episodes are played
+// directly.
+func playOptions(dev spotify.ID, uri *URI, position int) *spotify.PlayOptions {
+	opts := deviceOptions(dev)
+	opts.PositionMs = position
+
+	if uri.IsContext() {
+		context_uri := uri.Spotify()
+		opts.PlaybackContext = &context_uri
+	} else {
+		opts.URIs = []spotify.URI{uri.Spotify()}
+	}
+
+	return opts
+}
+
 // Reusable handler for an `Event` of type `Play`.
-func handlePlayEvent(ctx context.Context, cli *spotify.Client) {
-	err := cli.Play(ctx)
+func handlePlayEvent(ctx context.Context, cli *spotify.Client, dev spotify.ID) error {
+	err := cli.PlayOpt(ctx, deviceOptions(dev))
 	if err != nil {
-		log.WithError(err).Error("request to play failed")
+		return fmt.Errorf("request to play failed: %w", err)
 	}
+
+	return nil
 }
 
 // Reusable handler for an `Event` of type `Pause`.
-func handlePauseEvent(ctx context.Context, cli *spotify.Client) {
-	err := cli.Pause(ctx)
+func handlePauseEvent(ctx context.Context, cli *spotify.Client, dev spotify.ID) error {
+	err := cli.PauseOpt(ctx, deviceOptions(dev))
 	if err != nil {
-		log.WithError(err).Error("request to pause failed")
+		return fmt.Errorf("request to pause failed: %w", err)
 	}
+
+	return nil
 }
 
-// Manager for player events.
-func EventsManager(ctx context.Context, cli *spotify.Client, ch <-chan *Event) {
-	sdev, ok := ctx.Value("device").(string)
-	dev := spotify.ID(sdev)
-	if !ok || (dev == "") {
-		log.Errorf("invalid device: %s", sdev)
+// Handle a single player event on a device.
+func HandleEvent(ctx context.Context, cli *spotify.Client, dev spotify.ID, ev *Event) error {
+	switch ev.Type {
+	case PlayURI:
+		uri, err := ParseURI(string(ev.URI))
+		if err != nil {
+			return fmt.Errorf("request to play URI failed: %w", err)
+		}
+
+		err = cli.PlayOpt(ctx, playOptions(dev, uri, ev.Position))
+		if err != nil {
+			return fmt.Errorf("request to play URI failed: %w", err)
+		}
+
+	case QueueURI:
+		uri, err := ParseURI(string(ev.URI))
+		if err != nil {
+			return fmt.Errorf("request to queue URI failed: %w", err)
+		}
+		if !uri.IsPlayable() {
+			return fmt.Errorf("request to queue URI failed: cannot queue a URI of type %s", uri.Type)
+		}
+
+		err = QueueURIOnDevice(ctx, cli, dev, uri.Spotify())
+		if err != nil {
+			return fmt.Errorf("request to queue URI failed: %w", err)
+		}
+
+	case Play:
+		return handlePlayEvent(ctx, cli, dev)
+
+	case Pause:
+		return handlePauseEvent(ctx, cli, dev)
+
+	case Toggle:
+		status, err := cli.PlayerCurrentlyPlaying(ctx)
+		if err != nil {
+			log.WithError(err).Error("request to determine playback status, so assuming that status is playing")
+			return handlePauseEvent(ctx, cli, dev)
+		}
+		if status.Playing {
+			return handlePauseEvent(ctx, cli, dev)
+		}
+		return handlePlayEvent(ctx, cli, dev)
+
+	case PlayNext:
+		err := cli.NextOpt(ctx, deviceOptions(dev))
+		if err != nil {
+			return fmt.Errorf("request to play next failed: %w", err)
+		}
+
+	case PlayPrevious:
+		err := cli.PreviousOpt(ctx, deviceOptions(dev))
+		if err != nil {
+			return fmt.Errorf("request to play previous failed: %w", err)
+		}
+
+	case Seek:
+		err := cli.SeekOpt(ctx, ev.Position, deviceOptions(dev))
+		if err != nil {
+			return fmt.Errorf("request to seek failed: %w", err)
+		}
+
+	case Volume:
+		err := cli.VolumeOpt(ctx, ev.Volume, deviceOptions(dev))
+		if err != nil {
+			return fmt.Errorf("request to set volume failed: %w", err)
+		}
+
+	case PlayURIList:
+		if len(ev.URIs) == 0 {
+			return fmt.Errorf("request to play URIs failed: no URIs")
+		}
+
+		opts := deviceOptions(dev)
+		opts.URIs = ev.URIs
+		err := cli.PlayOpt(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("request to play URIs failed: %w", err)
+		}
+
+	case Transfer:
+		err := cli.TransferPlayback(ctx, ev.Device, false)
+		if err != nil {
+			return fmt.Errorf("request to transfer playback failed: %w", err)
+		}
+
+	default:
+		return fmt.Errorf("unhandled event: %s", debugEvent(ev.Type))
 	}
 
+	return nil
+}
+
+// Manager for player events. The player state is refreshed after each event.
+func EventsManager(ctx context.Context, cli *spotify.Client, dev spotify.ID, ch <-chan *Event, state *StateWatcher) {
 	for ev := range ch {
-		switch ev.Type {
-		case PlayURI:
-			opts := &spotify.PlayOptions{
-				DeviceID: &dev,
-				URIs: []spotify.URI{ev.URI},
-			}
-			err := cli.PlayOpt(ctx, opts)
-			if err != nil {
-				log.WithError(err).Error("request to play URI failed")
-			}
-	
-		case QueueURI:
-			suri := string(ev.URI)
-			sid := strings.Replace(suri, "spotify:track:", "", 1)
-			id := spotify.ID(sid)
-	
-			err := cli.QueueSong(ctx, id)
-			if err != nil {
-				log.WithError(err).Error("request to queue URI failed")
-			}
-	
-		case Play:
-			handlePlayEvent(ctx, cli)
-	
-		case Pause:
-			handlePauseEvent(ctx, cli)
-	
-		case Toggle:
-			status, err := cli.PlayerCurrentlyPlaying(ctx)
-			if err != nil {
-				log.WithError(err).Error("request to determine playback status, so assuming that status is playing")
-				handlePauseEvent(ctx, cli)
-			} else {
-				if status.Playing {
-					handlePauseEvent(ctx, cli)
-				} else {
-					handlePlayEvent(ctx, cli)
-				}
-			}
-
-		case PlayNext:
-			err := cli.Next(ctx)
-			if err != nil {
-				log.WithError(err).Error("request to play next failed")
-			}
-	
-		case PlayPrevious:
-			err := cli.Previous(ctx)
-			if err != nil {
-				log.WithError(err).Error("request to play previous failed")
-			}
-	
-		default:
-			log.Errorf("unhandled event: %s", debugEvent(ev.Type))
+		err := HandleEvent(ctx, cli, dev, ev)
+		if err != nil {
+			log.Error(err)
+		} else if ev.Type == Transfer {
+			// Later events target the new device.
+			dev = ev.Device
 		}
+
+		state.Refresh()
 	}
 
 	log.Trace("no more events, terminating events manager")
 }
-
@@ -0,0 +1,426 @@
+package main
+
+// Exporting the library (or a playlist) to a file, for backups and audits.
+// Items are streamed from Spotify as they are fetched, so that exporting a
+// large library does not hold it in memory.
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+)
+
+// An exported item.
+type ExportedItem struct {
+	Name     string   `json:"name"`
+	Artists  []string `json:"artists"`
+	Album    string   `json:"album,omitempty"`
+	Duration int      `json:"duration_ms"`
+	ISRC     string   `json:"isrc,omitempty"`
+	URI      string   `json:"uri"`
+	AddedAt  string   `json:"added_at,omitempty"`
+}
+
+// Convert an item for export.
+func ExportItem(item *Item) *ExportedItem {
+	exported := &ExportedItem{
+		Name: item.Name(),
+		Album: item.Album(),
+		Duration: item.Duration(),
+		ISRC: item.ISRC(),
+		URI: string(item.URI()),
+		AddedAt: item.AddedAt,
+	}
+
+	if item.Episode != nil {
+		exported.Artists = []string{item.Episode.Show.Name}
+	} else {
+		for _, artist := range item.Track.Artists {
+			exported.Artists = append(exported.Artists, artist.Name)
+		}
+	}
+
+	return exported
+}
+
+// Writer of an export format. `Begin` is called before any items, and `End`
+// after every item.
+type exportWriter interface {
+	Begin(title string) error
+	Write(item *ExportedItem) error
+	End() error
+}
+
+// Export formats, by name.
+var exportFormats = map[string]func(w io.Writer) exportWriter{
+	"csv": func(w io.Writer) exportWriter {
+		return &csvExport{w: csv.NewWriter(w)}
+	},
+	"jsonl": func(w io.Writer) exportWriter {
+		return &jsonlExport{w: json.NewEncoder(w)}
+	},
+	"m3u": func(w io.Writer) exportWriter {
+		return &m3uExport{w: w}
+	},
+	"xspf": func(w io.Writer) exportWriter {
+		return &xspfExport{w: w}
+	},
+}
+
+// Get the names of the export formats.
+func ExportFormats() []string {
+	names := []string{}
+	for name := range exportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Check that an export format is known.
+func checkExportFormat(format string) error {
+	if _, ok := exportFormats[format]; !ok {
+		return fmt.Errorf("unknown export format: %s (try one of: %s)", format, strings.Join(ExportFormats(), ", "))
+	}
+	return nil
+}
+
+// Columns of a CSV export.
+var csvColumns = []string{"name", "artists", "album", "duration_ms", "isrc", "uri", "added_at"}
+
+// CSV, with a header. Artists are `;`-delimited.
+type csvExport struct {
+	w *csv.Writer
+}
+
+func (e *csvExport) Begin(_ string) error {
+	return e.w.Write(csvColumns)
+}
+
+func (e *csvExport) Write(item *ExportedItem) error {
+	return e.w.Write([]string{
+		item.Name,
+		strings.Join(item.Artists, "; "),
+		item.Album,
+		strconv.Itoa(item.Duration),
+		item.ISRC,
+		item.URI,
+		item.AddedAt,
+	})
+}
+
+func (e *csvExport) End() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// JSON Lines, one object per item.
+type jsonlExport struct {
+	w *json.Encoder
+}
+
+func (e *jsonlExport) Begin(_ string) error {
+	return nil
+}
+
+func (e *jsonlExport) Write(item *ExportedItem) error {
+	return e.w.Encode(item)
+}
+
+func (e *jsonlExport) End() error {
+	return nil
+}
+
+// Extended M3U. Each item is located by its URI.
+type m3uExport struct {
+	w io.Writer
+}
+
+// Flatten text onto a single line of an M3U file.
+func m3uLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func (e *m3uExport) Begin(title string) error {
+	_, err := fmt.Fprintf(e.w, "#EXTM3U\n#PLAYLIST:%s\n", m3uLine(title))
+	return err
+}
+
+func (e *m3uExport) Write(item *ExportedItem) error {
+	_, err := fmt.Fprintf(e.w, "#EXTINF:%d,%s - %s\n", item.Duration/1000, m3uLine(strings.Join(item.Artists, ", ")), m3uLine(item.Name))
+	if err == nil && item.Album != "" {
+		_, err = fmt.Fprintf(e.w, "#EXTALB:%s\n", m3uLine(item.Album))
+	}
+	if err == nil {
+		_, err = fmt.Fprintln(e.w, item.URI)
+	}
+	return err
+}
+
+func (e *m3uExport) End() error {
+	return nil
+}
+
+// Application of XSPF extensions.
+const xspfApplication = "https://git.dominic-ricottone.com/~dricottone/nspotify"
+
+// A track of an XSPF playlist. The ISRC is an identifier like `isrc:[ISRC]`,
+// and the added date is an extension.
+type xspfTrack struct {
+	XMLName     xml.Name       `xml:"track"`
+	Location    string         `xml:"location"`
+	Identifiers []string       `xml:"identifier"`
+	Title       string         `xml:"title"`
+	Creator     string         `xml:"creator"`
+	Album       string         `xml:"album,omitempty"`
+	Duration    int            `xml:"duration"`
+	Extension   *xspfExtension `xml:"extension,omitempty"`
+}
+
+// Extension of an XSPF track.
+type xspfExtension struct {
+	Application string `xml:"application,attr"`
+	AddedAt     string `xml:"added"`
+}
+
+// XSPF. Each track is located by its link, and identified by its URI.
+type xspfExport struct {
+	w io.Writer
+}
+
+func (e *xspfExport) Begin(title string) error {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(title))
+
+	_, err := fmt.Fprintf(e.w, "%s<playlist version=\"1\" xmlns=\"http://xspf.org/ns/0/\">\n  <title>%s</title>\n  <trackList>\n", xml.Header, escaped.String())
+	return err
+}
+
+func (e *xspfExport) Write(item *ExportedItem) error {
+	track := &xspfTrack{
+		Location: item.URI,
+		Identifiers: []string{item.URI},
+		Title: item.Name,
+		Creator: strings.Join(item.Artists, ", "),
+		Album: item.Album,
+		Duration: item.Duration,
+	}
+	if uri, err := ParseURI(item.URI); err == nil {
+		track.Location = uri.Link()
+	}
+	if item.ISRC != "" {
+		track.Identifiers = append(track.Identifiers, "isrc:"+item.ISRC)
+	}
+	if item.AddedAt != "" {
+		track.Extension = &xspfExtension{xspfApplication, item.AddedAt}
+	}
+
+	out, err := xml.MarshalIndent(track, "    ", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.w, "%s\n", out)
+	return err
+}
+
+func (e *xspfExport) End() error {
+	_, err := fmt.Fprint(e.w, "  </trackList>\n</playlist>\n")
+	return err
+}
+
+// Export the end user's saved tracks, or a playlist, in a format. Returns the
+// number of items exported. Failing to fetch any of the items fails the
+// export, since a partial backup is worse than none.
+func Export(ctx context.Context, cli *spotify.Client, format string, w io.Writer, playlist *spotify.SimplePlaylist) (int, error) {
+	if err := checkExportFormat(format); err != nil {
+		return 0, err
+	}
+	e := exportFormats[format](w)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	title := "Liked Songs"
+	ch := make(chan *Item, fetchingBuffer)
+	failed := make(chan error, 1)
+	if playlist == nil {
+		go func() {
+			failed <- FetchSavedTracks(ctx, cli, ch)
+		}()
+	} else {
+		title = playlist.Name
+		go func() {
+			failed <- FetchPlaylistItems(ctx, cli, playlist, ch)
+		}()
+	}
+
+	// Stop fetching on failure, and drain the channel so that the
+	// fetcher can terminate.
+	fail := func(n int, err error) (int, error) {
+		cancel()
+		for range ch {
+		}
+		return n, fmt.Errorf("failed to export: %w", err)
+	}
+
+	if err := e.Begin(title); err != nil {
+		return fail(0, err)
+	}
+
+	n := 0
+	for item := range ch {
+		if err := e.Write(ExportItem(item)); err != nil {
+			return fail(n, err)
+		}
+		n++
+	}
+
+	if err := <-failed; err != nil {
+		return n, fmt.Errorf("failed to export: %w", err)
+	}
+
+	if err := e.End(); err != nil {
+		return n, fmt.Errorf("failed to export: %w", err)
+	}
+
+	return n, nil
+}
+
+// Get the mode of an export file: the mode of the file that it replaces, or
+// else the mode of a new file. Temporary files are only readable by the end
+// user, which would not suit exports that are shared.
+func exportMode(path string) os.FileMode {
+	if info, err := os.Stat(path); err == nil {
+		return info.Mode().Perm()
+	}
+
+	umask := syscall.Umask(0)
+	syscall.Umask(umask)
+	return 0666 &^ os.FileMode(umask)
+}
+
+// Export to a file, or to STDOUT if the path is `-` or empty. See `Export`.
+func ExportFile(ctx context.Context, cli *spotify.Client, format, path string, playlist *spotify.SimplePlaylist) (int, error) {
+	if path == "" || path == "-" {
+		w := bufio.NewWriter(os.Stdout)
+		n, err := Export(ctx, cli, format, w, playlist)
+		if err == nil {
+			err = w.Flush()
+		}
+		return n, err
+	}
+
+	// Write to a temporary file beside the export, and replace the export
+	// only once it is complete.
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to export: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	n, err := Export(ctx, cli, format, w, playlist)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Chmod(exportMode(path))
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return n, err
+	}
+
+	return n, nil
+}
+
+// Look up a playlist by ID, e.g. to export it.
+func LookupPlaylist(ctx context.Context, cli *spotify.Client, id spotify.ID) (*spotify.SimplePlaylist, error) {
+	playlist, err := cli.GetPlaylist(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch playlist: %w", err)
+	}
+
+	return &playlist.SimplePlaylist, nil
+}
+
+// Export as configured by `-export`, `-o`, and `-playlist`.
+func RunExport(ctx context.Context, cli *spotify.Client, cfg *Config) error {
+	var playlist *spotify.SimplePlaylist
+	if cfg.ExportPlaylist != "" {
+		var err error
+		playlist, err = LookupPlaylist(ctx, cli, cfg.ExportPlaylist)
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := ExportFile(ctx, cli, cfg.Export, cfg.ExportFile, playlist)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("exported %d items", n)
+	return nil
+}
+
+// Export from the command line, e.g. `export csv library.csv`. Exports the
+// playlist that is shown or selected, or else the saved tracks. The export
+// runs in the background.
+func (ui *UI) exportItems(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("export: usage: export FORMAT FILE")
+	}
+	format, path := args[0], args[1]
+	if err := checkExportFormat(format); err != nil {
+		return err
+	}
+
+	var playlist *spotify.SimplePlaylist
+	switch page, _ := ui.pages.GetFrontPage(); page {
+	case "playlist":
+		playlist = ui.shownPlaylist
+	case "playlists":
+		playlist, _ = ui.selectedReference().(*spotify.SimplePlaylist)
+	}
+
+	description := "saved tracks"
+	if playlist != nil {
+		description = playlist.Name
+	}
+	ui.message(fmt.Sprintf("exporting %s to %s...", description, path))
+
+	go func() {
+		n, err := ExportFile(ui.ctx, ui.cli, format, path, playlist)
+		if err != nil {
+			log.WithError(err).Error("failed to export")
+			return
+		}
+
+		ui.app.QueueUpdateDraw(func() {
+			ui.message(fmt.Sprintf("exported %d items to %s", n, path))
+		})
+	}()
+
+	return nil
+}
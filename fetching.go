@@ -4,6 +4,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -11,7 +12,7 @@ import (
 )
 
 // Actually fetch tracks from Spotify.
-func fetchingWorker(ctx context.Context, cli *spotify.Client, ch chan<- *spotify.FullTrack, done chan<- bool) {
+func fetchingWorker(ctx context.Context, cli *spotify.Client, ch chan<- *Item, done chan<- bool) {
 	// Fetch first page.
 	log.Trace("fetching first page...")
 	page, err := cli.CurrentUsersTracks(ctx)
@@ -26,21 +27,22 @@ func fetchingWorker(ctx context.Context, cli *spotify.Client, ch chan<- *spotify
 	log.Trace("fetched first page")
 
 	// Fetch additional pages.
+fetching:
 	for {
 		select {
 
 		// Context is cancelled.
 		case <-ctx.Done():
-			break
+			break fetching
 
 		// Continue fetching.
 		default:
-			for _, track := range page.Tracks {
+			for i := range page.Tracks {
 				// If the channel buffer is full, this will
 				// block. This is intentional. Manager will
 				// ensure that the buffer is emptied if the
 				// worker needs to terminate.
-				ch <- &track.FullTrack
+				ch <- TrackItem(&page.Tracks[i])
 			}
 
 			log.Trace("fetching a new page...")
@@ -49,13 +51,13 @@ func fetchingWorker(ctx context.Context, cli *spotify.Client, ch chan<- *spotify
 			// Reached end of pages.
 			if err == spotify.ErrNoMorePages {
 				log.Debug("no more pages")
-				break
+				break fetching
 			}
 
 			// Other error?
 			if err != nil {
 				log.WithError(err).Error("failed to fetch a page")
-				break
+				break fetching
 			}
 		}
 
@@ -67,8 +69,10 @@ func fetchingWorker(ctx context.Context, cli *spotify.Client, ch chan<- *spotify
 }
 
 // Manage fetching tracks from Spotify.
-func FetchingManager(ctx context.Context, cli *spotify.Client, ch chan *spotify.FullTrack) {
-	done := make(chan bool)
+func FetchingManager(ctx context.Context, cli *spotify.Client, ch chan *Item) {
+	// Buffered so that the worker can signal termination even while the
+	// manager is draining the channel.
+	done := make(chan bool, 1)
 	go fetchingWorker(ctx, cli, ch, done)
 
 	for {
@@ -77,7 +81,7 @@ func FetchingManager(ctx context.Context, cli *spotify.Client, ch chan *spotify.
 		// Fetch worker is terminating faster than the manager.
 		case <-done:
 			log.Trace("fetch worker terminated, terminating fetch manager")
-			break
+			return
 
 		// Context is cancelled.
 		case <-ctx.Done():
@@ -88,8 +92,11 @@ func FetchingManager(ctx context.Context, cli *spotify.Client, ch chan *spotify.
 			for _ = range ch {
 			}
 
+			// Block until worker is cleaned up.
+			<-done
+
 			log.Trace("cleanup complete, terminating fetch manager")
-			break
+			return
 
 		// Wait before re-checking the above situations.
 		default:
@@ -98,8 +105,59 @@ func FetchingManager(ctx context.Context, cli *spotify.Client, ch chan *spotify.
 		}
 
 	}
+}
+
+// Fetch the end user's saved tracks, sending them through the channel as they
+// are fetched. Unlike the fetching manager, a failure to fetch any page is
+// returned, so that callers never act on a partial library. The channel is
+// closed once there are no more tracks, on failure, or once the context is
+// cancelled.
+func FetchSavedTracks(ctx context.Context, cli *spotify.Client, ch chan<- *Item) error {
+	defer close(ch)
+
+	log.Trace("fetching first page of saved tracks...")
+	page, err := cli.CurrentUsersTracks(ctx, spotify.Limit(50))
+	if err != nil {
+		return fmt.Errorf("failed to fetch saved tracks: %w", err)
+	}
+
+	for {
+		for i := range page.Tracks {
+			select {
+			case ch <- TrackItem(&page.Tracks[i]):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
 
-	// Block until worker is cleaned up.
-	<- done
+		log.Trace("fetching a new page of saved tracks...")
+		err = cli.NextPage(ctx, page)
+		if err == spotify.ErrNoMorePages {
+			log.Debug("no more pages of saved tracks")
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch saved tracks: %w", err)
+		}
+	}
 }
 
+// Fetch every one of the end user's saved tracks, e.g. to find duplicates.
+func FetchLibrary(ctx context.Context, cli *spotify.Client) ([]*Item, error) {
+	ch := make(chan *Item, fetchingBuffer)
+	failed := make(chan error, 1)
+	go func() {
+		failed <- FetchSavedTracks(ctx, cli, ch)
+	}()
+
+	items := []*Item{}
+	for item := range ch {
+		items = append(items, item)
+	}
+
+	if err := <-failed; err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
@@ -2,18 +2,23 @@ module git.dominic-ricottone.com/~dricottone/nspotify
 
 go 1.22.1
 
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/gdamore/tcell/v2 v2.7.1
+	github.com/godbus/dbus/v5 v5.1.0
+	github.com/rivo/tview v0.0.0-20240403142647-a22293bda944
+	github.com/sirupsen/logrus v1.9.3
+	github.com/zmb3/spotify/v2 v2.4.1
+	golang.org/x/net v0.22.0
+	golang.org/x/oauth2 v0.18.0
+)
+
 require (
 	github.com/gdamore/encoding v1.0.0 // indirect
-	github.com/gdamore/tcell/v2 v2.7.1 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
 	github.com/mattn/go-runewidth v0.0.15 // indirect
-	github.com/rivo/tview v0.0.0-20240403142647-a22293bda944 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
-	github.com/sirupsen/logrus v1.9.3 // indirect
-	github.com/zmb3/spotify/v2 v2.4.1 // indirect
-	golang.org/x/net v0.22.0 // indirect
-	golang.org/x/oauth2 v0.18.0 // indirect
 	golang.org/x/sys v0.18.0 // indirect
 	golang.org/x/term v0.18.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
@@ -0,0 +1,221 @@
+package main
+
+// Grouped views of a table of items, e.g. by artist, then by album. Groups
+// can be collapsed, and show how many items they hold and for how long.
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
+)
+
+// A level of grouping, which gets the key and title of an item's group.
+type groupLevel func(item *Item) (key, title string)
+
+// Group items by the artists of their album, or by show for episodes.
+func byArtist(item *Item) (string, string) {
+	if item.Episode != nil {
+		return string(item.Episode.Show.URI), item.Episode.Show.Name
+	}
+	if len(item.Track.Album.Artists) == 0 {
+		return item.Artist(), item.Artist()
+	}
+
+	artists := FormatArtists(item.Track.Album.Artists)
+	return artists, artists
+}
+
+// Group items by album, or by show for episodes.
+func byAlbum(item *Item) (string, string) {
+	if item.Episode != nil {
+		return string(item.Episode.Show.URI), item.Episode.Show.Name
+	}
+	return string(item.Track.Album.URI), item.Track.Album.Name
+}
+
+// Ways to group items, by name. `flat` is not grouped.
+var itemGroupings = map[string][]groupLevel{
+	"flat": nil,
+	"artist": {byArtist, byAlbum},
+	"album": {byAlbum},
+}
+
+// Names of the ways to group items, in the order that they are cycled
+// through.
+var itemGroupingNames = []string{"flat", "artist", "album"}
+
+// Get the names of the ways to group items.
+func ItemGroupings() []string {
+	return slices.Clone(itemGroupingNames)
+}
+
+// Get the way to group items after another, wrapping around.
+func nextGrouping(grouping string) string {
+	i := slices.Index(itemGroupingNames, grouping)
+	return itemGroupingNames[(i+1)%len(itemGroupingNames)]
+}
+
+// A group of rows, e.g. the tracks of an album. Only the last level of
+// groups holds rows; the others hold groups.
+type itemGroup struct {
+	key   string
+	title string
+	depth int
+
+	groups []*itemGroup
+	byKey  map[string]*itemGroup
+	rows   []*itemRow
+
+	count    int
+	duration int
+}
+
+// Get every item of a group, in order.
+func (g *itemGroup) Items() []*Item {
+	items := []*Item{}
+	for _, row := range g.rows {
+		items = append(items, row.item)
+	}
+	for _, group := range g.groups {
+		items = append(items, group.Items()...)
+	}
+
+	return items
+}
+
+// Get the URIs of every item of a group, in order.
+func (g *itemGroup) URIs() []spotify.URI {
+	uris := []spotify.URI{}
+	for _, item := range g.Items() {
+		uris = append(uris, item.URI())
+	}
+
+	return uris
+}
+
+// Add a row to a group, or to the group below it at the next level.
+func (g *itemGroup) add(row *itemRow, levels []groupLevel) {
+	g.count++
+	g.duration += row.item.Duration()
+
+	if len(levels) == 0 {
+		g.rows = append(g.rows, row)
+		return
+	}
+
+	key, title := levels[0](row.item)
+	if key == "" {
+		key = title
+	}
+	key = g.key + "\n" + key
+
+	sub, ok := g.byKey[key]
+	if !ok {
+		sub = &itemGroup{
+			key: key,
+			title: title,
+			depth: g.depth + 1,
+			byKey: map[string]*itemGroup{},
+		}
+		g.byKey[key] = sub
+		g.groups = append(g.groups, sub)
+	}
+
+	sub.add(row, levels[1:])
+}
+
+// Sort the groups under a group by title. Rows are kept in order, unless
+// `albumOrder` in which case they are sorted by disc and track number.
+func (g *itemGroup) sort(albumOrder bool) {
+	slices.SortStableFunc(g.groups, func(a, b *itemGroup) int {
+		return compareFold(a.title, b.title)
+	})
+	for _, group := range g.groups {
+		group.sort(albumOrder)
+	}
+
+	if albumOrder {
+		slices.SortStableFunc(g.rows, func(a, b *itemRow) int {
+			return compareTrackNumber(a.item, b.item)
+		})
+	}
+}
+
+// Compare items by disc and track number. Episodes have neither.
+func compareTrackNumber(a, b *Item) int {
+	if a.Track == nil || b.Track == nil {
+		return 0
+	}
+	if result := cmp.Compare(a.Track.DiscNumber, b.Track.DiscNumber); result != 0 {
+		return result
+	}
+	return cmp.Compare(a.Track.TrackNumber, b.Track.TrackNumber)
+}
+
+// A line of a grouped table: either a group or a row within its group.
+type itemLine struct {
+	group *itemGroup
+	row   *itemRow
+
+	// Group that the line belongs to, if any.
+	parent *itemGroup
+}
+
+// Flatten groups into lines, skipping the contents of collapsed groups.
+func flattenGroups(g *itemGroup, collapsed map[string]bool, lines []itemLine) []itemLine {
+	for _, group := range g.groups {
+		lines = append(lines, itemLine{group: group, parent: g})
+		if !collapsed[group.key] {
+			lines = flattenGroups(group, collapsed, lines)
+		}
+	}
+	for _, row := range g.rows {
+		lines = append(lines, itemLine{row: row, parent: g})
+	}
+
+	return lines
+}
+
+// Indentation of a line at some depth.
+func indent(depth int) string {
+	return strings.Repeat("  ", depth)
+}
+
+// Create the cells of a group's line, with as many columns as the table.
+// The first cell references the group.
+func groupCells(group *itemGroup, collapsed bool, columns int) []*tview.TableCell {
+	marker := "▾"
+	if collapsed {
+		marker = "▸"
+	}
+
+	count := fmt.Sprintf("%d tracks", group.count)
+	if group.count == 1 {
+		count = "1 track"
+	}
+
+	cells := make([]*tview.TableCell, max(columns, 3))
+	cells[0] = tview.NewTableCell(indent(group.depth-1) + marker + " " + group.title).SetReference(group)
+	cells[1] = tview.NewTableCell(count)
+	for i := 2; i < len(cells)-1; i++ {
+		cells[i] = tview.NewTableCell("")
+	}
+	cells[len(cells)-1] = tview.NewTableCell(FormatDuration(group.duration)).SetAlign(tview.AlignRight)
+
+	return cells
+}
+
+// Get the group referenced by a row of a table.
+func groupAt(table *tview.Table, row int) (*itemGroup, bool) {
+	cell := table.GetCell(row, 0)
+	if cell == nil {
+		return nil, false
+	}
+
+	group, ok := cell.GetReference().(*itemGroup)
+	return group, ok
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Create a row of a track on an album.
+func trackRow(name, artist, album string, duration int) *itemRow {
+	artists := []spotify.SimpleArtist{{Name: artist}}
+	return &itemRow{item: &Item{Track: &spotify.FullTrack{
+		SimpleTrack: spotify.SimpleTrack{
+			Name: name,
+			Duration: duration,
+			Artists: artists,
+		},
+		Album: spotify.SimpleAlbum{
+			Name: album,
+			URI: spotify.URI("spotify:album:" + album),
+			Artists: artists,
+		},
+	}}}
+}
+
+// Describe lines as the titles of groups (indented by depth) and the names
+// of rows.
+func describeLines(lines []itemLine) []string {
+	described := []string{}
+	for _, line := range lines {
+		if line.group != nil {
+			described = append(described, indent(line.group.depth-1)+line.group.title)
+		} else {
+			described = append(described, "- "+line.row.item.Name())
+		}
+	}
+	return described
+}
+
+func TestItemGroupAdd(t *testing.T) {
+	root := &itemGroup{byKey: map[string]*itemGroup{}}
+	rows := []*itemRow{
+		trackRow("One", "Artist", "First", 1000),
+		trackRow("Two", "Other", "Second", 2000),
+		trackRow("Three", "Artist", "Third", 3000),
+		trackRow("Four", "Artist", "First", 4000),
+	}
+	for _, row := range rows {
+		root.add(row, itemGroupings["artist"])
+	}
+
+	if root.count != 4 || root.duration != 10000 {
+		t.Errorf("root holds %d rows for %dms, expected 4 for 10000ms", root.count, root.duration)
+	}
+	if len(root.groups) != 2 {
+		t.Fatalf("root holds %d groups, expected 2", len(root.groups))
+	}
+
+	tests := []struct {
+		group    *itemGroup
+		title    string
+		depth    int
+		count    int
+		duration int
+		groups   int
+		rows     int
+	}{
+		{root.groups[0], "Artist", 1, 3, 8000, 2, 0},
+		{root.groups[0].groups[0], "First", 2, 2, 5000, 0, 2},
+		{root.groups[0].groups[1], "Third", 2, 1, 3000, 0, 1},
+		{root.groups[1], "Other", 1, 1, 2000, 1, 0},
+		{root.groups[1].groups[0], "Second", 2, 1, 2000, 0, 1},
+	}
+
+	for _, tt := range tests {
+		g := tt.group
+		if g.title != tt.title || g.depth != tt.depth {
+			t.Errorf("group %q at depth %d, expected %q at depth %d", g.title, g.depth, tt.title, tt.depth)
+		}
+		if g.count != tt.count || g.duration != tt.duration {
+			t.Errorf("group %q holds %d rows for %dms, expected %d for %dms", g.title, g.count, g.duration, tt.count, tt.duration)
+		}
+		if len(g.groups) != tt.groups || len(g.rows) != tt.rows {
+			t.Errorf("group %q holds %d groups and %d rows, expected %d and %d", g.title, len(g.groups), len(g.rows), tt.groups, tt.rows)
+		}
+	}
+
+	// Albums of the same name by different artists are kept apart.
+	root.add(trackRow("Five", "Other", "First", 5000), itemGroupings["artist"])
+	if first := root.groups[1].groups[1]; first.title != "First" || first.count != 1 {
+		t.Errorf("album of another artist was grouped as %q holding %d rows", first.title, first.count)
+	}
+}
+
+func TestFlattenGroups(t *testing.T) {
+	root := &itemGroup{byKey: map[string]*itemGroup{}}
+	for _, row := range []*itemRow{
+		trackRow("One", "Artist", "First", 1000),
+		trackRow("Two", "Other", "Second", 2000),
+		trackRow("Three", "Artist", "Third", 3000),
+	} {
+		root.add(row, itemGroupings["artist"])
+	}
+
+	artist := root.groups[0]
+	tests := []struct {
+		collapsed map[string]bool
+		want      []string
+	}{
+		{
+			nil,
+			[]string{"Artist", "  First", "- One", "  Third", "- Three", "Other", "  Second", "- Two"},
+		},
+		{
+			map[string]bool{artist.key: true},
+			[]string{"Artist", "Other", "  Second", "- Two"},
+		},
+		{
+			map[string]bool{artist.groups[0].key: true},
+			[]string{"Artist", "  First", "  Third", "- Three", "Other", "  Second", "- Two"},
+		},
+	}
+
+	for _, tt := range tests {
+		lines := flattenGroups(root, tt.collapsed, nil)
+		got := describeLines(lines)
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("flattened to %q, expected %q", got, tt.want)
+		}
+
+		for _, line := range lines {
+			if line.row != nil && line.parent.count == 0 {
+				t.Errorf("row %q has no parent group", line.row.item.Name())
+			}
+		}
+	}
+
+	// Ungrouped rows are flattened in order.
+	flat := &itemGroup{byKey: map[string]*itemGroup{}}
+	flat.add(trackRow("One", "Artist", "First", 1000), nil)
+	flat.add(trackRow("Two", "Other", "Second", 2000), nil)
+	if got := describeLines(flattenGroups(flat, nil, nil)); strings.Join(got, "\n") != "- One\n- Two" {
+		t.Errorf("flattened to %q, expected the rows", got)
+	}
+}
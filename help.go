@@ -0,0 +1,109 @@
+package main
+
+// Help page, listing every action with its command, current bindings, and
+// description.
+
+import (
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// Help page. A search field filters a table of actions.
+type HelpPage struct {
+	*tview.Flex
+	search *tview.InputField
+	table  *tview.Table
+	keymap *Keymap
+	theme  *Theme
+}
+
+// Check if an action matches a search, by name, bindings, or description.
+func matchAction(action *Action, bindings []string, query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return true
+	}
+
+	text := actionCommand(action) + " " + strings.Join(bindings, " ") + " " + action.Description
+	return strings.Contains(strings.ToLower(text), query)
+}
+
+// Format how an action is run on the command line, e.g. `quit (q)` or
+// `volume PERCENT`.
+func actionCommand(action *Action) string {
+	command := action.Name
+	if action.Args != "" {
+		command += " " + action.Args
+	}
+	if len(action.Aliases) != 0 {
+		command += " (" + strings.Join(action.Aliases, ", ") + ")"
+	}
+
+	return command
+}
+
+// Create the help page for a keymap, styled by a theme.
+func NewHelpPage(km *Keymap, theme *Theme) *HelpPage {
+	help := &HelpPage{
+		Flex: tview.NewFlex().SetDirection(tview.FlexRow),
+		search: tview.NewInputField().SetLabel("Search: "),
+		table: theme.ApplyTable(tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)),
+		keymap: km,
+		theme: theme,
+	}
+
+	help.search.SetChangedFunc(help.Filter)
+	help.Filter("")
+
+	note := "Type a count before keys, e.g. 5j, or after a command, e.g. :next 3."
+	if !km.Counts() {
+		note = "Number keys switch tabs, so type a count after a command, e.g. :next 3 (or use keymap = \"vim\" for counts like 5j)."
+	}
+
+	help.AddItem(help.search, 1, 0, true)
+	help.AddItem(help.table, 0, 1, false)
+	help.AddItem(tview.NewTextView().SetText(note), 1, 0, false)
+
+	return help
+}
+
+// List the actions that match a search.
+func (help *HelpPage) Filter(query string) {
+	help.table.Clear()
+
+	for col, header := range []string{"Command", "Keys", "Description"} {
+		help.table.SetCell(0, col, tview.NewTableCell(header).SetStyle(help.theme.Header).SetSelectable(false))
+	}
+
+	row := 1
+	for _, action := range actions {
+		bindings := help.keymap.Bindings(action.Name)
+		if !matchAction(action, bindings, query) {
+			continue
+		}
+
+		keys := strings.Join(bindings, ", ")
+		if keys == "" && action.Run != nil {
+			keys = "(unbound)"
+		}
+
+		help.table.SetCell(row, 0, tview.NewTableCell(":" + actionCommand(action)).SetReference(action))
+		help.table.SetCell(row, 1, tview.NewTableCell(keys))
+		help.table.SetCell(row, 2, tview.NewTableCell(action.Description).SetExpansion(1))
+		row++
+	}
+
+	help.table.Select(1, 0).ScrollToBeginning()
+}
+
+// Get the action in a row.
+func (help *HelpPage) ActionAt(row int) (*Action, bool) {
+	cell := help.table.GetCell(row, 0)
+	if cell == nil {
+		return nil, false
+	}
+
+	action, ok := cell.GetReference().(*Action)
+	return action, ok
+}
@@ -0,0 +1,559 @@
+package main
+
+// Play history: every change of the playing item observed by polling the
+// player state, appended to a local file as JSON lines. Unlike the Spotify
+// Web API's recently played tracks, which only reach back 50 tracks, the file
+// keeps every play. Plays missed while nspotify was not running are filled in
+// from the recently played tracks.
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	// Sources of plays in the history.
+	historyFromPlayer = "player"
+	historyFromRecent = "recently-played"
+
+	// Slack (in milliseconds) for the time between polls of the player
+	// state, and for matching plays from different sources.
+	historySlack = 1000
+
+	// Interval (in minutes) between filling in plays from the recently
+	// played tracks.
+	historyBackfillInterval = 30
+)
+
+// A play of an item.
+type HistoryEntry struct {
+	// Time that the play started, and that it was last observed.
+	Started time.Time `json:"started"`
+	Ended   time.Time `json:"ended"`
+
+	URI       spotify.URI  `json:"uri"`
+	Name      string       `json:"name"`
+	Artists   []string     `json:"artists,omitempty"`
+	ArtistIDs []spotify.ID `json:"artist_ids,omitempty"`
+	Album     string       `json:"album,omitempty"`
+	Show      string       `json:"show,omitempty"`
+	Duration  int          `json:"duration_ms"`
+
+	// How much of the item was heard, in milliseconds. Plays filled in
+	// from the recently played tracks are assumed to be heard in full.
+	Heard int `json:"heard_ms"`
+
+	// Device that played the item, if known.
+	Device   string     `json:"device,omitempty"`
+	DeviceID spotify.ID `json:"device_id,omitempty"`
+
+	// Context that the item played from, if any.
+	Context spotify.URI `json:"context,omitempty"`
+
+	// Where the play was observed: `player` or `recently-played`.
+	Source string `json:"source"`
+}
+
+// Create a play of the item in a snapshot of the player state.
+func newHistoryEntry(np *NowPlaying) *HistoryEntry {
+	item := np.Item
+	entry := &HistoryEntry{
+		Started: np.Fetched.Add(-time.Duration(np.Progress) * time.Millisecond),
+		Ended: np.Fetched,
+		URI: item.URI(),
+		Name: item.Name(),
+		Duration: item.Duration(),
+		Device: np.Device.Name,
+		DeviceID: np.Device.ID,
+		Context: np.Context.URI,
+		Source: historyFromPlayer,
+	}
+
+	if item.Episode != nil {
+		entry.Show = item.Episode.Show.Name
+	} else {
+		for _, artist := range item.Track.Artists {
+			entry.Artists = append(entry.Artists, artist.Name)
+			entry.ArtistIDs = append(entry.ArtistIDs, artist.ID)
+		}
+		entry.Album = item.Track.Album.Name
+	}
+
+	return entry
+}
+
+// Create a play of a recently played track.
+func recentHistoryEntry(recent *spotify.RecentlyPlayedItem) *HistoryEntry {
+	track := &recent.Track
+	entry := &HistoryEntry{
+		Started: recent.PlayedAt.Add(-time.Duration(track.Duration) * time.Millisecond).Local(),
+		Ended: recent.PlayedAt.Local(),
+		URI: track.URI,
+		Name: track.Name,
+		Album: track.Album.Name,
+		Duration: track.Duration,
+		Heard: track.Duration,
+		Context: recent.PlaybackContext.URI,
+		Source: historyFromRecent,
+	}
+
+	for _, artist := range track.Artists {
+		entry.Artists = append(entry.Artists, artist.Name)
+		entry.ArtistIDs = append(entry.ArtistIDs, artist.ID)
+	}
+
+	return entry
+}
+
+// Describe a play, like `Artist - Title`.
+func (entry *HistoryEntry) String() string {
+	by := entry.Show
+	if by == "" {
+		by = strings.Join(entry.Artists, ", ")
+	}
+	return by + " – " + entry.Name
+}
+
+// Check if two plays of the same item overlap in time.
+func (entry *HistoryEntry) overlaps(other *HistoryEntry) bool {
+	slack := historySlack * time.Millisecond
+	return entry.URI == other.URI && entry.Started.Before(other.Ended.Add(slack)) && other.Started.Before(entry.Ended.Add(slack))
+}
+
+// Open the history file, holding an exclusive lock on it until it is closed.
+// Every writer (e.g. a subcommand and a running interface) takes the lock,
+// so that a play is never checked for and appended by two at once.
+func lockHistory(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock history: %w", err)
+	}
+
+	return file, nil
+}
+
+// Append plays to a locked history file.
+func appendHistory(file *os.File, entries ...*HistoryEntry) error {
+	// Each play is written in a single call, so that a crash never leaves
+	// more than one line cut short.
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write history: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Append plays to the history file.
+func AppendHistory(path string, entries ...*HistoryEntry) error {
+	file, err := lockHistory(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return appendHistory(file, entries...)
+}
+
+// Read every play from a history file, in the order they started. Lines that
+// cannot be parsed (e.g. a line cut short by a crash) are skipped.
+func readHistory(file *os.File) ([]*HistoryEntry, error) {
+	entries := []*HistoryEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		entry := &HistoryEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			log.WithError(err).Warnf("skipping line %d of %s", n, file.Name())
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	slices.SortStableFunc(entries, func(a, b *HistoryEntry) int {
+		return a.Started.Compare(b.Started)
+	})
+
+	return entries, nil
+}
+
+// Read every play in the history file, in the order they started. A missing
+// file is an empty history.
+func ReadHistory(path string) ([]*HistoryEntry, error) {
+	if path == "" {
+		return nil, fmt.Errorf("play history is disabled")
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []*HistoryEntry{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	return readHistory(file)
+}
+
+// Fill in plays from the recently played tracks that are not yet in the
+// history file, e.g. because nspotify was not running. Returns the number of
+// plays added.
+func BackfillHistory(ctx context.Context, cli *spotify.Client, path string) (int, error) {
+	if path == "" {
+		return 0, fmt.Errorf("play history is disabled")
+	}
+
+	recent, err := cli.PlayerRecentlyPlayedOpt(ctx, &spotify.RecentlyPlayedOptions{Limit: 50})
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch recently played tracks: %w", err)
+	}
+
+	// Hold the lock from reading the history until the missing plays are
+	// appended.
+	file, err := lockHistory(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	entries, err := readHistory(file)
+	if err != nil {
+		return 0, err
+	}
+
+	missing := []*HistoryEntry{}
+	for i := range recent {
+		entry := recentHistoryEntry(&recent[i])
+		if !slices.ContainsFunc(entries, entry.overlaps) {
+			missing = append(missing, entry)
+		}
+	}
+
+	// Recently played tracks are listed from the latest.
+	slices.Reverse(missing)
+
+	return len(missing), appendHistory(file, missing...)
+}
+
+// A range of time to list plays from. Either end may be unbounded (zero).
+type HistoryRange struct {
+	Since time.Time
+	Until time.Time
+}
+
+// Check if a play started within a range.
+func (r HistoryRange) Contains(entry *HistoryEntry) bool {
+	return (r.Since.IsZero() || !entry.Started.Before(r.Since)) && (r.Until.IsZero() || entry.Started.Before(r.Until))
+}
+
+// Layouts of times accepted by `parseHistoryTime`, with whether they are
+// dates.
+var historyTimeLayouts = []struct {
+	layout string
+	date   bool
+}{
+	{"2006-01-02", true},
+	{"2006-01-02T15:04", false},
+	{"2006-01-02 15:04", false},
+	{time.RFC3339, false},
+}
+
+// Parse a time relative to now: `today`, `yesterday`, a date like
+// `2024-01-31`, a time like `2024-01-31T15:04`, or an age like `7d`, `2w`, or
+// `12h`. Days start at midnight, or end at midnight if `end` is set, so that
+// ranges of days are inclusive.
+func parseHistoryTime(value string, now time.Time, end bool) (time.Time, error) {
+	day := func(t time.Time) time.Time {
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		if end {
+			return start.AddDate(0, 0, 1)
+		}
+		return start
+	}
+
+	switch value {
+	case "today":
+		return day(now), nil
+	case "yesterday":
+		return day(now.AddDate(0, 0, -1)), nil
+	}
+
+	for _, layout := range historyTimeLayouts {
+		if t, err := time.ParseInLocation(layout.layout, value, now.Location()); err == nil {
+			if layout.date {
+				return day(t), nil
+			}
+			return t, nil
+		}
+	}
+
+	// Ages in days or weeks have exactly one suffix, e.g. `7d` but not
+	// `7dd`.
+	for suffix, days := range map[string]int{"d": 1, "w": 7} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			if n, err := strconv.Atoi(number); err == nil && n >= 0 {
+				return now.AddDate(0, 0, -days*n), nil
+			}
+		}
+	}
+	if age, err := time.ParseDuration(value); err == nil && age >= 0 {
+		return now.Add(-age), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time: %s (try a date like 2024-01-31, today, yesterday, or an age like 7d)", value)
+}
+
+// Parse the arguments `[SINCE [UNTIL]]` into a range of time.
+func ParseHistoryRange(args []string, now time.Time) (HistoryRange, error) {
+	r := HistoryRange{}
+	if len(args) > 2 {
+		return r, fmt.Errorf("too many arguments")
+	}
+
+	var err error
+	if len(args) > 0 {
+		if r.Since, err = parseHistoryTime(args[0], now, false); err != nil {
+			return r, err
+		}
+	}
+	if len(args) > 1 {
+		if r.Until, err = parseHistoryTime(args[1], now, true); err != nil {
+			return r, err
+		}
+	}
+
+	return r, nil
+}
+
+// Get the plays within a range.
+func FilterHistory(entries []*HistoryEntry, r HistoryRange) []*HistoryEntry {
+	filtered := []*HistoryEntry{}
+	for _, entry := range entries {
+		if r.Contains(entry) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// Sum how much was heard over some plays, in milliseconds.
+func totalHeard(entries []*HistoryEntry) int {
+	heard := 0
+	for _, entry := range entries {
+		heard += entry.Heard
+	}
+	return heard
+}
+
+// Write plays as a table, followed by a total.
+func WriteHistory(w io.Writer, entries []*HistoryEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STARTED\tHEARD\tITEM\tDEVICE\tCONTEXT")
+	for _, entry := range entries {
+		heard := FormatDuration(entry.Heard) + "/" + FormatDuration(entry.Duration)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", entry.Started.Format("2006-01-02 15:04"), heard, entry.String(), entry.Device, entry.Context)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d plays, %s heard\n", len(entries), FormatDuration(totalHeard(entries)))
+	return err
+}
+
+// Recorder of plays, from successive snapshots of the player state.
+type historyRecorder struct {
+	// The play being observed, if any.
+	current *HistoryEntry
+
+	// The previous snapshot.
+	last *NowPlaying
+}
+
+// Observe a snapshot of the player state. Returns the previous play if it
+// ended, i.e. if another item (or nothing) is playing, or the same item
+// started over.
+func (r *historyRecorder) observe(np *NowPlaying) *HistoryEntry {
+	last := r.last
+	r.last = np
+
+	var elapsed int
+	if last != nil {
+		elapsed = int(np.Fetched.Sub(last.Fetched).Milliseconds()) + historySlack
+	}
+
+	if r.current != nil && np.Item != nil && np.Item.URI() == r.current.URI {
+		// Progress only goes back past the end if the item repeated.
+		repeated := np.Progress < last.Progress && last.Progress+elapsed >= r.current.Duration
+		if !repeated {
+			// Progress beyond the time between polls was skipped by
+			// seeking.
+			if last.Playing {
+				r.current.Heard += min(max(np.Progress-last.Progress, 0), elapsed)
+			}
+			r.current.Ended = np.Fetched
+			return nil
+		}
+	}
+
+	ended := r.finish()
+	if np.Item != nil {
+		r.current = newHistoryEntry(np)
+		if last != nil && np.Playing {
+			r.current.Heard = min(np.Progress, elapsed)
+		}
+	}
+	return ended
+}
+
+// Stop observing the current play, returning it if any of it was heard.
+func (r *historyRecorder) finish() *HistoryEntry {
+	entry := r.current
+	r.current = nil
+
+	if entry == nil || entry.Heard == 0 {
+		return nil
+	}
+	entry.Heard = min(entry.Heard, entry.Duration)
+	return entry
+}
+
+// Fill in plays from the recently played tracks, logging the outcome.
+func backfillHistory(ctx context.Context, cli *spotify.Client, path string) {
+	n, err := BackfillHistory(ctx, cli, path)
+	if err != nil {
+		log.WithError(err).Warn("failed to fill in play history")
+		return
+	}
+	log.Debugf("filled in %d plays from the recently played tracks", n)
+}
+
+// Manager for recording plays to the history file. Will continue to run in
+// background. The play being observed is recorded once the context is
+// cancelled, and then `done` is closed.
+func HistoryManager(ctx context.Context, cli *spotify.Client, path string, state *StateWatcher, done chan<- bool) {
+	defer close(done)
+
+	ch := state.Subscribe()
+	defer state.Unsubscribe(ch)
+
+	backfillHistory(ctx, cli, path)
+	ticker := time.NewTicker(historyBackfillInterval * time.Minute)
+	defer ticker.Stop()
+
+	recorder := &historyRecorder{}
+	record := func(entry *HistoryEntry) {
+		if entry == nil {
+			return
+		}
+		if err := AppendHistory(path, entry); err != nil {
+			log.WithError(err).Error("failed to record play")
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			record(recorder.finish())
+			return
+
+		case <-ticker.C:
+			backfillHistory(ctx, cli, path)
+
+		case np := <-ch:
+			record(recorder.observe(np))
+		}
+	}
+}
+
+// Show plays in a table, from the latest. The first cell of each row
+// references the play.
+func ShowHistory(table *tview.Table, entries []*HistoryEntry, theme *Theme) {
+	table.Clear()
+
+	for column, title := range []string{"STARTED", "NAME", "ARTIST", "ALBUM", "HEARD", "DEVICE"} {
+		table.SetCell(0, column, tview.NewTableCell(title).SetStyle(theme.Header).SetSelectable(false))
+	}
+
+	for i, entry := range entries {
+		by := entry.Show
+		if by == "" {
+			by = strings.Join(entry.Artists, ", ")
+		}
+
+		row := len(entries) - i
+		table.SetCell(row, 0, tview.NewTableCell(entry.Started.Format("2006-01-02 15:04")).SetReference(entry))
+		table.SetCell(row, 1, tview.NewTableCell(entry.Name).SetExpansion(2))
+		table.SetCell(row, 2, tview.NewTableCell(by).SetExpansion(1))
+		table.SetCell(row, 3, tview.NewTableCell(entry.Album).SetExpansion(1))
+		table.SetCell(row, 4, tview.NewTableCell(FormatDuration(entry.Heard)+"/"+FormatDuration(entry.Duration)).SetAlign(tview.AlignRight))
+		table.SetCell(row, 5, tview.NewTableCell(entry.Device))
+	}
+
+	table.SetFixed(1, 0).Select(1, 0).ScrollToBeginning()
+}
+
+// Load the plays within the range of the history page, and show them.
+func (ui *UI) loadHistory() {
+	r, err := ParseHistoryRange(ui.historyArgs, time.Now())
+	if err != nil {
+		log.WithError(err).Error("invalid history range")
+		return
+	}
+
+	entries, err := ReadHistory(ui.historyPath)
+	if err != nil {
+		log.WithError(err).Error("failed to read play history")
+		return
+	}
+	entries = FilterHistory(entries, r)
+
+	ui.app.QueueUpdateDraw(func() {
+		ShowHistory(ui.history, entries, ui.theme)
+		ui.message(fmt.Sprintf("%d plays, %s heard", len(entries), FormatDuration(totalHeard(entries))))
+	})
+}
+
+// Show the plays within a range (`[SINCE [UNTIL]]`) on the history page.
+func (ui *UI) showHistory(args []string) error {
+	if _, err := ParseHistoryRange(args, time.Now()); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+
+	ui.historyArgs = args
+	ui.openingPage("history")
+	ui.showPage("history")
+	return nil
+}
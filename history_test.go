@@ -0,0 +1,221 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func TestParseHistoryTime(t *testing.T) {
+	now := time.Date(2024, 1, 31, 15, 30, 0, 0, time.UTC)
+	midnight := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		end   bool
+		want  time.Time
+	}{
+		{"today", false, midnight},
+		{"today", true, midnight.AddDate(0, 0, 1)},
+		{"yesterday", false, midnight.AddDate(0, 0, -1)},
+		{"yesterday", true, midnight},
+		{"2024-01-01", false, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-01-01", true, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"2024-01-01T08:15", true, time.Date(2024, 1, 1, 8, 15, 0, 0, time.UTC)},
+		{"2024-01-01 08:15", false, time.Date(2024, 1, 1, 8, 15, 0, 0, time.UTC)},
+		{"2024-01-01T08:15:00Z", false, time.Date(2024, 1, 1, 8, 15, 0, 0, time.UTC)},
+		{"7d", false, now.AddDate(0, 0, -7)},
+		{"0d", false, now},
+		{"2w", true, now.AddDate(0, 0, -14)},
+		{"12h", false, now.Add(-12 * time.Hour)},
+		{"90m", false, now.Add(-90 * time.Minute)},
+
+		// Invalid
+		{"", false, time.Time{}},
+		{"tomorrow", false, time.Time{}},
+		{"2024-13-01", false, time.Time{}},
+		{"7x", false, time.Time{}},
+		{"d", false, time.Time{}},
+		{"-1d", false, time.Time{}},
+		{"-1h", false, time.Time{}},
+		{"7dd", false, time.Time{}},
+		{"7dw", false, time.Time{}},
+		{"3ww", false, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		got, err := parseHistoryTime(tt.value, now, tt.end)
+		if tt.want.IsZero() {
+			if err == nil {
+				t.Errorf("parseHistoryTime(%q) = %v, expected an error", tt.value, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseHistoryTime(%q) failed: %v", tt.value, err)
+		} else if !got.Equal(tt.want) {
+			t.Errorf("parseHistoryTime(%q, end=%v) = %v, expected %v", tt.value, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestParseHistoryRange(t *testing.T) {
+	now := time.Date(2024, 1, 31, 15, 30, 0, 0, time.UTC)
+	midnight := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		args  []string
+		since time.Time
+		until time.Time
+		valid bool
+	}{
+		{nil, time.Time{}, time.Time{}, true},
+		{[]string{"7d"}, now.AddDate(0, 0, -7), time.Time{}, true},
+		{[]string{"yesterday", "yesterday"}, midnight.AddDate(0, 0, -1), midnight, true},
+		{[]string{"2024-01-01", "today"}, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), midnight.AddDate(0, 0, 1), true},
+
+		// Invalid
+		{[]string{"7x"}, time.Time{}, time.Time{}, false},
+		{[]string{"today", "7x"}, time.Time{}, time.Time{}, false},
+		{[]string{"today", "yesterday", "7d"}, time.Time{}, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseHistoryRange(tt.args, now)
+		if !tt.valid {
+			if err == nil {
+				t.Errorf("ParseHistoryRange(%q) = %v, expected an error", tt.args, r)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseHistoryRange(%q) failed: %v", tt.args, err)
+		} else if !r.Since.Equal(tt.since) || !r.Until.Equal(tt.until) {
+			t.Errorf("ParseHistoryRange(%q) = %v to %v, expected %v to %v", tt.args, r.Since, r.Until, tt.since, tt.until)
+		}
+	}
+}
+
+// Create a track lasting some milliseconds.
+func historyTrack(id string, duration int) *Item {
+	return &Item{Track: &spotify.FullTrack{SimpleTrack: spotify.SimpleTrack{
+		Name: id,
+		URI: spotify.URI("spotify:track:" + id),
+		Duration: duration,
+	}}}
+}
+
+func TestHistoryRecorderObserve(t *testing.T) {
+	short := historyTrack("short", 10000)
+	long := historyTrack("long", 100000)
+	other := historyTrack("other", 100000)
+
+	// A snapshot of the player state, and the play that it ended (if any).
+	type poll struct {
+		item     *Item
+		playing  bool
+		progress int
+
+		ended *Item
+		heard int
+	}
+
+	tests := []struct {
+		name  string
+		polls []poll
+	}{
+		{
+			name: "played through",
+			polls: []poll{
+				{item: long, playing: true, progress: 0},
+				{item: long, playing: true, progress: 2000},
+				{item: long, playing: true, progress: 4000},
+				{item: nil, ended: long, heard: 4000},
+			},
+		},
+		{
+			name: "repeated",
+			polls: []poll{
+				{item: short, playing: true, progress: 0},
+				{item: short, playing: true, progress: 2000},
+				{item: short, playing: true, progress: 4000},
+				{item: short, playing: true, progress: 6000},
+				{item: short, playing: true, progress: 8000},
+				{item: short, playing: true, progress: 500, ended: short, heard: 8000},
+				{item: short, playing: true, progress: 2500},
+				{item: nil, ended: short, heard: 2500},
+			},
+		},
+		{
+			name: "seeked",
+			polls: []poll{
+				{item: long, playing: true, progress: 0},
+				{item: long, playing: true, progress: 2000},
+				{item: long, playing: true, progress: 50000},
+				{item: long, playing: true, progress: 52000},
+				{item: long, playing: true, progress: 10000},
+				{item: long, playing: true, progress: 12000},
+				{item: nil, ended: long, heard: 9000},
+			},
+		},
+		{
+			name: "paused",
+			polls: []poll{
+				{item: long, playing: true, progress: 0},
+				{item: long, playing: false, progress: 2000},
+				{item: long, playing: false, progress: 2000},
+				{item: long, playing: true, progress: 2000},
+				{item: long, playing: true, progress: 4000},
+				{item: nil, ended: long, heard: 4000},
+			},
+		},
+		{
+			name: "skipped",
+			polls: []poll{
+				{item: long, playing: true, progress: 0},
+				{item: other, playing: true, progress: 0},
+				{item: other, playing: true, progress: 2000},
+				{item: long, playing: true, progress: 1000, ended: other, heard: 2000},
+				{item: nil, ended: long, heard: 1000},
+			},
+		},
+		{
+			name: "observed midway",
+			polls: []poll{
+				{item: long, playing: true, progress: 30000},
+				{item: long, playing: true, progress: 32000},
+				{item: nil, ended: long, heard: 2000},
+			},
+		},
+	}
+
+	start := time.Date(2024, 1, 31, 15, 30, 0, 0, time.UTC)
+	for _, tt := range tests {
+		recorder := &historyRecorder{}
+		for i, p := range tt.polls {
+			np := &NowPlaying{
+				Item: p.item,
+				Playing: p.playing,
+				Progress: p.progress,
+				Fetched: start.Add(time.Duration(i) * 2 * time.Second),
+			}
+			entry := recorder.observe(np)
+
+			switch {
+			case p.ended == nil && entry != nil:
+				t.Errorf("%s: poll %d ended a play of %s", tt.name, i, entry.URI)
+			case p.ended != nil && entry == nil:
+				t.Errorf("%s: poll %d ended no play, expected %s", tt.name, i, p.ended.URI())
+			case p.ended != nil && (entry.URI != p.ended.URI() || entry.Heard != p.heard):
+				t.Errorf("%s: poll %d ended a play of %s heard for %dms, expected %s for %dms", tt.name, i, entry.URI, entry.Heard, p.ended.URI(), p.heard)
+			}
+		}
+
+		if entry := recorder.finish(); entry != nil {
+			t.Errorf("%s: a play of %s was left unfinished", tt.name, entry.URI)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+// Optional HTTP API for remote control, e.g. by a dashboard. Only listens on
+// localhost, and every request must carry the token either as
+// `Authorization: Bearer [token]` or as `?token=[token]`.
+//
+//   POST /api/[command]  Send a `ControlRequest` (as the JSON body, which may
+//                        be empty) for one of `play`, `pause`, `toggle`,
+//                        `next`, `prev`, `queue`, `seek`, or `volume`.
+//   GET  /api/status     Report the player state.
+//   GET  /api/search?q=  Search the Spotify catalog, or the saved tracks
+//                        with `&scope=library`.
+//   GET  /api/events     WebSocket stream of the player state, sent whenever
+//                        it changes.
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+	"golang.org/x/net/websocket"
+)
+
+// Generate a random token for the HTTP API.
+func GenerateToken() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.WithError(err).Fatal("failed to generate token")
+	}
+	return hex.EncodeToString(buf)
+}
+
+// Write a JSON response.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Write a JSON error response.
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, &ControlResponse{Error: err.Error()})
+}
+
+// Wrap a handler to require the token.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := r.URL.Query().Get("token")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			given = bearer
+		}
+
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Check that a WebSocket connection is not opened by a web page, unless the
+// page is served from localhost. Clients other than browsers send no origin.
+func checkLocalOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	config.Origin = origin
+
+	if origin == nil {
+		return nil
+	}
+	switch origin.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return nil
+	}
+	return fmt.Errorf("origin not allowed: %s", origin)
+}
+
+// Check if two player states differ in a way worth reporting. Progress is
+// expected to change and is ignored.
+func statusChanged(prev, next *Status) bool {
+	if prev == nil {
+		return true
+	}
+
+	a, b := *prev, *next
+	a.Progress, b.Progress = 0, 0
+
+	return !reflect.DeepEqual(a, b)
+}
+
+// Create the handler for the HTTP API.
+func apiHandler(ctx context.Context, cli *spotify.Client, token string, tx chan<- *Event, state *StateWatcher) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /api/{command}", func(w http.ResponseWriter, r *http.Request) {
+		req := &ControlRequest{}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(body) != 0 {
+			if err := json.Unmarshal(body, req); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+				return
+			}
+		}
+		req.Command = r.PathValue("command")
+
+		ev, err := req.Event()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if ev == nil {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("use GET for status"))
+			return
+		}
+
+		tx <- ev
+		writeJSON(w, http.StatusAccepted, &ControlResponse{OK: true})
+	})
+
+	mux.HandleFunc("GET /api/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, &ControlResponse{OK: true, Status: state.Latest().Status()})
+	})
+
+	mux.HandleFunc("GET /api/search", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		if query == "" {
+			writeError(w, http.StatusBadRequest, errors.New("missing query"))
+			return
+		}
+
+		var results []SearchResult
+		var err error
+		switch scope := r.URL.Query().Get("scope"); scope {
+		case "", "catalog":
+			results, err = Search(r.Context(), cli, query)
+		case "library":
+			results, err = SearchLibrary(r.Context(), cli, query)
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid scope: %s", scope))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, results)
+	})
+
+	mux.Handle("GET /api/events", websocket.Server{
+		Handshake: checkLocalOrigin,
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+
+			ch := state.Subscribe()
+			defer state.Unsubscribe(ch)
+
+			// Notice when the client hangs up.
+			closed := make(chan bool)
+			go func() {
+				io.Copy(io.Discard, conn)
+				close(closed)
+			}()
+
+			var prev *Status
+			np := state.Latest()
+			for {
+				status := np.Status()
+				if statusChanged(prev, status) {
+					if err := websocket.JSON.Send(conn, status); err != nil {
+						return
+					}
+					prev = status
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-closed:
+					return
+				case np = <-ch:
+				}
+			}
+		},
+	})
+
+	return requireToken(token, mux)
+}
+
+// Manager for the HTTP API. Terminates once the context is cancelled.
+func HTTPManager(ctx context.Context, cli *spotify.Client, port int, token string, tx chan<- *Event, state *StateWatcher) {
+	srv := &http.Server{
+		Addr: fmt.Sprintf("localhost:%d", port),
+		Handler: apiHandler(ctx, cli, token, tx, state),
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	log.Debugf("serving HTTP API at http://%s", srv.Addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.WithError(err).Error("HTTP API died")
+	}
+
+	log.Trace("context closed, terminating HTTP manager")
+}
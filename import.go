@@ -0,0 +1,733 @@
+package main
+
+// Importing lists of tracks from files into the library or a new playlist.
+// Entries with a URI are looked up directly, and other entries are resolved
+// by ISRC or by searching for their artist and name. Matches that are not
+// confident are reviewed interactively, and nothing is saved without a
+// report of what would be.
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+)
+
+// Minimum score of a match that does not need to be reviewed.
+const importConfidence = 0.85
+
+// Number of candidates to consider for each entry.
+const importCandidates = 5
+
+// An entry of an imported file. Any field may be missing.
+type ImportEntry struct {
+	// Line (or position) of the entry in the file.
+	Line int
+
+	trackMetadata
+	ISRC string
+	URI  *URI
+}
+
+// Describe an entry, e.g. for a report.
+func (entry *ImportEntry) String() string {
+	if entry.Name == "" && entry.URI != nil {
+		return entry.URI.String()
+	}
+	if len(entry.Artists) == 0 {
+		return entry.Name
+	}
+	return strings.Join(entry.Artists, ", ") + " - " + entry.Name
+}
+
+// Split a list of artists on `;`, or else on `,`.
+func splitArtists(artists string) []string {
+	sep := ","
+	if strings.Contains(artists, ";") {
+		sep = ";"
+	}
+
+	split := []string{}
+	for _, artist := range strings.Split(artists, sep) {
+		if artist = strings.TrimSpace(artist); artist != "" {
+			split = append(split, artist)
+		}
+	}
+
+	return split
+}
+
+// Split text like `Artist - Title` into the artists and the name.
+func splitArtistName(text string) ([]string, string) {
+	artist, name, ok := strings.Cut(text, " - ")
+	if !ok {
+		return nil, strings.TrimSpace(text)
+	}
+	return splitArtists(artist), strings.TrimSpace(name)
+}
+
+// Parse a duration in seconds, or like `3:05`, into milliseconds. Durations
+// in milliseconds are only read from columns named for them (see
+// `importColumns`), since a number alone cannot tell the unit.
+func parseImportDuration(text string) int {
+	text = strings.TrimSpace(text)
+	if minutes, seconds, ok := strings.Cut(text, ":"); ok {
+		m, err1 := strconv.Atoi(minutes)
+		s, err2 := strconv.Atoi(seconds)
+		if err1 != nil || err2 != nil {
+			return 0
+		}
+		return (m*60 + s) * 1000
+	}
+
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return int(n * 1000)
+}
+
+// Names of the columns of an imported CSV file, by field. Column names are
+// compared in lowercase with only letters, e.g. `Artist Name(s)` is
+// `artistnames`.
+var importColumns = map[string][]string{
+	"name": {"name", "title", "track", "trackname", "song", "songname"},
+	"artists": {"artists", "artist", "artistname", "artistnames", "creator"},
+	"album": {"album", "albumname", "albumtitle"},
+	"duration_ms": {"durationms"},
+	"duration": {"duration", "length", "time"},
+	"isrc": {"isrc"},
+	"uri": {"uri", "trackuri", "spotifyuri", "link", "url", "spotifylink"},
+}
+
+// Normalize the name of a CSV column.
+func normalizeColumn(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if 'a' <= r && r <= 'z' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Read a CSV file with a header, e.g. one exported by nspotify.
+func readImportCSV(r io.Reader) ([]*ImportEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = normalizeColumn(name)
+		for field, names := range importColumns {
+			if _, ok := columns[field]; !ok && slices.Contains(names, name) {
+				columns[field] = i
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no known columns in header: %s", strings.Join(header, ","))
+	}
+
+	entries := []*ImportEntry{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		get := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		entry := &ImportEntry{Line: line, ISRC: get("isrc")}
+		entry.Name = get("name")
+		entry.Artists = splitArtists(get("artists"))
+		entry.Album = get("album")
+		if ms, err := strconv.ParseFloat(get("duration_ms"), 64); err == nil && ms >= 0 {
+			entry.Duration = int(ms)
+		} else {
+			entry.Duration = parseImportDuration(get("duration"))
+		}
+		if uri := get("uri"); uri != "" {
+			entry.URI, _ = ParseURI(uri)
+		}
+
+		entries = append(entries, entry)
+	}
+}
+
+// Read an M3U file, extended or not. Locations that are not Spotify URIs or
+// links (e.g. local files) are resolved by their `#EXTINF` description, or
+// else by their file name.
+func readImportM3U(r io.Reader) ([]*ImportEntry, error) {
+	entries := []*ImportEntry{}
+	entry := &ImportEntry{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case text == "":
+
+		case strings.HasPrefix(text, "#EXTINF:"):
+			info, description, _ := strings.Cut(strings.TrimPrefix(text, "#EXTINF:"), ",")
+			if fields := strings.Fields(info); len(fields) != 0 {
+				if seconds, err := strconv.Atoi(fields[0]); err == nil && seconds > 0 {
+					entry.Duration = seconds * 1000
+				}
+			}
+			entry.Artists, entry.Name = splitArtistName(description)
+
+		case strings.HasPrefix(text, "#EXTALB:"):
+			entry.Album = strings.TrimSpace(strings.TrimPrefix(text, "#EXTALB:"))
+
+		case strings.HasPrefix(text, "#EXTART:"):
+			entry.Artists = splitArtists(strings.TrimPrefix(text, "#EXTART:"))
+
+		case strings.HasPrefix(text, "#"):
+
+		default:
+			entry.Line = line
+			if uri, err := ParseURI(text); err == nil {
+				entry.URI = uri
+			} else if entry.Name == "" {
+				name := strings.TrimSuffix(filepath.Base(text), filepath.Ext(text))
+				entry.Artists, entry.Name = splitArtistName(name)
+			}
+
+			entries = append(entries, entry)
+			entry = &ImportEntry{}
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// An XSPF playlist, as much as is needed to import it.
+type xspfPlaylist struct {
+	Tracks []struct {
+		Locations   []string `xml:"location"`
+		Identifiers []string `xml:"identifier"`
+		Title       string   `xml:"title"`
+		Creator     string   `xml:"creator"`
+		Album       string   `xml:"album"`
+		Duration    int      `xml:"duration"`
+	} `xml:"trackList>track"`
+}
+
+// Read an XSPF playlist, e.g. one exported by nspotify. A track is located
+// by the first location or identifier that is a Spotify URI or link, and
+// identifiers like `isrc:[ISRC]` are ISRCs.
+func readImportXSPF(r io.Reader) ([]*ImportEntry, error) {
+	var playlist xspfPlaylist
+	if err := xml.NewDecoder(r).Decode(&playlist); err != nil {
+		return nil, err
+	}
+
+	entries := []*ImportEntry{}
+	for i, track := range playlist.Tracks {
+		entry := &ImportEntry{Line: i + 1}
+		entry.Name = strings.TrimSpace(track.Title)
+		entry.Artists = splitArtists(track.Creator)
+		entry.Album = strings.TrimSpace(track.Album)
+		entry.Duration = track.Duration
+
+		for _, location := range append(track.Locations, track.Identifiers...) {
+			location = strings.TrimSpace(location)
+			if isrc, ok := strings.CutPrefix(location, "isrc:"); ok {
+				entry.ISRC = isrc
+			} else if uri, err := ParseURI(location); err == nil && entry.URI == nil {
+				entry.URI = uri
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Read a list of URIs or links, one per line. Other lines are resolved as
+// `Artist - Title`.
+func readImportList(r io.Reader) ([]*ImportEntry, error) {
+	entries := []*ImportEntry{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		entry := &ImportEntry{Line: line}
+		if uri, err := ParseURI(text); err == nil {
+			entry.URI = uri
+		} else {
+			entry.Artists, entry.Name = splitArtistName(text)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Readers of imported files, by extension. Other files are read as lists of
+// URIs.
+var importReaders = map[string]func(r io.Reader) ([]*ImportEntry, error){
+	".csv": readImportCSV,
+	".m3u": readImportM3U,
+	".m3u8": readImportM3U,
+	".xspf": readImportXSPF,
+}
+
+// Read the entries of a file to import.
+func ReadImport(path string) ([]*ImportEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import: %w", err)
+	}
+	defer f.Close()
+
+	read, ok := importReaders[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		read = readImportList
+	}
+
+	entries, err := read(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import %s: %w", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("failed to import %s: no entries", path)
+	}
+
+	return entries, nil
+}
+
+// A candidate match of an entry, scored from 0 to 1.
+type importCandidate struct {
+	Track *spotify.FullTrack
+	Score float64
+}
+
+// The match of an entry. Entries that are not confidently matched must be
+// reviewed before they are imported.
+type ImportMatch struct {
+	Entry *ImportEntry
+
+	// Candidates, best first.
+	Candidates []importCandidate
+
+	// Index of the chosen candidate, or -1 if none.
+	Choice int
+
+	// If the choice must be reviewed, and if it has been.
+	Review   bool
+	Reviewed bool
+
+	// Why the entry cannot be imported, e.g. `not found`.
+	Problem string
+}
+
+// Get the track to import, if any.
+func (m *ImportMatch) Track() *spotify.FullTrack {
+	if m.Problem != "" || m.Choice < 0 || (m.Review && !m.Reviewed) {
+		return nil
+	}
+	return m.Candidates[m.Choice].Track
+}
+
+// Get the status of a match, e.g. for a report.
+func (m *ImportMatch) Status() string {
+	switch {
+	case m.Problem != "":
+		return m.Problem
+	case m.Choice < 0:
+		return "skipped"
+	case m.Review && !m.Reviewed:
+		return "ambiguous"
+	case m.Reviewed:
+		return "reviewed"
+	}
+	return "matched"
+}
+
+// Format the chosen candidate of a match, e.g. for a report.
+func (m *ImportMatch) Chosen() (string, string) {
+	if m.Problem != "" || m.Choice < 0 {
+		return "-", ""
+	}
+
+	candidate := m.Candidates[m.Choice]
+	description := FormatArtists(candidate.Track.Artists) + " - " + candidate.Track.Name + " (" + candidate.Track.Album.Name + ")"
+	return fmt.Sprintf("%.2f", candidate.Score), description
+}
+
+// Choose the best candidate of a match, and whether it needs review.
+func (m *ImportMatch) choose() {
+	slices.SortStableFunc(m.Candidates, func(a, b importCandidate) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	if len(m.Candidates) > importCandidates {
+		m.Candidates = m.Candidates[:importCandidates]
+	}
+
+	if len(m.Candidates) == 0 {
+		m.Choice = -1
+		if m.Problem == "" {
+			m.Problem = "not found"
+		}
+		return
+	}
+
+	m.Choice = 0
+	m.Review = m.Candidates[0].Score < importConfidence
+}
+
+// Build a search query for the name and artist of an entry. Quotes are
+// removed from field filters.
+func importQuery(entry *ImportEntry, filtered bool) string {
+	if !filtered {
+		return normalizeText(strings.Join(append(slices.Clone(entry.Artists), entry.Name), " "))
+	}
+
+	unquote := strings.NewReplacer(`"`, "")
+	query := fmt.Sprintf(`track:"%s"`, unquote.Replace(normalizeName(entry.Name)))
+	if len(entry.Artists) != 0 {
+		query += fmt.Sprintf(` artist:"%s"`, unquote.Replace(entry.Artists[0]))
+	}
+	return query
+}
+
+// Search for tracks.
+func searchTracks(ctx context.Context, cli *spotify.Client, query string) ([]spotify.FullTrack, error) {
+	results, err := cli.Search(ctx, query, spotify.SearchTypeTrack, spotify.Limit(importCandidates*2))
+	if err != nil {
+		return nil, err
+	}
+	if results.Tracks == nil {
+		return nil, nil
+	}
+	return results.Tracks.Tracks, nil
+}
+
+// Resolve an entry without a URI, first by ISRC and then by searching for
+// its name and artist.
+func resolveEntry(ctx context.Context, cli *spotify.Client, m *ImportMatch) {
+	entry := m.Entry
+	defer m.choose()
+
+	if entry.ISRC != "" {
+		tracks, err := searchTracks(ctx, cli, "isrc:"+entry.ISRC)
+		if err != nil {
+			log.WithError(err).Warnf("failed to search for ISRC %s", entry.ISRC)
+		}
+		for i := range tracks {
+			if !strings.EqualFold(tracks[i].ExternalIDs["isrc"], entry.ISRC) {
+				continue
+			}
+
+			// Every release of a recording has the same ISRC, so
+			// prefer the release that matches best otherwise.
+			score := 1.0
+			if entry.Name != "" {
+				score = 0.9 + 0.1*matchScore(entry.trackMetadata, fullTrackMetadata(&tracks[i]))
+			}
+			m.Candidates = append(m.Candidates, importCandidate{&tracks[i], score})
+		}
+		if len(m.Candidates) != 0 {
+			return
+		}
+	}
+
+	if entry.Name == "" {
+		return
+	}
+
+	for _, filtered := range []bool{true, false} {
+		tracks, err := searchTracks(ctx, cli, importQuery(entry, filtered))
+		if err != nil {
+			log.WithError(err).Warnf("failed to search for %s", entry)
+			m.Problem = "search failed"
+			return
+		}
+		for i := range tracks {
+			score := matchScore(entry.trackMetadata, fullTrackMetadata(&tracks[i]))
+			m.Candidates = append(m.Candidates, importCandidate{&tracks[i], score})
+		}
+		if len(m.Candidates) != 0 {
+			return
+		}
+	}
+}
+
+// Look up the entries with track URIs, in batches. If a batch fails, each of
+// its entries is reported as failed, and the rest are still looked up.
+func lookupEntries(ctx context.Context, cli *spotify.Client, matches []*ImportMatch) error {
+	for start := 0; start < len(matches); start += 50 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch := matches[start:min(start+50, len(matches))]
+
+		ids := []spotify.ID{}
+		for _, m := range batch {
+			ids = append(ids, m.Entry.URI.ID)
+		}
+
+		tracks, err := cli.GetTracks(ctx, ids)
+		if err != nil {
+			log.WithError(err).Warnf("failed to look up entries %d through %d", start+1, start+len(batch))
+			for _, m := range batch {
+				m.Problem = "lookup failed"
+			}
+			continue
+		}
+
+		for i, m := range batch {
+			if i < len(tracks) && tracks[i] != nil {
+				m.Candidates = []importCandidate{{tracks[i], 1}}
+			}
+			m.choose()
+		}
+	}
+
+	return nil
+}
+
+// Resolve every entry to a track. `progress` is called as entries are
+// resolved.
+func ResolveImport(ctx context.Context, cli *spotify.Client, entries []*ImportEntry, progress func(done, total int)) ([]*ImportMatch, error) {
+	matches := []*ImportMatch{}
+	lookups := []*ImportMatch{}
+	searches := []*ImportMatch{}
+
+	for _, entry := range entries {
+		m := &ImportMatch{Entry: entry, Choice: -1}
+		matches = append(matches, m)
+
+		switch {
+		case entry.URI == nil:
+			searches = append(searches, m)
+		case entry.URI.Type == TrackURI:
+			lookups = append(lookups, m)
+		default:
+			m.Problem = "not a track"
+		}
+	}
+
+	if err := lookupEntries(ctx, cli, lookups); err != nil {
+		return nil, err
+	}
+	progress(len(lookups), len(lookups)+len(searches))
+
+	for i, m := range searches {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		resolveEntry(ctx, cli, m)
+		progress(len(lookups)+i+1, len(lookups)+len(searches))
+	}
+
+	return matches, nil
+}
+
+// Get the IDs of the tracks to import, in order.
+func ImportIDs(matches []*ImportMatch) []spotify.ID {
+	ids := []spotify.ID{}
+	for _, m := range matches {
+		if track := m.Track(); track != nil {
+			ids = append(ids, track.ID)
+		}
+	}
+
+	return ids
+}
+
+// Write a report of how every entry was matched, and what would be
+// imported.
+func WriteImportReport(out io.Writer, matches []*ImportMatch) error {
+	counts := map[string]int{}
+	for _, m := range matches {
+		counts[m.Status()]++
+	}
+
+	statuses := []string{}
+	for _, status := range []string{"matched", "reviewed", "ambiguous", "skipped", "not found", "not a track", "search failed"} {
+		if counts[status] != 0 {
+			statuses = append(statuses, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "LINE\tSTATUS\tSCORE\tENTRY\tMATCH\n")
+	for _, m := range matches {
+		score, match := m.Chosen()
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", m.Entry.Line, m.Status(), score, m.Entry, match)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "\n%d entries: %s\n%d tracks to import\n", len(matches), strings.Join(statuses, ", "), len(ImportIDs(matches)))
+	return err
+}
+
+// Check an import target: `library`, or `playlist:NAME` for a new playlist.
+func checkImportTarget(target string) error {
+	if target == "library" {
+		return nil
+	}
+	if name, ok := strings.CutPrefix(target, "playlist:"); ok && strings.TrimSpace(name) != "" {
+		return nil
+	}
+	return fmt.Errorf("invalid import target: %s (try library or playlist:NAME)", target)
+}
+
+// Describe an import target.
+func describeImportTarget(target string) string {
+	if name, ok := strings.CutPrefix(target, "playlist:"); ok {
+		return fmt.Sprintf("a new playlist %q", strings.TrimSpace(name))
+	}
+	return "the library"
+}
+
+// Save tracks to an import target. Tracks already in the library are saved
+// again harmlessly.
+func SaveImport(ctx context.Context, cli *spotify.Client, ids []spotify.ID, target string) error {
+	name, ok := strings.CutPrefix(target, "playlist:")
+	if !ok {
+		for start := 0; start < len(ids); start += 50 {
+			if err := cli.AddTracksToLibrary(ctx, ids[start:min(start+50, len(ids))]...); err != nil {
+				return fmt.Errorf("failed to save tracks: %w", err)
+			}
+		}
+		return nil
+	}
+
+	user, err := cli.CurrentUser(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create playlist: %w", err)
+	}
+
+	playlist, err := cli.CreatePlaylistForUser(ctx, user.ID, strings.TrimSpace(name), "Imported by nspotify", false, false)
+	if err != nil {
+		return fmt.Errorf("failed to create playlist: %w", err)
+	}
+
+	for start := 0; start < len(ids); start += 100 {
+		if _, err := cli.AddTracksToPlaylist(ctx, playlist.ID, ids[start:min(start+100, len(ids))]...); err != nil {
+			return fmt.Errorf("failed to add tracks to playlist: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Check if a file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Ask the end user to confirm something on the terminal.
+func confirm(prompt string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// Import as configured by `-import` and `-import-to`. Without a target, this
+// is a dry run that only reports the matches. On a terminal, ambiguous
+// matches are reviewed first, and saving must be confirmed after the report.
+func RunImport(ctx context.Context, cli *spotify.Client, cfg *Config) error {
+	entries, err := ReadImport(cfg.Import)
+	if err != nil {
+		return err
+	}
+
+	interactive := isTerminal(os.Stdin) && isTerminal(os.Stderr)
+
+	matches, err := ResolveImport(ctx, cli, entries, func(done, total int) {
+		if interactive {
+			fmt.Fprintf(os.Stderr, "\rresolving %d/%d...", done, total)
+		}
+	})
+	if interactive {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if interactive && slices.ContainsFunc(matches, func(m *ImportMatch) bool { return m.Review }) {
+		theme, err := cfg.NewTheme()
+		if err != nil {
+			return err
+		}
+		if err := ReviewImport(matches, theme); err != nil {
+			return err
+		}
+	}
+
+	if err := WriteImportReport(os.Stdout, matches); err != nil {
+		return err
+	}
+
+	ids := ImportIDs(matches)
+	if cfg.ImportTo == "" || len(ids) == 0 {
+		return nil
+	}
+
+	target := describeImportTarget(cfg.ImportTo)
+	if interactive && !confirm(fmt.Sprintf("Save %d tracks to %s?", len(ids), target)) {
+		return nil
+	}
+
+	if err := SaveImport(ctx, cli, ids, cfg.ImportTo); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "saved %d tracks to %s\n", len(ids), target)
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+// Items that can be listed, played, and queued.
+
+import (
+	"fmt"
+
+	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
+)
+
+// A playable item. Exactly one of `Track` or `Episode` is set.
+type Item struct {
+	Track   *spotify.FullTrack
+	Episode *spotify.EpisodePage
+
+	// Timestamp that the item was saved, if known.
+	AddedAt string
+}
+
+// Wrap a saved Spotify track as an `Item`.
+func TrackItem(track *spotify.SavedTrack) *Item {
+	return &Item{
+		Track: &track.FullTrack,
+		AddedAt: track.AddedAt,
+	}
+}
+
+// Wrap a Spotify episode as an `Item`.
+func EpisodeItem(episode *spotify.EpisodePage) *Item {
+	return &Item{
+		Episode: episode,
+	}
+}
+
+// Get the URI of an item.
+func (item *Item) URI() spotify.URI {
+	if item.Episode != nil {
+		return item.Episode.URI
+	}
+	return item.Track.URI
+}
+
+// Get the name of an item.
+func (item *Item) Name() string {
+	if item.Episode != nil {
+		return item.Episode.Name
+	}
+	return item.Track.Name
+}
+
+// Get the duration (in milliseconds) of an item.
+func (item *Item) Duration() int {
+	if item.Episode != nil {
+		return item.Episode.Duration_ms
+	}
+	return item.Track.Duration
+}
+
+// Get the artists of an item (or the show, for episodes).
+func (item *Item) Artist() string {
+	if item.Episode != nil {
+		return item.Episode.Show.Name
+	}
+	return FormatArtists(item.Track.Artists)
+}
+
+// Get the album of an item. Episodes have no album.
+func (item *Item) Album() string {
+	if item.Episode != nil {
+		return ""
+	}
+	return item.Track.Album.Name
+}
+
+// Get the release date of an item.
+func (item *Item) Released() string {
+	if item.Episode != nil {
+		return item.Episode.ReleaseDate
+	}
+	return item.Track.Album.ReleaseDate
+}
+
+// Get the ISRC of an item, if known. Episodes have none.
+func (item *Item) ISRC() string {
+	if item.Episode != nil {
+		return ""
+	}
+	if isrc := item.Track.ExternalIDs["isrc"]; isrc != "" {
+		return isrc
+	}
+	return item.Track.SimpleTrack.ExternalIDs.ISRC
+}
+
+// Get the position (in milliseconds) that playback of an item should resume
+// from. Only episodes track a resume point; tracks always start from the
+// beginning.
+func (item *Item) ResumePosition() int {
+	if item.Episode == nil || item.Episode.ResumePoint.FullyPlayed {
+		return 0
+	}
+	return item.Episode.ResumePoint.ResumePositionMs
+}
+
+// Describe an item as `[artists] - [name]` (or `[show] - [name]` for
+// episodes).
+func DescribeItem(item *Item) string {
+	if item.Episode != nil {
+		return fmt.Sprintf("%s - %s", item.Episode.Show.Name, item.Episode.Name)
+	}
+	return fmt.Sprintf("%s - %s", FormatArtists(item.Track.Artists), item.Track.Name)
+}
+
+// Create a row of table cells from an item. The first cell references the
+// item.
+func IntoCells(item *Item) []*tview.TableCell {
+	var cells []*tview.TableCell
+	if item.Episode != nil {
+		cells = EpisodeIntoCells(item.Episode)
+	} else {
+		cells = TrackIntoCells(item.Track)
+	}
+
+	cells[0].SetReference(item)
+
+	return cells
+}
+
+// Get the item referenced by a row of a table.
+func ItemAt(table *tview.Table, row int) (*Item, bool) {
+	item, ok := table.GetCell(row, 0).GetReference().(*Item)
+	return item, ok
+}
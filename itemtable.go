@@ -0,0 +1,571 @@
+package main
+
+// Tables of items that can be sorted and filtered.
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Ways to sort items, by name.
+var itemOrders = map[string]func(a, b *Item) int{
+	"name": func(a, b *Item) int {
+		return compareFold(a.Name(), b.Name())
+	},
+	"artist": func(a, b *Item) int {
+		return compareFold(a.Artist(), b.Artist())
+	},
+	"album": func(a, b *Item) int {
+		return compareFold(a.Album(), b.Album())
+	},
+	"duration": func(a, b *Item) int {
+		return cmp.Compare(a.Duration(), b.Duration())
+	},
+	"added": func(a, b *Item) int {
+		return cmp.Compare(a.AddedAt, b.AddedAt)
+	},
+	"released": func(a, b *Item) int {
+		return cmp.Compare(a.Released(), b.Released())
+	},
+}
+
+// Compare strings, ignoring case.
+func compareFold(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// Get the names of the ways to sort items, plus `loaded` for the order they
+// were loaded in.
+func ItemOrders() []string {
+	names := []string{"loaded"}
+	for name := range itemOrders {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+
+	return names
+}
+
+// Check if an item matches a filter, by name, artist, or album.
+func matchItem(item *Item, filter string) bool {
+	if filter == "" {
+		return true
+	}
+
+	text := strings.ToLower(item.Name() + "\n" + item.Artist() + "\n" + item.Album())
+	return strings.Contains(text, filter)
+}
+
+// A column of a table of items.
+type Column struct {
+	Title string
+
+	// Way to sort by this column, if any. See `itemOrders`.
+	Order string
+}
+
+// A row of a table of items.
+type itemRow struct {
+	item  *Item
+	cells []*tview.TableCell
+
+	// Position in the order that rows were loaded.
+	index int
+}
+
+// Content of a table of items. Every item is kept in the order it was loaded,
+// while the rows shown are filtered and sorted, and optionally grouped. The
+// first row is a header. The playing item is highlighted as it is drawn, so
+// rows loaded later are highlighted too.
+type itemContent struct {
+	tview.TableContentReadOnly
+
+	mu   sync.Mutex
+	rows []*itemRow
+	view []*itemRow
+
+	order  string
+	desc   bool
+	filter string
+
+	// Way to group the rows shown (see `itemGroupings`), and the lines
+	// of groups and rows if grouped. Lines are rebuilt as they are next
+	// drawn once `dirty`, so that appending stays cheap.
+	grouping  string
+	lines     []itemLine
+	dirty     bool
+	collapsed map[string]bool
+
+	// Items marked for multi-selection.
+	marked map[*Item]bool
+
+	columns []Column
+	header  tcell.Style
+	mark    tcell.Style
+	playing *Playing
+
+	// Called when the end user clicks a column header.
+	clicked func(column Column)
+}
+
+// Compare rows by the current order. Ties are kept in the order they were
+// loaded.
+func (c *itemContent) compare(a, b *itemRow) int {
+	result := 0
+	if by, ok := itemOrders[c.order]; ok {
+		result = by(a.item, b.item)
+	}
+	if c.desc {
+		result = -result
+	}
+	if result == 0 {
+		result = cmp.Compare(a.index, b.index)
+	}
+
+	return result
+}
+
+// Rebuild the rows shown. Must be called with the lock held.
+func (c *itemContent) rebuild() {
+	c.view = nil
+	for _, row := range c.rows {
+		if matchItem(row.item, c.filter) {
+			c.view = append(c.view, row)
+		}
+	}
+	slices.SortFunc(c.view, c.compare)
+	c.dirty = true
+}
+
+// Check if the rows shown are grouped.
+func (c *itemContent) grouped() bool {
+	return len(itemGroupings[c.grouping]) != 0
+}
+
+// Rebuild the lines of groups and rows, if needed. Must be called with the
+// lock held.
+func (c *itemContent) regroup() {
+	if !c.dirty || !c.grouped() {
+		return
+	}
+
+	root := &itemGroup{byKey: map[string]*itemGroup{}}
+	levels := itemGroupings[c.grouping]
+	for _, row := range c.view {
+		root.add(row, levels)
+	}
+
+	// Without an explicit order, the tracks of an album are in album
+	// order.
+	root.sort(c.order == "" || c.order == "loaded")
+
+	c.lines = flattenGroups(root, c.collapsed, nil)
+	c.dirty = false
+}
+
+// Get the line at a row, excluding the header. Must be called with the lock
+// held.
+func (c *itemContent) line(row int) (itemLine, bool) {
+	if !c.grouped() {
+		if row < 0 || row >= len(c.view) {
+			return itemLine{}, false
+		}
+		return itemLine{row: c.view[row]}, true
+	}
+
+	c.regroup()
+	if row < 0 || row >= len(c.lines) {
+		return itemLine{}, false
+	}
+	return c.lines[row], true
+}
+
+// Create the header cell of a column, marked if the rows are sorted by it.
+// Must be called with the lock held.
+func (c *itemContent) headerCell(column Column) *tview.TableCell {
+	title := column.Title
+	if column.Order != "" && column.Order == c.order {
+		if c.desc {
+			title += " ▼"
+		} else {
+			title += " ▲"
+		}
+	}
+
+	return tview.NewTableCell(title).SetStyle(c.header).SetSelectable(false).SetClickedFunc(func() bool {
+		c.clicked(column)
+		return true
+	})
+}
+
+func (c *itemContent) GetCell(row, column int) *tview.TableCell {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if row == 0 && 0 <= column && column < len(c.columns) {
+		return c.headerCell(c.columns[column])
+	}
+
+	line, ok := c.line(row - 1)
+	if !ok || column < 0 {
+		return nil
+	}
+
+	if line.group != nil {
+		cells := groupCells(line.group, c.collapsed[line.group.key], len(c.columns))
+		if column >= len(cells) {
+			return nil
+		}
+		return cells[column].SetStyle(c.header)
+	}
+
+	r := line.row
+	if column >= len(r.cells) {
+		return nil
+	}
+	cell := r.cells[column]
+
+	// Rows within groups are indented, so copy the cell.
+	if column == 0 && line.parent != nil {
+		indented := *cell
+		indented.Text = indent(line.parent.depth) + cell.Text
+		cell = &indented
+	}
+
+	if c.marked[r.item] {
+		highlighted := *cell
+		highlighted.SetStyle(c.mark)
+		return &highlighted
+	}
+
+	if c.playing != nil && r.item.URI() == c.playing.URI() {
+		highlighted := *cell
+		highlighted.SetStyle(c.playing.highlight)
+		return &highlighted
+	}
+
+	return cell
+}
+
+func (c *itemContent) GetRowCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.grouped() {
+		c.regroup()
+		return len(c.lines) + 1
+	}
+	return len(c.view) + 1
+}
+
+func (c *itemContent) GetColumnCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	columns := len(c.columns)
+	for _, row := range c.view {
+		columns = max(columns, len(row.cells))
+	}
+
+	return columns
+}
+
+func (c *itemContent) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rows = nil
+	c.view = nil
+	c.marked = map[*Item]bool{}
+	c.lines = nil
+	c.dirty = true
+}
+
+// A table of items.
+type ItemTable struct {
+	*tview.Table
+	content *itemContent
+}
+
+// Create a table of items with some columns, styled by a theme and
+// highlighting the playing item. Clicking a column header sorts by it, or
+// reverses the order if already sorted by it.
+func NewItemTable(columns []Column, theme *Theme, playing *Playing) *ItemTable {
+	content := &itemContent{
+		collapsed: map[string]bool{},
+		marked: map[*Item]bool{},
+		columns: columns,
+		header: theme.Header,
+		mark: theme.Marked,
+		playing: playing,
+	}
+
+	t := &ItemTable{
+		Table: tview.NewTable().SetContent(content).SetSelectable(true, false).SetFixed(1, 0).Select(1, 0),
+		content: content,
+	}
+	theme.ApplyTable(t.Table)
+
+	content.clicked = func(column Column) {
+		if column.Order == "" {
+			return
+		}
+
+		content.mu.Lock()
+		desc := column.Order == content.order && !content.desc
+		content.mu.Unlock()
+
+		t.Sort(column.Order, desc)
+	}
+
+	return t
+}
+
+// Append an item. It is only shown if it matches the filter, and is placed
+// by the current order.
+func (t *ItemTable) Append(item *Item) {
+	c := t.content
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	row := &itemRow{
+		item: item,
+		cells: IntoCells(item),
+		index: len(c.rows),
+	}
+	c.rows = append(c.rows, row)
+
+	if matchItem(item, c.filter) {
+		i, _ := slices.BinarySearchFunc(c.view, row, c.compare)
+		c.view = slices.Insert(c.view, i, row)
+		c.dirty = true
+	}
+}
+
+// Get every item, in the order they were loaded.
+func (t *ItemTable) Items() []*Item {
+	c := t.content
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	items := make([]*Item, len(c.rows))
+	for i, row := range c.rows {
+		items[i] = row.item
+	}
+
+	return items
+}
+
+// Get the items shown, i.e. not filtered out, in the order they are shown.
+func (t *ItemTable) Shown() []*Item {
+	c := t.content
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	items := make([]*Item, len(c.view))
+	for i, row := range c.view {
+		items[i] = row.item
+	}
+
+	return items
+}
+
+// Rebuild the rows shown, keeping the selected item (or group) selected if it
+// is still shown.
+func (t *ItemTable) update(change func(c *itemContent)) {
+	selected, _ := ItemAt(t.Table, t.selectedRow())
+	group, _ := groupAt(t.Table, t.selectedRow())
+
+	c := t.content
+	c.mu.Lock()
+	change(c)
+	c.rebuild()
+	c.mu.Unlock()
+
+	row := 1
+	for i := 1; i < t.GetRowCount(); i++ {
+		if item, ok := ItemAt(t.Table, i); ok && item == selected {
+			row = i
+			break
+		}
+		if g, ok := groupAt(t.Table, i); ok && group != nil && g.key == group.key {
+			row = i
+			break
+		}
+	}
+	t.Select(row, 0)
+}
+
+// Get the selected row.
+func (t *ItemTable) selectedRow() int {
+	row, _ := t.GetSelection()
+	return row
+}
+
+// Sort the items by name (see `ItemOrders`), optionally descending. Items
+// loaded later are placed by the same order.
+func (t *ItemTable) Sort(order string, desc bool) error {
+	if _, ok := itemOrders[order]; !ok && order != "loaded" {
+		return fmt.Errorf("unknown order: %s (try one of: %s)", order, strings.Join(ItemOrders(), ", "))
+	}
+
+	t.update(func(c *itemContent) {
+		c.order = order
+		c.desc = desc
+	})
+	return nil
+}
+
+// Show only the items that match a filter, by name, artist, or album. An
+// empty filter shows every item. Items loaded later are filtered too.
+func (t *ItemTable) Filter(filter string) {
+	t.update(func(c *itemContent) {
+		c.filter = strings.ToLower(strings.TrimSpace(filter))
+	})
+}
+
+// Group the items (see `ItemGroupings`), or show them flat. Items loaded
+// later are grouped too.
+func (t *ItemTable) Group(grouping string) error {
+	if _, ok := itemGroupings[grouping]; !ok {
+		return fmt.Errorf("unknown view: %s (try one of: %s)", grouping, strings.Join(ItemGroupings(), ", "))
+	}
+
+	t.update(func(c *itemContent) {
+		c.grouping = grouping
+	})
+	return nil
+}
+
+// Get the way that the items are grouped.
+func (t *ItemTable) Grouping() string {
+	c := t.content
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.grouping == "" {
+		return "flat"
+	}
+	return c.grouping
+}
+
+// Collapse or expand the group in a row. For an item, the group that it
+// belongs to is collapsed and selected instead.
+func (t *ItemTable) Fold(row int) {
+	c := t.content
+	c.mu.Lock()
+	line, ok := c.line(row - 1)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	group := line.group
+	if group == nil {
+		group = line.parent
+	}
+	if group == nil || group.depth == 0 {
+		return
+	}
+
+	t.update(func(c *itemContent) {
+		c.collapsed[group.key] = line.group == nil || !c.collapsed[group.key]
+	})
+
+	for i := 1; i < t.GetRowCount(); i++ {
+		if g, ok := groupAt(t.Table, i); ok && g.key == group.key {
+			t.Select(i, 0)
+			break
+		}
+	}
+}
+
+// Collapse or expand every group.
+func (t *ItemTable) FoldAll(collapse bool) {
+	t.update(func(c *itemContent) {
+		c.collapsed = map[string]bool{}
+		if !collapse {
+			return
+		}
+
+		c.dirty = true
+		c.regroup()
+		for _, line := range c.lines {
+			if line.group != nil {
+				c.collapsed[line.group.key] = true
+			}
+		}
+	})
+}
+
+// Mark or unmark the item in a row for multi-selection. Marking a group marks
+// every item in it, unless they are all marked already.
+func (t *ItemTable) Mark(row int) {
+	if group, ok := groupAt(t.Table, row); ok {
+		items := group.Items()
+
+		c := t.content
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		all := true
+		for _, item := range items {
+			all = all && c.marked[item]
+		}
+		for _, item := range items {
+			if all {
+				delete(c.marked, item)
+			} else {
+				c.marked[item] = true
+			}
+		}
+		return
+	}
+
+	item, ok := ItemAt(t.Table, row)
+	if !ok {
+		return
+	}
+
+	c := t.content
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.marked[item] {
+		delete(c.marked, item)
+	} else {
+		c.marked[item] = true
+	}
+}
+
+// Get the marked items, in the order they are shown.
+func (t *ItemTable) Marked() []*Item {
+	c := t.content
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	items := []*Item{}
+	for _, row := range c.view {
+		if c.marked[row.item] {
+			items = append(items, row.item)
+		}
+	}
+
+	return items
+}
+
+// Unmark every item.
+func (t *ItemTable) Unmark() {
+	c := t.content
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.marked = map[*Item]bool{}
+}
@@ -0,0 +1,291 @@
+package main
+
+// Key bindings. A binding maps a sequence of keys (e.g. `g g`) to the name of
+// an action. Keys are named like `j`, `G`, `Space`, `Enter`, `F1`, `Ctrl-V`, or
+// `Alt-x`, and sequences are space-delimited.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Bindings that every preset starts from.
+var defaultBindings = map[string]string{
+	"F1": "show-listing",
+	"F2": "show-logs",
+	"F3": "show-help",
+	"F4": "show-shows",
+	"1": "show-listing",
+	"2": "show-playlists",
+	"3": "show-albums",
+	"4": "show-shows",
+	"5": "show-search",
+	"6": "show-queue",
+	"7": "show-devices",
+	"8": "show-logs",
+	"9": "show-help",
+	"Alt-1": "show-listing",
+	"Alt-2": "show-playlists",
+	"Alt-3": "show-albums",
+	"Alt-4": "show-shows",
+	"Alt-5": "show-search",
+	"Alt-6": "show-queue",
+	"Alt-7": "show-devices",
+	"Alt-8": "show-logs",
+	"Alt-9": "show-help",
+	"]": "next-tab",
+	"[": "previous-tab",
+	"r": "refresh",
+	"v": "view",
+	"z": "fold",
+	"d": "details",
+	"a": "open-artist",
+	"A": "open-album",
+	"y": "copy-uri",
+	"Y": "copy-link",
+	"m": "mark",
+	"M": "unmark-all",
+	"K": "keep",
+	"L": "list",
+	"?": "show-help",
+	"q": "quit",
+	"Ctrl-V": "paste",
+	"p": "toggle",
+	"f": "next",
+	"b": "previous",
+	"Enter": "select",
+	"Space": "queue",
+	"i": "jump-to-playing",
+	":": "command-line",
+	"j": "down",
+	"k": "up",
+	"Down": "down",
+	"Up": "up",
+	"g": "top",
+	"G": "bottom",
+	"Home": "top",
+	"End": "bottom",
+	"PgDn": "page-down",
+	"PgUp": "page-up",
+	"Ctrl-F": "page-down",
+	"Ctrl-B": "page-up",
+}
+
+// Presets of bindings, applied on top of the default bindings. An empty
+// action removes a default binding.
+var keymapPresets = map[string]map[string]string{
+	"default": {},
+	"vim": {
+		// Digits are counts, so tabs are only switched with `Alt`.
+		"1": "",
+		"2": "",
+		"3": "",
+		"4": "",
+		"5": "",
+		"6": "",
+		"7": "",
+		"8": "",
+		"9": "",
+		"g t": "next-tab",
+		"g T": "previous-tab",
+		"g": "",
+		"g g": "top",
+		"Ctrl-D": "page-down",
+		"Ctrl-U": "page-up",
+		"Z Z": "quit",
+		"Z Q": "quit",
+	},
+	"emacs": {
+		"q": "",
+		"?": "",
+		"Ctrl-F": "",
+		"Ctrl-B": "",
+		"Ctrl-N": "down",
+		"Ctrl-P": "up",
+		"Alt-<": "top",
+		"Alt->": "bottom",
+		"Ctrl-V": "page-down",
+		"Alt-v": "page-up",
+		"Ctrl-Y": "paste",
+		"Alt-x": "command-line",
+		"Ctrl-X Ctrl-C": "quit",
+	},
+}
+
+// Get the names of the available presets.
+func KeymapPresets() []string {
+	names := []string{}
+	for name := range keymapPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Get the name of a key, as used in bindings.
+func KeyName(ev *tcell.EventKey) string {
+	prefix := ""
+	if ev.Modifiers()&tcell.ModAlt != 0 {
+		prefix = "Alt-"
+	}
+
+	switch ev.Key() {
+	case tcell.KeyRune:
+		if ev.Rune() == ' ' {
+			return prefix + "Space"
+		}
+		return prefix + string(ev.Rune())
+
+	case tcell.KeyBackspace2:
+		return prefix + "Backspace"
+	}
+
+	if name, ok := tcell.KeyNames[ev.Key()]; ok {
+		return prefix + name
+	}
+
+	return prefix + fmt.Sprintf("Key%d", ev.Key())
+}
+
+// Normalize a sequence of keys, e.g. `g  g` to `g g`.
+func normalizeSequence(seq string) string {
+	return strings.Join(strings.Fields(seq), " ")
+}
+
+// Active key bindings, along with the state of a partially typed sequence.
+type Keymap struct {
+	bindings map[string]string
+
+	// Every proper prefix of a bound sequence.
+	prefixes map[string]bool
+
+	// Keys typed so far of a sequence.
+	pending []string
+
+	// Count typed before a sequence, e.g. `5` in `5j`, or 0 if none.
+	count int
+}
+
+// Create a keymap from a preset and overrides. Actions are validated against
+// the names of known actions.
+func NewKeymap(preset string, overrides map[string]string, known func(string) bool) (*Keymap, error) {
+	bindings, ok := keymapPresets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown keymap preset: %s (try one of: %s)", preset, strings.Join(KeymapPresets(), ", "))
+	}
+
+	km := &Keymap{
+		bindings: map[string]string{},
+		prefixes: map[string]bool{},
+	}
+
+	for _, layer := range []map[string]string{defaultBindings, bindings, overrides} {
+		for seq, action := range layer {
+			seq = normalizeSequence(seq)
+			if seq == "" {
+				return nil, fmt.Errorf("empty key sequence bound to %s", action)
+			}
+
+			if action == "" {
+				delete(km.bindings, seq)
+				continue
+			}
+			if !known(action) {
+				return nil, fmt.Errorf("unknown action bound to %s: %s", seq, action)
+			}
+			km.bindings[seq] = action
+		}
+	}
+
+	for seq := range km.bindings {
+		keys := strings.Split(seq, " ")
+		for i := 1; i < len(keys); i++ {
+			km.prefixes[strings.Join(keys[:i], " ")] = true
+		}
+	}
+
+	return km, nil
+}
+
+// Check if counts can be typed before a sequence, e.g. `5j`. Counts are typed
+// with number keys, so only if none are bound (e.g. with the vim preset).
+func (km *Keymap) Counts() bool {
+	for digit := '1'; digit <= '9'; digit++ {
+		if _, bound := km.bindings[string(digit)]; bound || km.prefixes[string(digit)] {
+			return false
+		}
+	}
+	return true
+}
+
+// Reset a partially typed sequence.
+func (km *Keymap) Reset() {
+	km.pending = nil
+	km.count = 0
+}
+
+// Get the partially typed sequence, including any count, for display.
+func (km *Keymap) Pending() string {
+	keys := km.pending
+	if km.count != 0 {
+		keys = append([]string{strconv.Itoa(km.count)}, keys...)
+	}
+	return strings.Join(keys, " ")
+}
+
+// Handle a key. If it completes a sequence, the bound action and count
+// (at least 1) are returned. `consumed` reports if the key was part of a
+// sequence or count; if not, it should be handled as normal.
+func (km *Keymap) Handle(ev *tcell.EventKey) (action string, count int, consumed bool) {
+	key := KeyName(ev)
+
+	// Counts are typed before a sequence, and only with digits that are
+	// not bound themselves.
+	if len(km.pending) == 0 && ev.Key() == tcell.KeyRune && '0' <= ev.Rune() && ev.Rune() <= '9' {
+		_, bound := km.bindings[key]
+		if !bound && !km.prefixes[key] && (ev.Rune() != '0' || km.count != 0) {
+			km.count = km.count*10 + int(ev.Rune()-'0')
+			return "", 0, true
+		}
+	}
+
+	seq := strings.Join(append(km.pending, key), " ")
+
+	if action, ok := km.bindings[seq]; ok {
+		count = km.count
+		if count == 0 {
+			count = 1
+		}
+		km.Reset()
+		return action, count, true
+	}
+
+	if km.prefixes[seq] {
+		km.pending = append(km.pending, key)
+		return "", 0, true
+	}
+
+	// An unbound key cancels a partially typed sequence, and is consumed
+	// with it.
+	partial := len(km.pending) != 0 || km.count != 0
+	km.Reset()
+	return "", 0, partial
+}
+
+// Get the sequences bound to an action, sorted.
+func (km *Keymap) Bindings(action string) []string {
+	seqs := []string{}
+	for seq, bound := range km.bindings {
+		if bound == action {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Strings(seqs)
+
+	return seqs
+}
@@ -1,13 +1,12 @@
 package main
 
-// Manager for the track listing.
+// Managers for listings of items.
 
 import (
 	"context"
 	"time"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/zmb3/spotify/v2"
 	"github.com/rivo/tview"
 )
 
@@ -16,31 +15,35 @@ import (
 // NOTE: `ch` is for receiving tracks from the `FetchingManager`.
 //       `quit` is for receiving a termination signal from the `ListingManager`.
 //       `done` is for the reverse, sending a termination signal to the `ListingManager`.
-func listingWorker(listing *tview.Table, ch <-chan *spotify.FullTrack, quit <-chan bool, done chan<- bool) {
+func listingWorker(listing *ItemTable, ch <-chan *Item, quit <-chan bool, done chan<- bool) {
+loading:
 	for {
 		select {
 
 		// Terminate loader.
 		case <-quit:
-			break
+			break loading
 
 		default:
+			// Scrolling (e.g. with the mouse wheel) loads tracks
+			// too, not just moving the selection.
 			cursor, _ := listing.GetSelection()
+			offset, _ := listing.GetOffset()
+			_, _, _, height := listing.GetInnerRect()
+			cursor = max(cursor, offset+height)
 			length := listing.GetRowCount()
 
 			if (length - cursor) < loadLookahead {
-				track, ok := <- ch
+				item, ok := <- ch
 
 				// Channel is closed; terminate now.
 				if !ok {
 					log.Trace("no more tracks to load")
-					break
+					break loading
 				}
 
-				log.Tracef("loading %s...", track.Name)
-				for col, cell := range IntoCells(track) {
-					listing.SetCell(length, col, cell)
-				}
+				log.Tracef("loading %s...", item.Name())
+				listing.Append(item)
 
 				continue
 			}
@@ -54,37 +57,52 @@ func listingWorker(listing *tview.Table, ch <-chan *spotify.FullTrack, quit <-ch
 }
 
 // Manager for appending tracks to the listing.
-func ListingManager(ctx context.Context, listing *tview.Table, ch <-chan *spotify.FullTrack) {
+func ListingManager(ctx context.Context, listing *ItemTable, ch <-chan *Item) {
 	// Load first N tracks eagerly.
 	log.Tracef("loading %d tracks...", loadEager)
 	for i := 0; i < loadEager; i++ {
-		track := <- ch
-		for col, cell := range IntoCells(track) {
-			listing.SetCell(i, col, cell)
+		item, ok := <- ch
+		if !ok {
+			log.Tracef("loaded all %d tracks", i)
+			return
 		}
+		listing.Append(item)
 	}
 	log.Tracef("loaded %d tracks", loadEager)
 
-	// Load more tracks lazily.
-	quit := make(chan bool)
-	done := make(chan bool)
+	// Load more tracks lazily. Both channels are buffered so that neither
+	// side blocks if the other has already terminated.
+	quit := make(chan bool, 1)
+	done := make(chan bool, 1)
 	go listingWorker(listing, ch, quit, done)
 
-	for {
-		select {
+	select {
 
-		// Somehow track loader is terminating faster than this loop.
-		case <-done:
-			log.Trace("track renderer stopped running")
-			break
+	// Somehow track loader is terminating faster than this loop.
+	case <-done:
+		log.Trace("track renderer stopped running")
 
-		// Context is cancelled; terminate track loader.
-		case <-ctx.Done():
-			break
+	// Context is cancelled; terminate track loader.
+	case <-ctx.Done():
+		quit <- true
 
-		}
 	}
-
-	quit <- true
 }
 
+// Manager for loading items into a table, e.g. a show's episodes. Unlike the
+// track listing, every item is loaded eagerly. Terminates once all items are
+// loaded or the context is cancelled.
+func ItemsManager(ctx context.Context, app *tview.Application, table *ItemTable, ch <-chan *Item) {
+	for item := range ch {
+		log.Tracef("loading %s...", item.Name())
+		app.QueueUpdateDraw(func() {
+			// The table may have been reloaded since this item
+			// was fetched.
+			if ctx.Err() != nil {
+				return
+			}
+
+			table.Append(item)
+		})
+	}
+}
@@ -2,46 +2,149 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"os"
 
-	"github.com/zmb3/spotify/v2"
+	log "github.com/sirupsen/logrus"
 )
 
 func main() {
-	ctx := ConfiguredContext()
-	ctx, cancel := context.WithCancel(ctx)
+	// Validate configuration before authenticating.
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "nspotify:", err)
+		os.Exit(exitUsage)
+	}
+
+	// Print configuration mode.
+	if cfg.PrintConfig {
+		if err := PrintConfig(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, "nspotify:", err)
+			os.Exit(exitFailure)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 
 	// TODO: Display version mode.
-	// version, ok := ctx.Value("version").(string)
-	// if ok && (version != "") {
-	// 	fmt.Println("nspotify %s", version)
+	// if cfg.Version != "" {
+	// 	fmt.Println("nspotify %s", cfg.Version)
 	// 	cancel()
 	// 	return
 	// }
 
+	// Play from STDIN mode is the same as the `play` subcommand without
+	// arguments. It only replaces the interactive interface, so that other
+	// modes (e.g. an export over SSH) are unaffected by a piped STDIN.
+	args := flag.Args()
+	if len(args) == 0 && cfg.Export == "" && cfg.Import == "" && cfg.Device != "" && PipedStdin() {
+		args = []string{"play"}
+	}
+
+	// Validate subcommand before authenticating.
+	var cmd *Command
+	if len(args) > 0 {
+		cmd, err = ParseCommand(args)
+		if err != nil {
+			cancel()
+			os.Exit(ExitCode(err))
+		}
+	}
+
+	// Subcommand mode, for subcommands that do not need authentication.
+	if cmd != nil && cmd.Local() {
+		code := cmd.Run(ctx, nil, cfg)
+		cancel()
+		os.Exit(code)
+	}
+
 	// Authenticate with Spotify.
-	cli := Authenticate(ctx)
+	cli := Authenticate(ctx, cfg)
 	// TODO: incorporate rate limiting? "set the AutoRetry field on the Client struct to true"
 
+	// Subcommand mode.
+	if cmd != nil {
+		code := cmd.Run(ctx, cli, cfg)
+		cancel()
+		os.Exit(code)
+	}
+
+	// Export mode.
+	if cfg.Export != "" {
+		code := ExitCode(RunExport(ctx, cli, cfg))
+		cancel()
+		os.Exit(code)
+	}
+
+	// Import mode.
+	if cfg.Import != "" {
+		code := ExitCode(RunImport(ctx, cli, cfg))
+		cancel()
+		os.Exit(code)
+	}
+
 	// List devices mode.
-	sdev, ok := ctx.Value("device").(string)
-	dev := spotify.ID(sdev)
-	if !ok || (dev == "") {
-		ListDevices(ctx, cli)
+	if cfg.Device == "" {
+		if err := ListDevices(ctx, cli); err != nil {
+			log.WithError(err).Fatal("failed to list devices")
+		}
 		cancel()
 		return
 	}
 
+	// Without an explicit `-http-token`, reuse a random token from the
+	// cache. Failing that, the HTTP API would be unusable.
+	httpToken := cfg.HTTPToken
+	if cfg.HTTPPort != 0 && httpToken == "" {
+		httpToken, err = CachedHTTPToken(cfg.CacheDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "nspotify:", err)
+			cancel()
+			os.Exit(exitFailure)
+		}
+	}
+
 	// Fetch user tracks with Spotify client. Will continue to run in
 	// background.
-	fetchCh := make(chan *spotify.FullTrack, fetchingBuffer)
+	fetchCh := make(chan *Item, fetchingBuffer)
 	go FetchingManager(ctx, cli, fetchCh)
 
+	// Poll player state. Will continue to run in background.
+	state := NewStateWatcher()
+	go state.Run(ctx, cli)
+
 	evCh := make(chan *Event)
-	go EventsManager(ctx, cli, evCh)
+	go EventsManager(ctx, cli, cfg.Device, evCh, state)
+
+	// Listen for control requests. Will continue to run in background.
+	if cfg.Socket != "" {
+		go ControlManager(ctx, cfg.Socket, evCh, state)
+	}
+
+	// Serve HTTP API. Will continue to run in background.
+	if cfg.HTTPPort != 0 {
+		go HTTPManager(ctx, cli, cfg.HTTPPort, httpToken, evCh, state)
+	}
+
+	// Record play history. Will continue to run in background, and is
+	// waited on so that the last play is recorded.
+	recorded := make(chan bool)
+	if cfg.History != "" {
+		go HistoryManager(ctx, cli, cfg.History, state, recorded)
+	} else {
+		close(recorded)
+	}
+
+	// Expose MPRIS interface. Will continue to run in background.
+	if cfg.MPRIS {
+		go MPRISManager(ctx, evCh, state)
+	}
 
 	// Run terminal application. Will block until application terminates.
-	Start(ctx, fetchCh, evCh)
+	Start(ctx, cli, cfg, state, fetchCh, evCh)
 
 	cancel()
+	<-recorded
 }
-
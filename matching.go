@@ -0,0 +1,185 @@
+package main
+
+// Matching tracks by metadata, e.g. to find an imported track on Spotify or
+// to find duplicates. Names are compared after normalizing away case,
+// punctuation, and decorations like `(Remastered 2011)`.
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Decorations of a track name that do not distinguish recordings, e.g.
+// `(Remastered 2011)` or ` - Radio Edit`.
+var nameDecoration = regexp.MustCompile(`(?i)\s*(\([^)]*\)|\[[^]]*\]|\s-\s.*(remaster|edit|version|mix|mono|stereo|live|deluxe).*)$`)
+
+// Qualifiers of a decoration that distinguish recordings, e.g. `(Live)` or
+// ` - Radio Edit`.
+var recordingQualifier = regexp.MustCompile(`(?i)\b(live|(re)?mix|version|edit|acoustic|demo|instrumental)\b`)
+
+// Remasters, which are the same recording even as a `Remastered Version`.
+var remasterQualifier = regexp.MustCompile(`(?i)\bremaster(ed)?( version)?\b`)
+
+// Leading articles of an artist's name, e.g. `The`.
+var leadingArticle = regexp.MustCompile(`(?i)^(the|a|an)\s+`)
+
+// Normalize text for comparison: fold case, and replace punctuation with
+// spaces.
+func normalizeText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r):
+			b.WriteRune(unicode.ToLower(r))
+		case r == '&':
+			b.WriteString(" and ")
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Normalize the name of a track, stripping decorations.
+func normalizeName(name string) string {
+	for {
+		stripped := nameDecoration.ReplaceAllString(name, "")
+		if stripped == name || stripped == "" {
+			break
+		}
+		name = stripped
+	}
+
+	return normalizeText(name)
+}
+
+// Normalize the name of a track, stripping only the decorations that do not
+// distinguish recordings, e.g. `(2011 Remaster)` but not `(Live)`.
+func normalizeRecording(name string) string {
+	for {
+		loc := nameDecoration.FindStringIndex(name)
+		if loc == nil || loc[0] == 0 {
+			break
+		}
+
+		decoration := remasterQualifier.ReplaceAllString(name[loc[0]:], "")
+		if recordingQualifier.MatchString(decoration) {
+			break
+		}
+		name = name[:loc[0]]
+	}
+
+	return normalizeText(name)
+}
+
+// Normalize the name of an artist, stripping a leading article.
+func normalizeArtist(artist string) string {
+	return normalizeText(leadingArticle.ReplaceAllString(strings.TrimSpace(artist), ""))
+}
+
+// Similarity of two normalized texts, from 0 to 1: the Dice coefficient of
+// their words.
+func similarity(a, b string) float64 {
+	if a == b && a != "" {
+		return 1
+	}
+
+	as, bs := strings.Fields(a), strings.Fields(b)
+	total := len(as) + len(bs)
+
+	words := map[string]int{}
+	for _, word := range as {
+		words[word]++
+	}
+
+	common := 0
+	for _, word := range bs {
+		if words[word] > 0 {
+			words[word]--
+			common++
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+// Closeness of two durations in milliseconds, from 0 to 1. Durations within
+// 3 seconds are the same, and durations 30 seconds apart are unrelated.
+func durationCloseness(a, b int) float64 {
+	diff := a - b
+	if diff < 0 {
+		diff = -diff
+	}
+
+	switch {
+	case diff <= 3000:
+		return 1
+	case diff >= 30000:
+		return 0
+	}
+	return 1 - float64(diff-3000)/27000
+}
+
+// Metadata of a track to match.
+type trackMetadata struct {
+	Name     string
+	Artists  []string
+	Album    string
+	Duration int
+}
+
+// Get the metadata of a track on Spotify.
+func fullTrackMetadata(track *spotify.FullTrack) trackMetadata {
+	meta := trackMetadata{
+		Name: track.Name,
+		Album: track.Album.Name,
+		Duration: int(track.Duration),
+	}
+	for _, artist := range track.Artists {
+		meta.Artists = append(meta.Artists, artist.Name)
+	}
+
+	return meta
+}
+
+// Best similarity of any artist of a track to any artist of another.
+func artistSimilarity(a, b []string) float64 {
+	best := 0.0
+	for _, x := range a {
+		for _, y := range b {
+			best = max(best, similarity(normalizeArtist(x), normalizeArtist(y)))
+		}
+	}
+
+	return best
+}
+
+// Score how likely a candidate is the same recording as a track, from 0 to
+// 1. The name counts most, then the artists, then the duration and album
+// if both are known.
+func matchScore(want, candidate trackMetadata) float64 {
+	score := 0.5 * similarity(normalizeName(want.Name), normalizeName(candidate.Name))
+	weight := 0.5
+
+	if len(want.Artists) != 0 {
+		score += 0.3 * artistSimilarity(want.Artists, candidate.Artists)
+		weight += 0.3
+	}
+	if want.Duration != 0 && candidate.Duration != 0 {
+		score += 0.15 * durationCloseness(want.Duration, candidate.Duration)
+		weight += 0.15
+	}
+	if want.Album != "" {
+		score += 0.05 * similarity(normalizeName(want.Album), normalizeName(candidate.Album))
+		weight += 0.05
+	}
+
+	return score / weight
+}
@@ -0,0 +1,400 @@
+package main
+
+// MPRIS interface on the D-Bus session bus, for desktop media widgets and
+// media keys. See `https://specifications.freedesktop.org/mpris-spec/latest/`.
+//
+// The session bus is found through `DBUS_SESSION_BUS_ADDRESS`, so this can be
+// exercised against a private bus started with
+// `dbus-daemon --session --print-address`.
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	// Well-known bus name of this player.
+	mprisName = "org.mpris.MediaPlayer2.nspotify"
+
+	// Object path of the MPRIS interfaces.
+	mprisPath = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+
+	// MPRIS root interface.
+	mprisRootIface = "org.mpris.MediaPlayer2"
+
+	// MPRIS player interface.
+	mprisPlayerIface = "org.mpris.MediaPlayer2.Player"
+
+	// Track ID signifying that nothing is playing.
+	mprisNoTrack = dbus.ObjectPath("/org/mpris/MediaPlayer2/TrackList/NoTrack")
+
+	// Difference (in microseconds) between the expected and the polled
+	// position that signals that the end user seeked outside of MPRIS.
+	mprisSeekThreshold = 3 * stateTimeout * 1000000
+)
+
+// Implementation of the MPRIS root interface.
+type mprisRoot struct{}
+
+// Bring the player's interface to the front. Not supported.
+func (root *mprisRoot) Raise() *dbus.Error {
+	return nil
+}
+
+// Quit the player. Not supported.
+func (root *mprisRoot) Quit() *dbus.Error {
+	return nil
+}
+
+// Implementation of the MPRIS player interface.
+type mprisPlayer struct {
+	tx    chan<- *Event
+	state *StateWatcher
+}
+
+// Request that the next item play.
+func (player *mprisPlayer) Next() *dbus.Error {
+	player.tx <- RequestPlayNext()
+	return nil
+}
+
+// Request that the previous item play.
+func (player *mprisPlayer) Previous() *dbus.Error {
+	player.tx <- RequestPlayPrevious()
+	return nil
+}
+
+// Request that playback pause.
+func (player *mprisPlayer) Pause() *dbus.Error {
+	player.tx <- RequestPause()
+	return nil
+}
+
+// Request that playback toggle.
+func (player *mprisPlayer) PlayPause() *dbus.Error {
+	player.tx <- RequestToggle()
+	return nil
+}
+
+// Request that playback stop. Spotify has no concept of stopping, so this
+// pauses.
+func (player *mprisPlayer) Stop() *dbus.Error {
+	player.tx <- RequestPause()
+	return nil
+}
+
+// Request that playback play.
+func (player *mprisPlayer) Play() *dbus.Error {
+	player.tx <- RequestPlay()
+	return nil
+}
+
+// Request that playback seek by an offset (in microseconds). Seeking past the
+// end plays the next item. Exported as `Seek`, but named differently to avoid
+// confusion with `io.Seeker`.
+func (player *mprisPlayer) SeekBy(offset int64) *dbus.Error {
+	np := player.state.Latest()
+	if np.Item == nil {
+		return nil
+	}
+
+	position := np.EstimatedProgress() + int(offset/1000)
+	if position < 0 {
+		position = 0
+	}
+	if position > np.Item.Duration() {
+		player.tx <- RequestPlayNext()
+		return nil
+	}
+
+	player.tx <- RequestSeek(position)
+	return nil
+}
+
+// Request that playback seek to a position (in microseconds). Ignored if the
+// track has since changed.
+func (player *mprisPlayer) SetPosition(track dbus.ObjectPath, position int64) *dbus.Error {
+	np := player.state.Latest()
+	if np.Item == nil || mprisTrackID(np.Item) != track {
+		return nil
+	}
+	if position < 0 || int(position/1000) > np.Item.Duration() {
+		return nil
+	}
+
+	player.tx <- RequestSeek(int(position / 1000))
+	return nil
+}
+
+// Request that a URI be played.
+func (player *mprisPlayer) OpenUri(s string) *dbus.Error {
+	uri, err := ParseURI(s)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	player.tx <- RequestPlayURI(uri.Spotify())
+	return nil
+}
+
+// Methods of `mprisPlayer` that are exported under different names.
+var mprisPlayerMethods = map[string]string{
+	"SeekBy": "Seek",
+}
+
+// Get the introspection data for methods, accounting for methods that are
+// exported under different names.
+func mprisIntrospectMethods(v any, names map[string]string) []introspect.Method {
+	methods := introspect.Methods(v)
+	for i := range methods {
+		if name, ok := names[methods[i].Name]; ok {
+			methods[i].Name = name
+		}
+	}
+	return methods
+}
+
+// Get the MPRIS track ID of an item.
+func mprisTrackID(item *Item) dbus.ObjectPath {
+	uri, err := ParseURI(string(item.URI()))
+	if err != nil {
+		return mprisNoTrack
+	}
+	return dbus.ObjectPath(fmt.Sprintf("/org/nspotify/%s/%s", uri.Type, uri.ID))
+}
+
+// Get the MPRIS metadata of an item.
+func mprisMetadata(item *Item) map[string]dbus.Variant {
+	if item == nil {
+		return map[string]dbus.Variant{
+			"mpris:trackid": dbus.MakeVariant(mprisNoTrack),
+		}
+	}
+
+	metadata := map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(mprisTrackID(item)),
+		"mpris:length": dbus.MakeVariant(int64(item.Duration()) * 1000),
+		"xesam:title": dbus.MakeVariant(item.Name()),
+	}
+
+	if uri, err := ParseURI(string(item.URI())); err == nil {
+		metadata["xesam:url"] = dbus.MakeVariant(uri.Link())
+	}
+
+	if item.Episode != nil {
+		metadata["xesam:album"] = dbus.MakeVariant(item.Episode.Show.Name)
+		metadata["xesam:artist"] = dbus.MakeVariant([]string{item.Episode.Show.Publisher})
+		if len(item.Episode.Images) > 0 {
+			metadata["mpris:artUrl"] = dbus.MakeVariant(item.Episode.Images[0].URL)
+		}
+	} else {
+		artists := []string{}
+		for _, artist := range item.Track.Artists {
+			artists = append(artists, artist.Name)
+		}
+		metadata["xesam:artist"] = dbus.MakeVariant(artists)
+		metadata["xesam:album"] = dbus.MakeVariant(item.Track.Album.Name)
+		metadata["xesam:trackNumber"] = dbus.MakeVariant(int32(item.Track.TrackNumber))
+		if len(item.Track.Album.Images) > 0 {
+			metadata["mpris:artUrl"] = dbus.MakeVariant(item.Track.Album.Images[0].URL)
+		}
+	}
+
+	return metadata
+}
+
+// Get the MPRIS playback status of a player state.
+func mprisPlaybackStatus(np *NowPlaying) string {
+	if np.Item == nil {
+		return "Stopped"
+	}
+	if np.Playing {
+		return "Playing"
+	}
+	return "Paused"
+}
+
+// Export the MPRIS interfaces on a bus connection.
+func exportMPRIS(conn *dbus.Conn, tx chan<- *Event, state *StateWatcher) (*prop.Properties, error) {
+	root := &mprisRoot{}
+	player := &mprisPlayer{tx: tx, state: state}
+
+	if err := conn.Export(root, mprisPath, mprisRootIface); err != nil {
+		return nil, err
+	}
+	if err := conn.ExportWithMap(player, mprisPlayerMethods, mprisPath, mprisPlayerIface); err != nil {
+		return nil, err
+	}
+
+	props, err := prop.Export(conn, mprisPath, prop.Map{
+		mprisRootIface: {
+			"CanQuit": {Value: false, Emit: prop.EmitConst},
+			"CanRaise": {Value: false, Emit: prop.EmitConst},
+			"HasTrackList": {Value: false, Emit: prop.EmitConst},
+			"Identity": {Value: "nspotify", Emit: prop.EmitConst},
+			"SupportedUriSchemes": {Value: []string{"spotify", "https"}, Emit: prop.EmitConst},
+			"SupportedMimeTypes": {Value: []string{}, Emit: prop.EmitConst},
+		},
+		mprisPlayerIface: {
+			"PlaybackStatus": {Value: "Stopped", Emit: prop.EmitTrue},
+			"LoopStatus": {Value: "None", Emit: prop.EmitTrue},
+			"Rate": {Value: 1.0, Emit: prop.EmitConst},
+			"Shuffle": {Value: false, Emit: prop.EmitTrue},
+			"Metadata": {Value: mprisMetadata(nil), Emit: prop.EmitTrue},
+			"Volume": {
+				Value: 1.0,
+				Writable: true,
+				Emit: prop.EmitTrue,
+				Callback: func(change *prop.Change) *dbus.Error {
+					volume := change.Value.(float64)
+					if volume < 0 {
+						volume = 0
+					}
+					if volume > 1 {
+						volume = 1
+					}
+					tx <- RequestVolume(int(volume * 100))
+					return nil
+				},
+			},
+			"Position": {Value: int64(0), Emit: prop.EmitFalse},
+			"MinimumRate": {Value: 1.0, Emit: prop.EmitConst},
+			"MaximumRate": {Value: 1.0, Emit: prop.EmitConst},
+			"CanGoNext": {Value: true, Emit: prop.EmitConst},
+			"CanGoPrevious": {Value: true, Emit: prop.EmitConst},
+			"CanPlay": {Value: true, Emit: prop.EmitConst},
+			"CanPause": {Value: true, Emit: prop.EmitConst},
+			"CanSeek": {Value: true, Emit: prop.EmitConst},
+			"CanControl": {Value: true, Emit: prop.EmitConst},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	node := &introspect.Node{
+		Name: string(mprisPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name: mprisRootIface,
+				Methods: introspect.Methods(root),
+				Properties: props.Introspection(mprisRootIface),
+			},
+			{
+				Name: mprisPlayerIface,
+				Methods: mprisIntrospectMethods(player, mprisPlayerMethods),
+				Properties: props.Introspection(mprisPlayerIface),
+				Signals: []introspect.Signal{
+					{Name: "Seeked", Args: []introspect.Arg{{Name: "Position", Type: "x"}}},
+				},
+			},
+		},
+	}
+	err = conn.Export(introspect.NewIntrospectable(node), mprisPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		return nil, err
+	}
+
+	return props, nil
+}
+
+// Update the MPRIS properties from a player state. Properties are only set if
+// changed, so that change signals are only emitted if needed.
+func updateMPRIS(conn *dbus.Conn, props *prop.Properties, prev, np *NowPlaying) {
+	status := mprisPlaybackStatus(np)
+	if props.GetMust(mprisPlayerIface, "PlaybackStatus").(string) != status {
+		props.SetMust(mprisPlayerIface, "PlaybackStatus", status)
+	}
+
+	// Nothing playing before and after is unchanged, so that an idle
+	// player does not signal on every poll.
+	changed := (prev.Item == nil) != (np.Item == nil)
+	if prev.Item != nil && np.Item != nil {
+		changed = prev.Item.URI() != np.Item.URI()
+	}
+	if changed {
+		props.SetMust(mprisPlayerIface, "Metadata", mprisMetadata(np.Item))
+	}
+
+	if props.GetMust(mprisPlayerIface, "Shuffle").(bool) != np.Shuffle {
+		props.SetMust(mprisPlayerIface, "Shuffle", np.Shuffle)
+	}
+
+	loop := "None"
+	switch np.Repeat {
+	case "track":
+		loop = "Track"
+	case "context":
+		loop = "Playlist"
+	}
+	if props.GetMust(mprisPlayerIface, "LoopStatus").(string) != loop {
+		props.SetMust(mprisPlayerIface, "LoopStatus", loop)
+	}
+
+	volume := float64(np.Device.Volume) / 100
+	if props.GetMust(mprisPlayerIface, "Volume").(float64) != volume {
+		props.SetMust(mprisPlayerIface, "Volume", volume)
+	}
+
+	position := int64(np.Progress) * 1000
+	props.SetMust(mprisPlayerIface, "Position", position)
+
+	// Signal a seek if the position jumped, rather than progressing as
+	// expected since the last poll.
+	if prev.Item != nil && np.Item != nil && prev.Item.URI() == np.Item.URI() {
+		expected := int64(prev.Progress) * 1000
+		if prev.Playing {
+			expected += np.Fetched.Sub(prev.Fetched).Microseconds()
+		}
+
+		diff := position - expected
+		if diff < -mprisSeekThreshold || mprisSeekThreshold < diff {
+			conn.Emit(mprisPath, mprisPlayerIface+".Seeked", position)
+		}
+	}
+}
+
+// Manager for the MPRIS interface. Terminates once the context is cancelled.
+func MPRISManager(ctx context.Context, tx chan<- *Event, state *StateWatcher) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		log.WithError(err).Warn("failed to connect to session bus; MPRIS is unavailable")
+		return
+	}
+	defer conn.Close()
+
+	props, err := exportMPRIS(conn, tx, state)
+	if err != nil {
+		log.WithError(err).Error("failed to export MPRIS interfaces")
+		return
+	}
+
+	reply, err := conn.RequestName(mprisName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		log.WithError(err).Errorf("failed to claim bus name: %s", mprisName)
+		return
+	}
+	log.Debugf("claimed bus name: %s", mprisName)
+
+	ch := state.Subscribe()
+	prev := &NowPlaying{}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Trace("context closed, terminating MPRIS manager")
+			return
+
+		case np := <-ch:
+			updateMPRIS(conn, props, prev, np)
+			prev = np
+		}
+	}
+}
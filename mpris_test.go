@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/zmb3/spotify/v2"
+)
+
+// Start a private session bus, stopped once the test finishes. Returns its
+// address. Skips the test if `dbus-daemon` is not installed.
+func privateBus(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read the bus address: %v", err)
+	}
+
+	return strings.TrimSpace(address)
+}
+
+// Connect to a bus, disconnecting once the test finishes.
+func connectBus(t *testing.T, address string) *dbus.Conn {
+	t.Helper()
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("failed to connect to the bus: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	return conn
+}
+
+// Collect the properties changed by the signals received within a timeout.
+func changedProperties(signals <-chan *dbus.Signal, timeout time.Duration) map[string]dbus.Variant {
+	changed := map[string]dbus.Variant{}
+	deadline := time.After(timeout)
+	for {
+		select {
+		case signal := <-signals:
+			if len(signal.Body) < 2 {
+				continue
+			}
+			properties, _ := signal.Body[1].(map[string]dbus.Variant)
+			for name, value := range properties {
+				changed[name] = value
+			}
+		case <-deadline:
+			return changed
+		}
+	}
+}
+
+func TestMPRIS(t *testing.T) {
+	address := privateBus(t)
+
+	conn := connectBus(t, address)
+	props, err := exportMPRIS(conn, make(chan *Event, 8), NewStateWatcher())
+	if err != nil {
+		t.Fatalf("failed to export MPRIS interfaces: %v", err)
+	}
+	if reply, err := conn.RequestName(mprisName, dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("failed to claim bus name: %v", err)
+	}
+
+	client := connectBus(t, address)
+	err = client.AddMatchSignal(
+		dbus.WithMatchObjectPath(mprisPath),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"))
+	if err != nil {
+		t.Fatalf("failed to match signals: %v", err)
+	}
+	signals := make(chan *dbus.Signal, 16)
+	client.Signal(signals)
+
+	player := client.Object(mprisName, mprisPath)
+	get := func(name string) any {
+		t.Helper()
+		value, err := player.GetProperty(mprisPlayerIface + "." + name)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", name, err)
+		}
+		return value.Value()
+	}
+	title := func(metadata any) any {
+		return metadata.(map[string]dbus.Variant)["xesam:title"].Value()
+	}
+
+	// Nothing is playing at first.
+	if status := get("PlaybackStatus"); status != "Stopped" {
+		t.Errorf("PlaybackStatus is %v, expected Stopped", status)
+	}
+	metadata := get("Metadata").(map[string]dbus.Variant)
+	if trackID := metadata["mpris:trackid"].Value(); trackID != mprisNoTrack {
+		t.Errorf("mpris:trackid is %v, expected %v", trackID, mprisNoTrack)
+	}
+
+	// An idle player signals nothing after the first poll.
+	idle := &NowPlaying{Fetched: time.Now()}
+	updateMPRIS(conn, props, &NowPlaying{}, idle)
+	changedProperties(signals, 200*time.Millisecond)
+
+	again := &NowPlaying{Fetched: idle.Fetched.Add(2 * time.Second)}
+	updateMPRIS(conn, props, idle, again)
+	if changed := changedProperties(signals, 200*time.Millisecond); len(changed) != 0 {
+		t.Errorf("idle player signaled changes: %v", changed)
+	}
+
+	// Playing a track signals the status and metadata.
+	item := &Item{Track: &spotify.FullTrack{
+		SimpleTrack: spotify.SimpleTrack{
+			Name: "Song",
+			URI: "spotify:track:6rqhFgbbKwnb9MLmUQDhG6",
+			Duration: 180000,
+			Artists: []spotify.SimpleArtist{{Name: "Artist"}},
+		},
+		Album: spotify.SimpleAlbum{Name: "Album"},
+	}}
+	playing := &NowPlaying{Item: item, Playing: true, Fetched: again.Fetched.Add(2 * time.Second)}
+	updateMPRIS(conn, props, again, playing)
+
+	changed := changedProperties(signals, 500*time.Millisecond)
+	if status, ok := changed["PlaybackStatus"]; !ok || status.Value() != "Playing" {
+		t.Errorf("PlaybackStatus changed to %v, expected Playing", status)
+	}
+	if metadata, ok := changed["Metadata"]; !ok || title(metadata.Value()) != "Song" {
+		t.Errorf("Metadata changed to %v, expected the track", metadata)
+	}
+	if status := get("PlaybackStatus"); status != "Playing" {
+		t.Errorf("PlaybackStatus is %v, expected Playing", status)
+	}
+	if name := title(get("Metadata")); name != "Song" {
+		t.Errorf("xesam:title is %v, expected Song", name)
+	}
+
+	// The same track playing on signals nothing.
+	later := &NowPlaying{Item: item, Playing: true, Progress: 2000, Fetched: playing.Fetched.Add(2 * time.Second)}
+	updateMPRIS(conn, props, playing, later)
+	if changed := changedProperties(signals, 200*time.Millisecond); len(changed) != 0 {
+		t.Errorf("player signaled changes while playing on: %v", changed)
+	}
+}
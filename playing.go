@@ -0,0 +1,87 @@
+package main
+
+// Highlighting of the playing item in every table of items.
+
+import (
+	"context"
+	"sync"
+
+	"github.com/zmb3/spotify/v2"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Tracks the playing item. Tables of items (see `ItemTable`) highlight its
+// rows as they are drawn.
+type Playing struct {
+	mu  sync.Mutex
+	uri spotify.URI
+
+	highlight tcell.Style
+}
+
+// Create a `Playing`, styled by a theme.
+func NewPlaying(theme *Theme) *Playing {
+	return &Playing{
+		highlight: theme.Playing,
+	}
+}
+
+// Get the URI of the playing item, or empty if nothing is playing.
+func (p *Playing) URI() spotify.URI {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.uri
+}
+
+// Find the row of the playing item in a table, if it is loaded.
+func (p *Playing) Find(table *tview.Table) (int, bool) {
+	uri := p.URI()
+	if uri == "" {
+		return 0, false
+	}
+
+	for row := 0; row < table.GetRowCount(); row++ {
+		if item, ok := ItemAt(table, row); ok && item.URI() == uri {
+			return row, true
+		}
+	}
+
+	return 0, false
+}
+
+// Change the playing item. Must be called from the application's event loop,
+// so that tables are redrawn.
+func (p *Playing) set(uri spotify.URI) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.uri = uri
+}
+
+// Manager for highlighting the playing item, as reported by the player
+// state. Will continue to run in background.
+func PlayingManager(ctx context.Context, app *tview.Application, playing *Playing, state *StateWatcher) {
+	ch := state.Subscribe()
+	defer state.Unsubscribe(ch)
+
+	for {
+		select {
+		case <- ctx.Done():
+			return
+
+		case np := <- ch:
+			var uri spotify.URI
+			if np.Item != nil {
+				uri = np.Item.URI()
+			}
+
+			if uri != playing.URI() {
+				app.QueueUpdateDraw(func() {
+					playing.set(uri)
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+// Spotify playlists interactions.
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
+)
+
+// Create a row of table cells from a Spotify playlist. The first cell
+// references the playlist.
+func PlaylistIntoCells(playlist *spotify.SimplePlaylist) []*tview.TableCell {
+	name := tview.NewTableCell(playlist.Name).SetReference(playlist)
+	owner := tview.NewTableCell(playlist.Owner.DisplayName)
+	tracks := tview.NewTableCell(fmt.Sprint(playlist.Tracks.Total)).SetAlign(tview.AlignRight)
+
+	return []*tview.TableCell{name, owner, tracks}
+}
+
+// Fetch all of the end user's playlists, both owned and followed.
+func FetchPlaylists(ctx context.Context, cli *spotify.Client) ([]spotify.SimplePlaylist, error) {
+	log.Trace("fetching first page of playlists...")
+	page, err := cli.CurrentUsersPlaylists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	playlists := page.Playlists
+	for {
+		log.Trace("fetching a new page of playlists...")
+		err = cli.NextPage(ctx, page)
+		if err == spotify.ErrNoMorePages {
+			return playlists, nil
+		}
+		if err != nil {
+			return playlists, err
+		}
+
+		playlists = append(playlists, page.Playlists...)
+	}
+}
+
+// Fetch the items of a playlist, in playlist order. The items are sent
+// through the channel as they are fetched, and the channel is closed once
+// there are no more items, on failure, or once the context is cancelled.
+// Local files and items that are unavailable are skipped.
+func FetchPlaylistItems(ctx context.Context, cli *spotify.Client, playlist *spotify.SimplePlaylist, ch chan<- *Item) error {
+	defer close(ch)
+
+	log.Tracef("fetching first page of items for %s...", playlist.Name)
+	page, err := cli.GetPlaylistItems(ctx, playlist.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch items for %s: %w", playlist.Name, err)
+	}
+
+	for {
+		for i := range page.Items {
+			entry := &page.Items[i]
+			if entry.IsLocal {
+				continue
+			}
+
+			var item *Item
+			switch {
+			case entry.Track.Track != nil:
+				item = &Item{Track: entry.Track.Track, AddedAt: entry.AddedAt}
+			case entry.Track.Episode != nil:
+				item = EpisodeItem(entry.Track.Episode)
+				item.AddedAt = entry.AddedAt
+			default:
+				continue
+			}
+
+			select {
+			case ch <- item:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		log.Trace("fetching a new page of playlist items...")
+		err = cli.NextPage(ctx, page)
+		if err == spotify.ErrNoMorePages {
+			log.Debug("no more pages of playlist items")
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch items for %s: %w", playlist.Name, err)
+		}
+	}
+}
+
+// Manager for the listing of playlists. Playlists are few enough to load
+// eagerly.
+func PlaylistsManager(ctx context.Context, cli *spotify.Client, app *tview.Application, playlists *tview.Table) {
+	saved, err := FetchPlaylists(ctx, cli)
+	if err != nil {
+		log.WithError(err).Error("failed to fetch playlists")
+	}
+	log.Tracef("fetched %d playlists", len(saved))
+
+	app.QueueUpdateDraw(func() {
+		for row := range saved {
+			for col, cell := range PlaylistIntoCells(&saved[row]) {
+				playlists.SetCell(row, col, cell)
+			}
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+// Spotify queue interactions.
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
+)
+
+// The queue as reported by the Spotify Web API. Items are decoded
+// separately, by type.
+type queueState struct {
+	Queue []json.RawMessage `json:"queue"`
+}
+
+// Fetch the items queued after the playing item, in order. Items of unknown
+// types are skipped.
+func FetchQueue(ctx context.Context, cli *spotify.Client) ([]*Item, error) {
+	state := &queueState{}
+	err := apiRequest(ctx, cli, http.MethodGet, "me/player/queue", nil, state)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*Item{}
+	for _, raw := range state.Queue {
+		typed := struct {
+			Type string `json:"type"`
+		}{}
+		if err := json.Unmarshal(raw, &typed); err != nil {
+			return nil, err
+		}
+
+		item, err := decodeItem(typed.Type, raw)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// Replace the listing of the queue.
+func LoadQueue(ctx context.Context, cli *spotify.Client, app *tview.Application, queue *ItemTable) {
+	items, err := FetchQueue(ctx, cli)
+	if err != nil {
+		log.WithError(err).Error("failed to fetch queue")
+		return
+	}
+	log.Tracef("fetched %d queued items", len(items))
+
+	app.QueueUpdateDraw(func() {
+		queue.Clear()
+		for _, item := range items {
+			queue.Append(item)
+		}
+	})
+}
+
+// Manager for the listing of the queue. The queue is reloaded every time
+// that the playing item changes. Will continue to run in background.
+func QueueManager(ctx context.Context, cli *spotify.Client, app *tview.Application, queue *ItemTable, state *StateWatcher) {
+	ch := state.Subscribe()
+	defer state.Unsubscribe(ch)
+
+	var playing spotify.URI
+	for {
+		select {
+		case <- ctx.Done():
+			return
+
+		case np := <- ch:
+			var uri spotify.URI
+			if np.Item != nil {
+				uri = np.Item.URI()
+			}
+
+			if uri != playing {
+				playing = uri
+				LoadQueue(ctx, cli, app, queue)
+			}
+		}
+	}
+}
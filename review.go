@@ -0,0 +1,116 @@
+package main
+
+// Interactive review of ambiguous import matches, run before the import
+// report. Each ambiguous entry shows its chosen candidate, which can be
+// changed, accepted, or skipped.
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Help shown above the review table.
+const reviewHelp = "Left/Right: change candidate   Enter: accept   Space: skip   q: finish (unreviewed entries are not imported)"
+
+// Create the cells of a match's row in the review table.
+func reviewCells(m *ImportMatch, theme *Theme) []*tview.TableCell {
+	score, match := m.Chosen()
+	candidate := "skip"
+	if m.Choice >= 0 {
+		candidate = fmt.Sprintf("%d/%d", m.Choice+1, len(m.Candidates))
+	}
+
+	status := tview.NewTableCell(m.Status())
+	if m.Reviewed {
+		status.SetStyle(theme.Marked)
+	}
+
+	return []*tview.TableCell{
+		status,
+		tview.NewTableCell(m.Entry.String()).SetMaxWidth(40).SetExpansion(1),
+		tview.NewTableCell(candidate).SetAlign(tview.AlignRight),
+		tview.NewTableCell(score).SetAlign(tview.AlignRight),
+		tview.NewTableCell(match).SetMaxWidth(60).SetExpansion(2),
+	}
+}
+
+// Review the ambiguous matches interactively, blocking until the end user
+// finishes.
+func ReviewImport(matches []*ImportMatch, theme *Theme) error {
+	review := []*ImportMatch{}
+	for _, m := range matches {
+		if m.Review {
+			review = append(review, m)
+		}
+	}
+
+	theme.Apply()
+	app := tview.NewApplication()
+	table := theme.ApplyTable(tview.NewTable().SetSelectable(true, false).SetFixed(1, 0))
+
+	for column, title := range []string{"STATUS", "ENTRY", "CANDIDATE", "SCORE", "MATCH"} {
+		table.SetCell(0, column, tview.NewTableCell(title).SetStyle(theme.Header).SetSelectable(false))
+	}
+
+	render := func(row int) {
+		for column, cell := range reviewCells(review[row-1], theme) {
+			table.SetCell(row, column, cell)
+		}
+	}
+	for row := range review {
+		render(row + 1)
+	}
+	table.Select(1, 0)
+
+	table.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		row, _ := table.GetSelection()
+		if row < 1 || row > len(review) {
+			return ev
+		}
+		m := review[row-1]
+
+		// Candidates cycle through skipping.
+		cycle := func(delta int) {
+			choices := len(m.Candidates) + 1
+			m.Choice = ((m.Choice+1+delta)%choices+choices)%choices - 1
+		}
+
+		switch {
+		case ev.Key() == tcell.KeyLeft || ev.Rune() == 'h':
+			cycle(-1)
+		case ev.Key() == tcell.KeyRight || ev.Rune() == 'l':
+			cycle(1)
+		case ev.Key() == tcell.KeyEnter:
+			m.Reviewed = true
+			render(row)
+			table.Select(min(row+1, len(review)), 0)
+			return nil
+		case ev.Rune() == ' ':
+			m.Choice = -1
+			m.Reviewed = true
+			render(row)
+			table.Select(min(row+1, len(review)), 0)
+			return nil
+		case ev.Key() == tcell.KeyEscape || ev.Rune() == 'q':
+			app.Stop()
+			return nil
+		default:
+			return ev
+		}
+
+		render(row)
+		return nil
+	})
+
+	help := tview.NewTextView().SetText(reviewHelp).SetTextStyle(theme.Status)
+	title := fmt.Sprintf(" Review %d ambiguous matches ", len(review))
+	table.SetBorder(true).SetTitle(title)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(table, 0, 1, true).
+		AddItem(help, 1, 0, false)
+
+	return app.SetRoot(layout, true).EnableMouse(true).Run()
+}
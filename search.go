@@ -0,0 +1,130 @@
+package main
+
+// Spotify search interactions.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
+)
+
+// A search result of any type.
+type SearchResult struct {
+	URI         spotify.URI `json:"uri"`
+	Description string      `json:"description"`
+}
+
+// Search the Spotify catalog for tracks, albums, artists, playlists, shows,
+// and episodes, in that order.
+func Search(ctx context.Context, cli *spotify.Client, query string) ([]SearchResult, error) {
+	types := spotify.SearchTypeTrack | spotify.SearchTypeAlbum | spotify.SearchTypeArtist | spotify.SearchTypePlaylist | spotify.SearchTypeShow | spotify.SearchTypeEpisode
+
+	found, err := cli.Search(ctx, query, spotify.SearchType(types), spotify.Limit(searchLimit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	results := []SearchResult{}
+
+	if found.Tracks != nil {
+		for _, track := range found.Tracks.Tracks {
+			results = append(results, SearchResult{track.URI, fmt.Sprintf("%s - %s", FormatArtists(track.Artists), track.Name)})
+		}
+	}
+
+	if found.Albums != nil {
+		for _, album := range found.Albums.Albums {
+			results = append(results, SearchResult{album.URI, fmt.Sprintf("%s - %s", FormatArtists(album.Artists), album.Name)})
+		}
+	}
+
+	if found.Artists != nil {
+		for _, artist := range found.Artists.Artists {
+			results = append(results, SearchResult{artist.URI, artist.Name})
+		}
+	}
+
+	if found.Playlists != nil {
+		for _, playlist := range found.Playlists.Playlists {
+			results = append(results, SearchResult{playlist.URI, fmt.Sprintf("%s - %s", playlist.Owner.DisplayName, playlist.Name)})
+		}
+	}
+
+	if found.Shows != nil {
+		for _, show := range found.Shows.Shows {
+			results = append(results, SearchResult{show.URI, fmt.Sprintf("%s - %s", show.Publisher, show.Name)})
+		}
+	}
+
+	if found.Episodes != nil {
+		for _, episode := range found.Episodes.Episodes {
+			results = append(results, SearchResult{episode.URI, episode.Name})
+		}
+	}
+
+	return results, nil
+}
+
+// Search the end user's saved tracks by name, artist, or album, like a filter
+// of the listing. Every saved track is fetched, so that none are missed.
+func SearchLibrary(ctx context.Context, cli *spotify.Client, query string) ([]SearchResult, error) {
+	library, err := FetchLibrary(ctx, cli)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	filter := strings.ToLower(query)
+	results := []SearchResult{}
+	for _, item := range library {
+		if matchItem(item, filter) {
+			results = append(results, SearchResult{item.URI(), fmt.Sprintf("%s - %s", item.Artist(), item.Name())})
+		}
+	}
+
+	return results, nil
+}
+
+// Search page. A search field above a table of results.
+type SearchPage struct {
+	*tview.Flex
+	input   *tview.InputField
+	results *tview.Table
+}
+
+// Create the search page, styled by a theme.
+func NewSearchPage(theme *Theme) *SearchPage {
+	page := &SearchPage{
+		Flex: tview.NewFlex().SetDirection(tview.FlexRow),
+		input: tview.NewInputField().SetLabel("Search: "),
+		results: theme.ApplyTable(tview.NewTable().SetSelectable(true, false)),
+	}
+
+	page.AddItem(page.input, 1, 0, true)
+	page.AddItem(page.results, 0, 1, false)
+
+	return page
+}
+
+// List search results. The first cell of each row references the result.
+func (page *SearchPage) Show(results []SearchResult) {
+	page.results.Clear()
+
+	row := 0
+	for i, result := range results {
+		uri, err := ParseURI(string(result.URI))
+		if err != nil {
+			log.WithError(err).Debug("skipping search result")
+			continue
+		}
+
+		page.results.SetCell(row, 0, tview.NewTableCell(string(uri.Type)).SetReference(&results[i]))
+		page.results.SetCell(row, 1, tview.NewTableCell(result.Description).SetExpansion(1))
+		row++
+	}
+
+	page.results.Select(0, 0).ScrollToBeginning()
+}
@@ -0,0 +1,29 @@
+package main
+
+// Manager for the show listing.
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
+)
+
+// Manager for the listing of saved shows. Shows are few enough to load
+// eagerly.
+func ShowsManager(ctx context.Context, cli *spotify.Client, app *tview.Application, shows *tview.Table) {
+	saved, err := FetchShows(ctx, cli)
+	if err != nil {
+		log.WithError(err).Error("failed to fetch shows")
+	}
+	log.Tracef("fetched %d shows", len(saved))
+
+	app.QueueUpdateDraw(func() {
+		for row := range saved {
+			for col, cell := range ShowIntoCells(&saved[row]) {
+				shows.SetCell(row, col, cell)
+			}
+		}
+	})
+}
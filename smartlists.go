@@ -0,0 +1,369 @@
+package main
+
+// Smart lists: virtual listings of the saved tracks that match some rules,
+// e.g. every explicit track by an artist released in the 1990s. Smart lists
+// are defined in `[lists.NAME]` tables of the configuration file, and can be
+// synced to a real playlist on demand.
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+)
+
+// A smart list. Rules that are not set match every track.
+type SmartList struct {
+	Name string
+
+	// Patterns that any artist, or the album, must match.
+	Artist *regexp.Regexp
+	Album  *regexp.Regexp
+
+	// Range of dates added, like `2024-01-01`, inclusive.
+	AddedAfter  string
+	AddedBefore string
+
+	// Range of durations in milliseconds, inclusive. A maximum of 0 is
+	// unbounded.
+	MinDuration int
+	MaxDuration int
+
+	// Range of release years, inclusive. A maximum of 0 is unbounded.
+	MinYear int
+	MaxYear int
+
+	// If set, whether tracks must be explicit.
+	Explicit *bool
+
+	// Range of popularity (0 to 100), inclusive.
+	MinPopularity int
+	MaxPopularity int
+
+	// Playlist to sync to, if any.
+	Playlist spotify.ID
+}
+
+// Names of the rules of a smart list.
+var smartListRules = []string{
+	"added-after",
+	"added-before",
+	"album",
+	"artist",
+	"explicit",
+	"max-duration",
+	"max-popularity",
+	"min-duration",
+	"min-popularity",
+	"playlist",
+	"year",
+}
+
+// Parse a year, or a range of years like `1990-1999`.
+func parseYears(value string) (int, int, error) {
+	first, last, ok := strings.Cut(value, "-")
+	if !ok {
+		last = first
+	}
+
+	min, err1 := strconv.Atoi(strings.TrimSpace(first))
+	max, err2 := strconv.Atoi(strings.TrimSpace(last))
+	if err1 != nil || err2 != nil || min > max {
+		return 0, 0, fmt.Errorf("invalid years: %s", value)
+	}
+	return min, max, nil
+}
+
+// Parse a popularity from 0 to 100.
+func parsePopularity(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 100 {
+		return 0, fmt.Errorf("invalid popularity: %s", value)
+	}
+	return n, nil
+}
+
+// Parse the rules of a smart list.
+func ParseSmartList(name string, rules map[string]string) (*SmartList, error) {
+	list := &SmartList{Name: name, MaxPopularity: 100}
+
+	var err error
+	for rule, value := range rules {
+		value = strings.TrimSpace(value)
+
+		switch rule {
+		case "artist":
+			list.Artist, err = regexp.Compile("(?i)" + value)
+		case "album":
+			list.Album, err = regexp.Compile("(?i)" + value)
+		case "added-after", "added-before":
+			if _, err = time.Parse("2006-01-02", value); err == nil {
+				if rule == "added-after" {
+					list.AddedAfter = value
+				} else {
+					list.AddedBefore = value
+				}
+			}
+		case "min-duration", "max-duration":
+			duration := parseImportDuration(value)
+			if duration == 0 {
+				err = fmt.Errorf("invalid duration: %s", value)
+			} else if rule == "min-duration" {
+				list.MinDuration = duration
+			} else {
+				list.MaxDuration = duration
+			}
+		case "year":
+			list.MinYear, list.MaxYear, err = parseYears(value)
+		case "explicit":
+			var explicit bool
+			explicit, err = strconv.ParseBool(value)
+			list.Explicit = &explicit
+		case "min-popularity":
+			list.MinPopularity, err = parsePopularity(value)
+		case "max-popularity":
+			list.MaxPopularity, err = parsePopularity(value)
+		case "playlist":
+			list.Playlist, err = parsePlaylistID(value)
+		default:
+			err = fmt.Errorf("unknown rule (try one of: %s)", strings.Join(smartListRules, ", "))
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %s of list %s: %w", rule, name, err)
+		}
+	}
+
+	return list, nil
+}
+
+// Parse the rules of every smart list, sorted by name.
+func ParseSmartLists(rules map[string]map[string]string) ([]*SmartList, error) {
+	lists := []*SmartList{}
+	for name, rules := range rules {
+		list, err := ParseSmartList(name, rules)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+
+	slices.SortFunc(lists, func(a, b *SmartList) int {
+		return compareFold(a.Name, b.Name)
+	})
+
+	return lists, nil
+}
+
+// Check if an item matches every rule of a smart list. Episodes never match.
+func (list *SmartList) Match(item *Item) bool {
+	track := item.Track
+	if track == nil {
+		return false
+	}
+
+	if list.Artist != nil && !slices.ContainsFunc(track.Artists, func(artist spotify.SimpleArtist) bool {
+		return list.Artist.MatchString(artist.Name)
+	}) {
+		return false
+	}
+	if list.Album != nil && !list.Album.MatchString(track.Album.Name) {
+		return false
+	}
+
+	added := item.AddedAt[:min(len(item.AddedAt), 10)]
+	if list.AddedAfter != "" && added < list.AddedAfter {
+		return false
+	}
+	if list.AddedBefore != "" && added > list.AddedBefore {
+		return false
+	}
+
+	if track.Duration < list.MinDuration || (list.MaxDuration != 0 && track.Duration > list.MaxDuration) {
+		return false
+	}
+
+	if list.MinYear != 0 {
+		year, err := strconv.Atoi(track.Album.ReleaseDate[:min(len(track.Album.ReleaseDate), 4)])
+		if err != nil || year < list.MinYear || year > list.MaxYear {
+			return false
+		}
+	}
+
+	if list.Explicit != nil && track.Explicit != *list.Explicit {
+		return false
+	}
+
+	return list.MinPopularity <= track.Popularity && track.Popularity <= list.MaxPopularity
+}
+
+// Fetch the saved tracks that match a smart list, sending them through the
+// channel as they are fetched. The channel is closed once there are no more
+// tracks, on failure, or once the context is cancelled.
+func FetchSmartList(ctx context.Context, cli *spotify.Client, list *SmartList, ch chan<- *Item) error {
+	defer close(ch)
+
+	library := make(chan *Item, fetchingBuffer)
+	failed := make(chan error, 1)
+	go func() {
+		failed <- FetchSavedTracks(ctx, cli, library)
+	}()
+
+	for item := range library {
+		if !list.Match(item) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case ch <- item:
+		}
+	}
+
+	return <-failed
+}
+
+// Replace the tracks of a playlist, in batches.
+func ReplacePlaylist(ctx context.Context, cli *spotify.Client, playlist spotify.ID, ids []spotify.ID) error {
+	if err := cli.ReplacePlaylistTracks(ctx, playlist, ids[:min(100, len(ids))]...); err != nil {
+		return fmt.Errorf("failed to replace playlist tracks: %w", err)
+	}
+
+	for start := 100; start < len(ids); start += 100 {
+		if _, err := cli.AddTracksToPlaylist(ctx, playlist, ids[start:min(start+100, len(ids))]...); err != nil {
+			return fmt.Errorf("failed to add tracks to playlist: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Get the smart list by name, or the list after the one shown if no name is
+// given.
+func (ui *UI) lookupSmartList(name string) (*SmartList, error) {
+	if len(ui.smartLists) == 0 {
+		return nil, fmt.Errorf("no smart lists (add a [lists.NAME] table to the configuration file)")
+	}
+
+	if name == "" {
+		i := slices.Index(ui.smartLists, ui.shownList)
+		return ui.smartLists[(i+1)%len(ui.smartLists)], nil
+	}
+
+	for _, list := range ui.smartLists {
+		if list.Name == name {
+			return list, nil
+		}
+	}
+	return nil, fmt.Errorf("no such smart list: %s", name)
+}
+
+// Get the names of the smart lists.
+func (ui *UI) smartListNames() []string {
+	names := []string{}
+	for _, list := range ui.smartLists {
+		names = append(names, list.Name)
+	}
+	return names
+}
+
+// Load the saved tracks that match a smart list, and switch to the smart
+// list page.
+func (ui *UI) openSmartList(name string) error {
+	list, err := ui.lookupSmartList(name)
+	if err != nil {
+		return err
+	}
+
+	ui.shownList = list
+	ui.openItems("smart", ui.smart, func(ctx context.Context, ch chan<- *Item) {
+		err := FetchSmartList(ctx, ui.cli, list, ch)
+		if err != nil && ctx.Err() == nil {
+			log.WithError(err).Error("failed to fetch smart list")
+			ui.app.QueueUpdateDraw(func() {
+				ui.message(fmt.Sprintf("smart list %s is incomplete: failed to fetch the saved tracks", list.Name))
+			})
+		}
+	})
+	ui.message("smart list: " + list.Name)
+
+	return nil
+}
+
+// Sync the smart list shown to its playlist, after confirming. Every saved
+// track is checked again, so that the playlist is complete even if the page
+// is still loading. Without a playlist, a new playlist is created.
+func (ui *UI) syncSmartList() error {
+	list := ui.shownList
+	if page, _ := ui.pages.GetFrontPage(); page != "smart" || list == nil {
+		return fmt.Errorf("sync-list: no smart list shown (try :list)")
+	}
+
+	ui.message(fmt.Sprintf("fetching the saved tracks to sync %s...", list.Name))
+
+	go func() {
+		// A partial library would drop tracks from the playlist, so
+		// never sync unless every saved track was fetched.
+		library, err := FetchLibrary(ui.ctx, ui.cli)
+		if err != nil {
+			log.WithError(err).Error("failed to sync smart list")
+			ui.app.QueueUpdateDraw(func() {
+				ui.message(fmt.Sprintf("not syncing %s: failed to fetch the saved tracks", list.Name))
+			})
+			return
+		}
+
+		ids := []spotify.ID{}
+		for _, item := range library {
+			if list.Match(item) {
+				ids = append(ids, item.Track.ID)
+			}
+		}
+
+		sync := func() {
+			playlist := list.Playlist
+			if playlist == "" {
+				user, err := ui.cli.CurrentUser(ui.ctx)
+				if err == nil {
+					var created *spotify.FullPlaylist
+					created, err = ui.cli.CreatePlaylistForUser(ui.ctx, user.ID, list.Name, "Smart list synced by nspotify", false, false)
+					if err == nil {
+						playlist = created.ID
+					}
+				}
+				if err != nil {
+					log.WithError(err).Error("failed to create playlist")
+					return
+				}
+				log.Infof("created playlist for %s; add `playlist = \"%s\"` to [lists.%s] to sync to it again", list.Name, playlist, list.Name)
+			}
+
+			if err := ReplacePlaylist(ui.ctx, ui.cli, playlist, ids); err != nil {
+				log.WithError(err).Error("failed to sync smart list")
+				return
+			}
+
+			ui.app.QueueUpdateDraw(func() {
+				ui.message(fmt.Sprintf("synced %d tracks to playlist %s", len(ids), playlist))
+			})
+		}
+
+		text := fmt.Sprintf("Create a playlist named %q with %d tracks?", list.Name, len(ids))
+		if list.Playlist != "" {
+			text = fmt.Sprintf("Replace the tracks of playlist %s with %d tracks?", list.Playlist, len(ids))
+		}
+		ui.app.QueueUpdateDraw(func() {
+			ui.confirm(text, "Sync", func() {
+				go sync()
+			})
+		})
+	}()
+
+	return nil
+}
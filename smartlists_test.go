@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func TestSmartListMatch(t *testing.T) {
+	track := &Item{
+		AddedAt: "2024-03-15T08:00:00Z",
+		Track: &spotify.FullTrack{
+			SimpleTrack: spotify.SimpleTrack{
+				Name: "Song",
+				Duration: 200000,
+				Explicit: true,
+				Artists: []spotify.SimpleArtist{{Name: "First Artist"}, {Name: "Featured"}},
+			},
+			Album: spotify.SimpleAlbum{Name: "Greatest Hits", ReleaseDate: "1994-06-01"},
+			Popularity: 60,
+		},
+	}
+	episode := &Item{Episode: &spotify.EpisodePage{Name: "Episode"}}
+
+	tests := []struct {
+		rules map[string]string
+		want  bool
+	}{
+		{map[string]string{}, true},
+		{map[string]string{"artist": "featured"}, true},
+		{map[string]string{"artist": "^second"}, false},
+		{map[string]string{"album": "greatest"}, true},
+		{map[string]string{"album": "live"}, false},
+		{map[string]string{"added-after": "2024-03-15"}, true},
+		{map[string]string{"added-after": "2024-03-16"}, false},
+		{map[string]string{"added-before": "2024-03-15"}, true},
+		{map[string]string{"added-before": "2024-03-14"}, false},
+		{map[string]string{"min-duration": "3:20"}, true},
+		{map[string]string{"min-duration": "3:21"}, false},
+		{map[string]string{"max-duration": "3:20"}, true},
+		{map[string]string{"max-duration": "3:19"}, false},
+		{map[string]string{"year": "1994"}, true},
+		{map[string]string{"year": "1990-1999"}, true},
+		{map[string]string{"year": "2000-2009"}, false},
+		{map[string]string{"explicit": "true"}, true},
+		{map[string]string{"explicit": "false"}, false},
+		{map[string]string{"min-popularity": "60", "max-popularity": "60"}, true},
+		{map[string]string{"min-popularity": "61"}, false},
+		{map[string]string{"max-popularity": "59"}, false},
+		{map[string]string{"artist": "first", "year": "1990-1999", "explicit": "true"}, true},
+		{map[string]string{"artist": "first", "year": "1990-1999", "explicit": "false"}, false},
+	}
+
+	for _, tt := range tests {
+		list, err := ParseSmartList("test", tt.rules)
+		if err != nil {
+			t.Errorf("ParseSmartList(%v) failed: %v", tt.rules, err)
+			continue
+		}
+
+		if got := list.Match(track); got != tt.want {
+			t.Errorf("list %v matched %v, expected %v", tt.rules, got, tt.want)
+		}
+		if list.Match(episode) {
+			t.Errorf("list %v matched an episode", tt.rules)
+		}
+	}
+}
+
+func TestParseSmartList(t *testing.T) {
+	invalid := []map[string]string{
+		{"artist": "("},
+		{"added-after": "March"},
+		{"min-duration": "long"},
+		{"year": "1999-1990"},
+		{"explicit": "sometimes"},
+		{"min-popularity": "101"},
+		{"genre": "rock"},
+	}
+
+	for _, rules := range invalid {
+		if _, err := ParseSmartList("test", rules); err == nil {
+			t.Errorf("ParseSmartList(%v) succeeded, expected an error", rules)
+		}
+	}
+}
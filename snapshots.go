@@ -0,0 +1,430 @@
+package main
+
+// Snapshots of the saved tracks, saved to dated files, and the differences
+// between them. A snapshot records which tracks were saved and when, and
+// which could not be played or had been relinked, so that tracks lost to
+// licensing changes can be noticed.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
+)
+
+// Snapshots are kept in the cache directory by default, so are disabled by
+// `-no-cache` unless `-snapshots` is set.
+var errSnapshotsDisabled = errors.New("library snapshots are disabled (set -snapshots)")
+
+// Format of the date in the name of a snapshot file.
+const snapshotDate = "20060102-150405"
+
+// A saved track in a snapshot. The URI is the URI that was saved, even if
+// Spotify has relinked it to another track.
+type SnapshotTrack struct {
+	ExportedItem
+
+	// If the track cannot be played.
+	Unplayable bool `json:"unplayable,omitempty"`
+
+	// URI of the track that Spotify plays instead, if relinked.
+	RelinkedTo string `json:"relinked_to,omitempty"`
+}
+
+// Convert a saved track for a snapshot.
+func snapshotTrack(track *spotify.SavedTrack) SnapshotTrack {
+	item := SnapshotTrack{ExportedItem: *ExportItem(TrackItem(track))}
+
+	if track.IsPlayable != nil && !*track.IsPlayable {
+		item.Unplayable = true
+	}
+	if track.LinkedFrom != nil && track.LinkedFrom.URI != "" && track.LinkedFrom.URI != string(track.URI) {
+		item.URI = track.LinkedFrom.URI
+		item.RelinkedTo = string(track.URI)
+	}
+
+	return item
+}
+
+// Describe a track in a snapshot, like `Artist - Title (Album)`.
+func (track *SnapshotTrack) String() string {
+	return strings.Join(track.Artists, ", ") + " - " + track.Name + " (" + track.Album + ")"
+}
+
+// A snapshot of the saved tracks.
+type Snapshot struct {
+	TakenAt time.Time       `json:"taken_at"`
+	Tracks  []SnapshotTrack `json:"tracks"`
+
+	// File that the snapshot was read from, or `live`.
+	Source string `json:"-"`
+}
+
+// Fetch a snapshot of the end user's saved tracks. Tracks are fetched for the
+// end user's market, so that unplayable and relinked tracks are reported.
+func FetchSnapshot(ctx context.Context, cli *spotify.Client) (*Snapshot, error) {
+	snapshot := &Snapshot{TakenAt: time.Now().UTC(), Source: "live"}
+
+	log.Trace("fetching first page of tracks for a snapshot...")
+	page, err := cli.CurrentUsersTracks(ctx, spotify.Limit(50), spotify.Market(spotify.MarketFromToken))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch saved tracks: %w", err)
+	}
+
+	for {
+		for i := range page.Tracks {
+			snapshot.Tracks = append(snapshot.Tracks, snapshotTrack(&page.Tracks[i]))
+		}
+
+		log.Trace("fetching a new page of tracks for a snapshot...")
+		err = cli.NextPage(ctx, page)
+		if err == spotify.ErrNoMorePages {
+			return snapshot, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch saved tracks: %w", err)
+		}
+	}
+}
+
+// Save a snapshot to a dated file in a directory. Returns the path.
+func SaveSnapshot(dir string, snapshot *Snapshot) (string, error) {
+	if dir == "" {
+		return "", errSnapshotsDisabled
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to make snapshot directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	// Never overwrite a snapshot taken in the same second (e.g. by a
+	// subcommand while the interface is running). Suffixes like `_2` sort
+	// after the first snapshot of that second.
+	name := "library-" + snapshot.TakenAt.Local().Format(snapshotDate)
+	path := filepath.Join(dir, name+".json")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	for n := 2; os.IsExist(err); n++ {
+		path = filepath.Join(dir, fmt.Sprintf("%s_%d.json", name, n))
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	snapshot.Source = path
+	return path, nil
+}
+
+// Read a snapshot from a file.
+func ReadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", path, err)
+	}
+	snapshot.Source = path
+
+	return snapshot, nil
+}
+
+// List the names of the snapshot files in a directory, oldest first.
+func ListSnapshots(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "library-*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// Find a snapshot by path, by name in a directory, or by a unique part of
+// its name (e.g. a date like `20240101`).
+func findSnapshot(dir, name string) (string, error) {
+	if _, err := os.Stat(name); err == nil {
+		return name, nil
+	}
+
+	names, err := ListSnapshots(dir)
+	if err != nil {
+		return "", err
+	}
+
+	found := []string{}
+	for _, candidate := range names {
+		if candidate == name {
+			return filepath.Join(dir, candidate), nil
+		}
+		if strings.Contains(candidate, name) {
+			found = append(found, candidate)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no such snapshot: %s", name)
+	case 1:
+		return filepath.Join(dir, found[0]), nil
+	}
+	return "", fmt.Errorf("ambiguous snapshot: %s matches %s", name, strings.Join(found, ", "))
+}
+
+// Differences between two snapshots of the saved tracks.
+type SnapshotDiff struct {
+	From     string `json:"from"`
+	FromTime time.Time `json:"from_taken_at"`
+	To       string `json:"to"`
+	ToTime   time.Time `json:"to_taken_at"`
+
+	// Tracks saved since the first snapshot, or no longer saved.
+	Added   []SnapshotTrack `json:"added"`
+	Removed []SnapshotTrack `json:"removed"`
+
+	// Tracks still saved that can no longer be played, or that have been
+	// relinked to another track.
+	Unavailable []SnapshotTrack `json:"unavailable"`
+	Relinked    []SnapshotTrack `json:"relinked"`
+}
+
+// Compare two snapshots of the saved tracks.
+func DiffSnapshots(from, to *Snapshot) *SnapshotDiff {
+	diff := &SnapshotDiff{
+		From: filepath.Base(from.Source),
+		FromTime: from.TakenAt,
+		To: filepath.Base(to.Source),
+		ToTime: to.TakenAt,
+		Added: []SnapshotTrack{},
+		Removed: []SnapshotTrack{},
+		Unavailable: []SnapshotTrack{},
+		Relinked: []SnapshotTrack{},
+	}
+
+	before := map[string]*SnapshotTrack{}
+	for i := range from.Tracks {
+		before[from.Tracks[i].URI] = &from.Tracks[i]
+	}
+
+	after := map[string]bool{}
+	for _, track := range to.Tracks {
+		after[track.URI] = true
+
+		old, ok := before[track.URI]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, track)
+		case track.Unplayable && !old.Unplayable:
+			diff.Unavailable = append(diff.Unavailable, track)
+		case track.RelinkedTo != "" && track.RelinkedTo != old.RelinkedTo:
+			diff.Relinked = append(diff.Relinked, track)
+		}
+	}
+
+	for _, track := range from.Tracks {
+		if !after[track.URI] {
+			diff.Removed = append(diff.Removed, track)
+		}
+	}
+
+	return diff
+}
+
+// A section of a diff, e.g. the tracks added.
+type diffSection struct {
+	title  string
+	marker string
+	tracks []SnapshotTrack
+}
+
+// Get the sections of a diff, in order.
+func (diff *SnapshotDiff) sections() []diffSection {
+	return []diffSection{
+		{"Added", "+", diff.Added},
+		{"Removed", "-", diff.Removed},
+		{"Unavailable", "!", diff.Unavailable},
+		{"Relinked", "~", diff.Relinked},
+	}
+}
+
+// Describe the snapshots compared by a diff.
+func (diff *SnapshotDiff) title() string {
+	return fmt.Sprintf("Changes from %s (%s) to %s (%s)", diff.From, diff.FromTime.Local().Format("2006-01-02 15:04"), diff.To, diff.ToTime.Local().Format("2006-01-02 15:04"))
+}
+
+// Write a diff as text, one track per line.
+func WriteDiff(w io.Writer, diff *SnapshotDiff) error {
+	fmt.Fprintln(w, diff.title())
+
+	for _, section := range diff.sections() {
+		fmt.Fprintf(w, "\n%s (%d):\n", section.title, len(section.tracks))
+		for _, track := range section.tracks {
+			line := fmt.Sprintf("  %s %s  %s", section.marker, track.String(), track.URI)
+			if track.RelinkedTo != "" {
+				line += " -> " + track.RelinkedTo
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Load the snapshots to compare: with no names, the latest snapshot and the
+// live saved tracks; with one, that snapshot and the live saved tracks; with
+// two, those snapshots.
+func LoadDiff(ctx context.Context, cli *spotify.Client, dir string, names []string) (*SnapshotDiff, error) {
+	if dir == "" {
+		return nil, errSnapshotsDisabled
+	}
+
+	if len(names) == 0 {
+		snapshots, err := ListSnapshots(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(snapshots) == 0 {
+			return nil, fmt.Errorf("no snapshots in %s (take one with `nspotify snapshot`)", dir)
+		}
+		names = snapshots[len(snapshots)-1:]
+	}
+
+	paths := []string{}
+	for _, name := range names {
+		path, err := findSnapshot(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	from, err := ReadSnapshot(paths[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var to *Snapshot
+	if len(paths) > 1 {
+		to, err = ReadSnapshot(paths[1])
+	} else {
+		to, err = FetchSnapshot(ctx, cli)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffSnapshots(from, to), nil
+}
+
+// Show a diff in a table, one section after another. Rows of tracks
+// reference the track.
+func ShowDiff(table *tview.Table, diff *SnapshotDiff, theme *Theme) {
+	table.Clear()
+	table.SetCell(0, 0, tview.NewTableCell(diff.title()).SetStyle(theme.Header).SetSelectable(false))
+
+	row := 1
+	for _, section := range diff.sections() {
+		title := fmt.Sprintf("%s (%d)", section.title, len(section.tracks))
+		table.SetCell(row, 0, tview.NewTableCell("").SetSelectable(false))
+		table.SetCell(row+1, 0, tview.NewTableCell(title).SetStyle(theme.Header).SetSelectable(false))
+		row += 2
+
+		for i := range section.tracks {
+			track := &section.tracks[i]
+			detail := track.AddedAt
+			if track.RelinkedTo != "" {
+				detail = "relinked to " + track.RelinkedTo
+			} else if track.Unplayable {
+				detail = "unavailable"
+			}
+
+			table.SetCell(row, 0, tview.NewTableCell(section.marker+" "+track.Name).SetReference(track).SetExpansion(2))
+			table.SetCell(row, 1, tview.NewTableCell(strings.Join(track.Artists, ", ")).SetExpansion(1))
+			table.SetCell(row, 2, tview.NewTableCell(track.Album).SetExpansion(1))
+			table.SetCell(row, 3, tview.NewTableCell(detail))
+			row++
+		}
+	}
+
+	table.Select(0, 0).ScrollToBeginning()
+	for r := 0; r < table.GetRowCount(); r++ {
+		if cell := table.GetCell(r, 0); cell.GetReference() != nil {
+			table.Select(r, 0)
+			break
+		}
+	}
+}
+
+// Save a snapshot of the saved tracks, reporting where it was saved.
+func (ui *UI) takeSnapshot() {
+	ui.app.QueueUpdateDraw(func() {
+		ui.message("taking a snapshot of the saved tracks...")
+	})
+
+	snapshot, err := FetchSnapshot(ui.ctx, ui.cli)
+	if err == nil {
+		_, err = SaveSnapshot(ui.snapshotDir, snapshot)
+	}
+	if err != nil {
+		log.WithError(err).Error("failed to take a snapshot")
+		return
+	}
+
+	ui.app.QueueUpdateDraw(func() {
+		ui.message(fmt.Sprintf("saved a snapshot of %d tracks to %s", len(snapshot.Tracks), snapshot.Source))
+	})
+}
+
+// Compare snapshots (see `LoadDiff`), and show the changes page.
+func (ui *UI) showDiff(names []string) {
+	if len(names) < 2 {
+		ui.app.QueueUpdateDraw(func() {
+			ui.message("fetching the saved tracks to compare...")
+		})
+	}
+
+	diff, err := LoadDiff(ui.ctx, ui.cli, ui.snapshotDir, names)
+	if err != nil {
+		log.WithError(err).Error("failed to compare snapshots")
+		return
+	}
+
+	ui.app.QueueUpdateDraw(func() {
+		ShowDiff(ui.changes, diff, ui.theme)
+		ui.showPage("changes")
+		ui.message(fmt.Sprintf("%d added, %d removed, %d unavailable, %d relinked", len(diff.Added), len(diff.Removed), len(diff.Unavailable), len(diff.Relinked)))
+	})
+}
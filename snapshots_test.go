@@ -0,0 +1,135 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Create a track of a snapshot.
+func snapshotItem(uri string) SnapshotTrack {
+	return SnapshotTrack{ExportedItem: ExportedItem{Name: uri, URI: uri}}
+}
+
+// Get the URIs of tracks.
+func snapshotURIs(tracks []SnapshotTrack) []string {
+	uris := []string{}
+	for _, track := range tracks {
+		uris = append(uris, track.URI)
+	}
+	return uris
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	unplayable := snapshotItem("spotify:track:b")
+	unplayable.Unplayable = true
+	relinked := snapshotItem("spotify:track:c")
+	relinked.RelinkedTo = "spotify:track:x"
+	relinkedAgain := snapshotItem("spotify:track:c")
+	relinkedAgain.RelinkedTo = "spotify:track:y"
+
+	tests := []struct {
+		name        string
+		from        []SnapshotTrack
+		to          []SnapshotTrack
+		added       []string
+		removed     []string
+		unavailable []string
+		relinked    []string
+	}{
+		{
+			name: "unchanged",
+			from: []SnapshotTrack{snapshotItem("spotify:track:a"), unplayable, relinked},
+			to: []SnapshotTrack{snapshotItem("spotify:track:a"), unplayable, relinked},
+		},
+		{
+			name: "added and removed",
+			from: []SnapshotTrack{snapshotItem("spotify:track:a"), snapshotItem("spotify:track:b")},
+			to: []SnapshotTrack{snapshotItem("spotify:track:b"), snapshotItem("spotify:track:d"), snapshotItem("spotify:track:e")},
+			added: []string{"spotify:track:d", "spotify:track:e"},
+			removed: []string{"spotify:track:a"},
+		},
+		{
+			name: "became unplayable",
+			from: []SnapshotTrack{snapshotItem("spotify:track:b")},
+			to: []SnapshotTrack{unplayable},
+			unavailable: []string{"spotify:track:b"},
+		},
+		{
+			name: "became playable",
+			from: []SnapshotTrack{unplayable},
+			to: []SnapshotTrack{snapshotItem("spotify:track:b")},
+		},
+		{
+			name: "relinked",
+			from: []SnapshotTrack{snapshotItem("spotify:track:c")},
+			to: []SnapshotTrack{relinked},
+			relinked: []string{"spotify:track:c"},
+		},
+		{
+			name: "relinked to another track",
+			from: []SnapshotTrack{relinked},
+			to: []SnapshotTrack{relinkedAgain},
+			relinked: []string{"spotify:track:c"},
+		},
+		{
+			name: "unplayable track removed",
+			from: []SnapshotTrack{unplayable},
+			removed: []string{"spotify:track:b"},
+		},
+	}
+
+	for _, tt := range tests {
+		from := &Snapshot{Tracks: tt.from, Source: "/snapshots/library-old.json"}
+		to := &Snapshot{Tracks: tt.to, Source: "live"}
+		diff := DiffSnapshots(from, to)
+
+		if diff.From != "library-old.json" || diff.To != "live" {
+			t.Errorf("%s: compared %s with %s", tt.name, diff.From, diff.To)
+		}
+		for _, section := range []struct {
+			title string
+			got   []SnapshotTrack
+			want  []string
+		}{
+			{"added", diff.Added, tt.added},
+			{"removed", diff.Removed, tt.removed},
+			{"unavailable", diff.Unavailable, tt.unavailable},
+			{"relinked", diff.Relinked, tt.relinked},
+		} {
+			got := snapshotURIs(section.got)
+			if !slices.Equal(got, section.want) {
+				t.Errorf("%s: %s %q, expected %q", tt.name, section.title, got, section.want)
+			}
+		}
+	}
+}
+
+func TestSaveSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	takenAt := time.Date(2024, 1, 31, 12, 0, 0, 0, time.Local)
+
+	// Snapshots taken in the same second are all kept, and are listed in the
+	// order they were taken.
+	paths := []string{}
+	for range 3 {
+		path, err := SaveSnapshot(dir, &Snapshot{TakenAt: takenAt, Tracks: []SnapshotTrack{snapshotItem("spotify:track:a")}})
+		if err != nil {
+			t.Fatalf("failed to save snapshot: %v", err)
+		}
+		paths = append(paths, filepath.Base(path))
+	}
+
+	names, err := ListSnapshots(dir)
+	if err != nil {
+		t.Fatalf("failed to list snapshots: %v", err)
+	}
+	if !slices.Equal(names, paths) {
+		t.Errorf("listed snapshots %q, expected %q", names, paths)
+	}
+
+	if _, err := SaveSnapshot("", &Snapshot{TakenAt: takenAt}); err != errSnapshotsDisabled {
+		t.Errorf("saved a snapshot without a directory: %v", err)
+	}
+}
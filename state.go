@@ -0,0 +1,301 @@
+package main
+
+// Spotify player state interactions.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+)
+
+// A snapshot of the end user's player state.
+type NowPlaying struct {
+	// The playing item, or nil if nothing is playing.
+	Item *Item
+
+	// If the player is playing (as opposed to paused).
+	Playing bool
+
+	// Progress (in milliseconds) into the playing item.
+	Progress int
+
+	// The active device.
+	Device spotify.PlayerDevice
+
+	// The context that the item is playing from, if any.
+	Context spotify.PlaybackContext
+
+	Shuffle bool
+	Repeat  string
+
+	// Time that the snapshot was taken.
+	Fetched time.Time
+}
+
+// The player state as reported by the Spotify Web API. The client library
+// only decodes tracks, so the item is decoded separately.
+type playerState struct {
+	Device       spotify.PlayerDevice    `json:"device"`
+	ShuffleState bool                    `json:"shuffle_state"`
+	RepeatState  string                  `json:"repeat_state"`
+	Context      spotify.PlaybackContext `json:"context"`
+	Progress     int                     `json:"progress_ms"`
+	Playing      bool                    `json:"is_playing"`
+	Type         string                  `json:"currently_playing_type"`
+	Item         json.RawMessage         `json:"item"`
+}
+
+// Fetch the end user's player state. Unlike `PlayerState`, this supports
+// episodes.
+func FetchNowPlaying(ctx context.Context, cli *spotify.Client) (*NowPlaying, error) {
+	query := url.Values{}
+	query.Set("additional_types", "track,episode")
+
+	state := &playerState{}
+	err := apiRequest(ctx, cli, http.MethodGet, "me/player", query, state)
+	if err != nil {
+		return nil, err
+	}
+
+	np := &NowPlaying{
+		Playing: state.Playing,
+		Progress: state.Progress,
+		Device: state.Device,
+		Context: state.Context,
+		Shuffle: state.ShuffleState,
+		Repeat: state.RepeatState,
+		Fetched: time.Now(),
+	}
+
+	if len(state.Item) == 0 || string(state.Item) == "null" {
+		return np, nil
+	}
+
+	np.Item, err = decodeItem(state.Type, state.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	return np, nil
+}
+
+// Decode an item of a type (`track` or `episode`) as reported by the Spotify
+// Web API. Items of other types (e.g. ads) are nil.
+func decodeItem(typ string, raw json.RawMessage) (*Item, error) {
+	switch typ {
+	case "track":
+		track := &spotify.FullTrack{}
+		if err := json.Unmarshal(raw, track); err != nil {
+			return nil, err
+		}
+		return &Item{Track: track}, nil
+
+	case "episode":
+		episode := &spotify.EpisodePage{}
+		if err := json.Unmarshal(raw, episode); err != nil {
+			return nil, err
+		}
+		return EpisodeItem(episode), nil
+	}
+
+	return nil, nil
+}
+
+// Get the estimated progress (in milliseconds) into the playing item, given
+// the time that has passed since the snapshot was taken.
+func (np *NowPlaying) EstimatedProgress() int {
+	if !np.Playing || np.Item == nil {
+		return np.Progress
+	}
+
+	progress := np.Progress + int(time.Since(np.Fetched).Milliseconds())
+	if progress > np.Item.Duration() {
+		return np.Item.Duration()
+	}
+	return progress
+}
+
+// A player state, formatted for machine consumption (i.e. JSON).
+type Status struct {
+	Playing  bool        `json:"playing"`
+	URI      spotify.URI `json:"uri,omitempty"`
+	Name     string      `json:"name,omitempty"`
+	Artists  []string    `json:"artists,omitempty"`
+	Album    string      `json:"album,omitempty"`
+	Show     string      `json:"show,omitempty"`
+	Progress int         `json:"progress_ms"`
+	Duration int         `json:"duration_ms"`
+	Device   string      `json:"device,omitempty"`
+	DeviceID spotify.ID  `json:"device_id,omitempty"`
+	Volume   int         `json:"volume"`
+	Context  spotify.URI `json:"context,omitempty"`
+	Shuffle  bool        `json:"shuffle"`
+	Repeat   string      `json:"repeat,omitempty"`
+}
+
+// Format a player state for machine consumption.
+func (np *NowPlaying) Status() *Status {
+	status := &Status{
+		Playing: np.Playing,
+		Progress: np.EstimatedProgress(),
+		Device: np.Device.Name,
+		DeviceID: np.Device.ID,
+		Volume: int(np.Device.Volume),
+		Context: np.Context.URI,
+		Shuffle: np.Shuffle,
+		Repeat: np.Repeat,
+	}
+
+	if item := np.Item; item != nil {
+		status.URI = item.URI()
+		status.Name = item.Name()
+		status.Duration = item.Duration()
+		if item.Episode != nil {
+			status.Show = item.Episode.Show.Name
+		} else {
+			for _, artist := range item.Track.Artists {
+				status.Artists = append(status.Artists, artist.Name)
+			}
+			status.Album = item.Track.Album.Name
+		}
+	}
+
+	return status
+}
+
+// Format a player state as a single line, e.g.
+// `playing: [artists] - [name] (1:23/4:56) on [device]`.
+func (status *Status) String() string {
+	if status.URI == "" {
+		return "stopped"
+	}
+
+	state := "paused"
+	if status.Playing {
+		state = "playing"
+	}
+
+	by := status.Show
+	if by == "" {
+		by = strings.Join(status.Artists, " & ")
+	}
+
+	line := fmt.Sprintf("%s: %s - %s (%s/%s)", state, by, status.Name, FormatDuration(status.Progress), FormatDuration(status.Duration))
+	if status.Device != "" {
+		line += " on " + status.Device
+	}
+
+	return line
+}
+
+// Format a player state as a single line.
+func FormatNowPlaying(np *NowPlaying) string {
+	return np.Status().String()
+}
+
+// Poller for the end user's player state. Shared by everything that reports
+// what is playing.
+type StateWatcher struct {
+	mu          sync.Mutex
+	latest      *NowPlaying
+	subscribers []chan *NowPlaying
+	refresh     chan bool
+}
+
+// Create a `StateWatcher`. It polls once `Run` is called.
+func NewStateWatcher() *StateWatcher {
+	return &StateWatcher{
+		latest: &NowPlaying{},
+		refresh: make(chan bool, 1),
+	}
+}
+
+// Get the latest snapshot of the player state.
+func (w *StateWatcher) Latest() *NowPlaying {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.latest
+}
+
+// Subscribe to snapshots of the player state. A snapshot is sent every time
+// that the state is polled. Slow subscribers miss snapshots rather than
+// blocking the poller.
+func (w *StateWatcher) Subscribe() <-chan *NowPlaying {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ch := make(chan *NowPlaying, 1)
+	w.subscribers = append(w.subscribers, ch)
+	return ch
+}
+
+// Stop sending snapshots of the player state to a subscriber.
+func (w *StateWatcher) Unsubscribe(ch <-chan *NowPlaying) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i, sub := range w.subscribers {
+		if sub == ch {
+			w.subscribers = append(w.subscribers[:i], w.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Request that the player state be polled now rather than at the next
+// interval, e.g. because an event just changed it.
+func (w *StateWatcher) Refresh() {
+	select {
+	case w.refresh <- true:
+	default:
+	}
+}
+
+// Poll the player state until the context is cancelled.
+func (w *StateWatcher) Run(ctx context.Context, cli *spotify.Client) {
+	ticker := time.NewTicker(stateTimeout * time.Second)
+	defer ticker.Stop()
+
+	for {
+		np, err := FetchNowPlaying(ctx, cli)
+		if err != nil {
+			log.WithError(err).Debug("failed to poll player state")
+		} else {
+			w.publish(np)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Trace("context closed, terminating state watcher")
+			return
+		case <-ticker.C:
+		case <-w.refresh:
+		}
+	}
+}
+
+// Store a snapshot of the player state and send it to subscribers.
+func (w *StateWatcher) publish(np *NowPlaying) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.latest = np
+	for _, ch := range w.subscribers {
+		// Replace any snapshot that the subscriber has not yet
+		// received.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- np
+	}
+}
@@ -0,0 +1,633 @@
+package main
+
+// Listening statistics from the play history and the saved tracks: plays per
+// day and week, top artists, albums, and tracks, listening time, growth of the
+// saved tracks, and genres. Shown as text sparklines and bar charts, or
+// reported as JSON.
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zmb3/spotify/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	// Entries in each list of top artists, albums, tracks, and genres.
+	statsTop = 10
+
+	// Periods shown in the sparklines of plays per day and per week. Every
+	// period is reported in JSON.
+	statsDays  = 90
+	statsWeeks = 52
+
+	// Widths of bar charts and of names beside them.
+	statsBarWidth  = 24
+	statsNameWidth = 40
+)
+
+// Plays within a period, like `2024-01-31` or `2024-W05`.
+type StatsPeriod struct {
+	Period string `json:"period"`
+	Plays  int    `json:"plays"`
+	Heard  int    `json:"heard_ms"`
+}
+
+// An artist, album, or track ranked by plays.
+type StatsRank struct {
+	Name string `json:"name"`
+
+	// Artists of an album or track.
+	By string `json:"by,omitempty"`
+
+	URI   spotify.URI `json:"uri,omitempty"`
+	Plays int         `json:"plays"`
+	Heard int         `json:"heard_ms"`
+}
+
+// Tracks saved within a month, like `2024-01`, and in total by its end.
+type StatsGrowth struct {
+	Month string `json:"month"`
+	Added int    `json:"added"`
+	Total int    `json:"total"`
+}
+
+// A genre, counted once per play or saved track by any of its artists.
+type StatsGenre struct {
+	Genre string  `json:"genre"`
+	Count int     `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// Listening statistics. Plays are within a range of time; the saved tracks
+// are not.
+type Stats struct {
+	Since *time.Time `json:"since,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+
+	Plays int `json:"plays"`
+	Heard int `json:"heard_ms"`
+
+	PerDay  []StatsPeriod `json:"plays_per_day"`
+	PerWeek []StatsPeriod `json:"plays_per_week"`
+
+	TopArtists []StatsRank `json:"top_artists"`
+	TopAlbums  []StatsRank `json:"top_albums"`
+	TopTracks  []StatsRank `json:"top_tracks"`
+
+	SavedTracks   int           `json:"saved_tracks"`
+	LibraryGrowth []StatsGrowth `json:"library_growth"`
+
+	// Genres of the artists of plays, and of saved tracks.
+	PlayedGenres []StatsGenre `json:"played_genres"`
+	SavedGenres  []StatsGenre `json:"saved_genres"`
+}
+
+// Get the IDs of the artists of some plays and saved tracks, without
+// duplicates.
+func statsArtistIDs(entries []*HistoryEntry, library []*Item) []spotify.ID {
+	seen := map[spotify.ID]bool{}
+	ids := []spotify.ID{}
+	add := func(id spotify.ID) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, entry := range entries {
+		for _, id := range entry.ArtistIDs {
+			add(id)
+		}
+	}
+	for _, item := range library {
+		if item.Track != nil {
+			for _, artist := range item.Track.Artists {
+				add(artist.ID)
+			}
+		}
+	}
+
+	return ids
+}
+
+// Fetch the genres of artists, in batches.
+func FetchArtistGenres(ctx context.Context, cli *spotify.Client, ids []spotify.ID) (map[spotify.ID][]string, error) {
+	genres := map[spotify.ID][]string{}
+	for start := 0; start < len(ids); start += 50 {
+		artists, err := cli.GetArtists(ctx, ids[start:min(start+50, len(ids))]...)
+		if err != nil {
+			return genres, fmt.Errorf("failed to fetch artists: %w", err)
+		}
+		for _, artist := range artists {
+			if artist != nil {
+				genres[artist.ID] = artist.Genres
+			}
+		}
+	}
+
+	return genres, nil
+}
+
+// Tally of ranked artists, albums, or tracks.
+type statsTally map[string]*StatsRank
+
+// Count a play towards a key.
+func (tally statsTally) add(key string, rank StatsRank, entry *HistoryEntry) {
+	counted, ok := tally[key]
+	if !ok {
+		counted = &rank
+		tally[key] = counted
+	}
+	counted.Plays++
+	counted.Heard += entry.Heard
+}
+
+// Get the most played, breaking ties by time heard and then by name.
+func (tally statsTally) top(n int) []StatsRank {
+	ranks := []StatsRank{}
+	for _, rank := range tally {
+		ranks = append(ranks, *rank)
+	}
+
+	slices.SortFunc(ranks, func(a, b StatsRank) int {
+		if c := cmp.Compare(b.Plays, a.Plays); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Heard, a.Heard); c != 0 {
+			return c
+		}
+		return compareFold(a.Name, b.Name)
+	})
+
+	return ranks[:min(n, len(ranks))]
+}
+
+// Count genres once per set of artists, e.g. per play, and get the most
+// common as shares of a total.
+func countGenres(artists [][]spotify.ID, genres map[spotify.ID][]string, total int) []StatsGenre {
+	counts := map[string]int{}
+	for _, ids := range artists {
+		seen := map[string]bool{}
+		for _, id := range ids {
+			for _, genre := range genres[id] {
+				if !seen[genre] {
+					seen[genre] = true
+					counts[genre]++
+				}
+			}
+		}
+	}
+
+	ranked := []StatsGenre{}
+	for genre, count := range counts {
+		ranked = append(ranked, StatsGenre{Genre: genre, Count: count, Share: float64(count) / float64(total)})
+	}
+	slices.SortFunc(ranked, func(a, b StatsGenre) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return compareFold(a.Genre, b.Genre)
+	})
+
+	return ranked[:min(statsTop, len(ranked))]
+}
+
+// Start of the day of a time.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Count plays per day and per week (ISO weeks), including periods without
+// plays. Periods run from the start of the range (or the first play) to the
+// end of the range (or now).
+func countPeriods(entries []*HistoryEntry, r HistoryRange, now time.Time) ([]StatsPeriod, []StatsPeriod) {
+	days := map[string]*StatsPeriod{}
+	for _, entry := range entries {
+		day := entry.Started.In(now.Location()).Format(time.DateOnly)
+		if days[day] == nil {
+			days[day] = &StatsPeriod{Period: day}
+		}
+		days[day].Plays++
+		days[day].Heard += entry.Heard
+	}
+
+	first, last := r.Since, now
+	if first.IsZero() {
+		if len(entries) == 0 {
+			return []StatsPeriod{}, []StatsPeriod{}
+		}
+		first = entries[0].Started.In(now.Location())
+	}
+	if !r.Until.IsZero() && r.Until.Before(now) {
+		last = r.Until.Add(-time.Nanosecond)
+	}
+
+	perDay := []StatsPeriod{}
+	perWeek := []StatsPeriod{}
+	for day := startOfDay(first); !day.After(last); day = day.AddDate(0, 0, 1) {
+		counted := StatsPeriod{Period: day.Format(time.DateOnly)}
+		if days[counted.Period] != nil {
+			counted = *days[counted.Period]
+		}
+		perDay = append(perDay, counted)
+
+		year, week := day.ISOWeek()
+		period := fmt.Sprintf("%d-W%02d", year, week)
+		if len(perWeek) == 0 || perWeek[len(perWeek)-1].Period != period {
+			perWeek = append(perWeek, StatsPeriod{Period: period})
+		}
+		perWeek[len(perWeek)-1].Plays += counted.Plays
+		perWeek[len(perWeek)-1].Heard += counted.Heard
+	}
+
+	return perDay, perWeek
+}
+
+// Count saved tracks per month of the dates they were added.
+func countGrowth(library []*Item) []StatsGrowth {
+	months := map[string]int{}
+	for _, item := range library {
+		if len(item.AddedAt) >= 7 {
+			months[item.AddedAt[:7]]++
+		}
+	}
+
+	growth := []StatsGrowth{}
+	for month, added := range months {
+		growth = append(growth, StatsGrowth{Month: month, Added: added})
+	}
+	slices.SortFunc(growth, func(a, b StatsGrowth) int {
+		return cmp.Compare(a.Month, b.Month)
+	})
+
+	total := 0
+	for i := range growth {
+		total += growth[i].Added
+		growth[i].Total = total
+	}
+
+	return growth
+}
+
+// Compute statistics from the plays within a range, the saved tracks, and
+// the genres of artists.
+func ComputeStats(entries []*HistoryEntry, r HistoryRange, library []*Item, genres map[spotify.ID][]string, now time.Time) *Stats {
+	stats := &Stats{
+		Plays: len(entries),
+		Heard: totalHeard(entries),
+		SavedTracks: len(library),
+		LibraryGrowth: countGrowth(library),
+	}
+	if !r.Since.IsZero() {
+		stats.Since = &r.Since
+	}
+	if !r.Until.IsZero() {
+		stats.Until = &r.Until
+	}
+
+	stats.PerDay, stats.PerWeek = countPeriods(entries, r, now)
+
+	artists, albums, tracks := statsTally{}, statsTally{}, statsTally{}
+	played := [][]spotify.ID{}
+	for _, entry := range entries {
+		if entry.Show != "" {
+			artists.add("show\n"+entry.Show, StatsRank{Name: entry.Show}, entry)
+		}
+		for i, name := range entry.Artists {
+			rank := StatsRank{Name: name}
+			key := "name\n" + name
+			if i < len(entry.ArtistIDs) && entry.ArtistIDs[i] != "" {
+				rank.URI = spotify.URI("spotify:artist:" + entry.ArtistIDs[i])
+				key = string(rank.URI)
+			}
+			artists.add(key, rank, entry)
+		}
+
+		by := entry.Show
+		if by == "" {
+			by = strings.Join(entry.Artists, ", ")
+		}
+		if entry.Album != "" {
+			albums.add(entry.Album+"\n"+by, StatsRank{Name: entry.Album, By: by}, entry)
+		}
+		tracks.add(string(entry.URI), StatsRank{Name: entry.Name, By: by, URI: entry.URI}, entry)
+
+		played = append(played, entry.ArtistIDs)
+	}
+
+	stats.TopArtists = artists.top(statsTop)
+	stats.TopAlbums = albums.top(statsTop)
+	stats.TopTracks = tracks.top(statsTop)
+
+	saved := [][]spotify.ID{}
+	for _, item := range library {
+		if item.Track == nil {
+			continue
+		}
+		ids := []spotify.ID{}
+		for _, artist := range item.Track.Artists {
+			ids = append(ids, artist.ID)
+		}
+		saved = append(saved, ids)
+	}
+
+	stats.PlayedGenres = countGenres(played, genres, len(entries))
+	stats.SavedGenres = countGenres(saved, genres, len(saved))
+
+	return stats
+}
+
+// Compute statistics from the plays within a range (`[SINCE [UNTIL]]`) and
+// some saved tracks. Genres are fetched for every artist; if that fails,
+// genres are left out.
+func LoadStats(ctx context.Context, cli *spotify.Client, path string, args []string, library []*Item) (*Stats, error) {
+	now := time.Now()
+	r, err := ParseHistoryRange(args, now)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ReadHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	entries = FilterHistory(entries, r)
+
+	genres, err := FetchArtistGenres(ctx, cli, statsArtistIDs(entries, library))
+	if err != nil {
+		log.WithError(err).Warn("failed to fetch genres")
+	}
+
+	return ComputeStats(entries, r, library, genres, now), nil
+}
+
+// Levels of sparklines, from lowest to highest.
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// Draw values as a sparkline. Zeros are blank.
+func sparkline(values []int) string {
+	top := 0
+	for _, value := range values {
+		top = max(top, value)
+	}
+
+	var b strings.Builder
+	for _, value := range values {
+		if value == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(sparkLevels[(value*len(sparkLevels)-1)/top])
+	}
+	return b.String()
+}
+
+// Partial blocks of bar charts, in eighths.
+var barEighths = []rune(" ▏▎▍▌▋▊▉")
+
+// Draw a value as a bar, scaled so that the top value fills the width.
+func bar(value, top, width int) string {
+	if top == 0 {
+		return strings.Repeat(" ", width)
+	}
+
+	eighths := value * width * 8 / top
+	if eighths == 0 && value > 0 {
+		eighths = 1
+	}
+
+	b := strings.Repeat("█", eighths/8)
+	if eighths%8 != 0 {
+		b += string(barEighths[eighths%8])
+	}
+	return b + strings.Repeat(" ", width-len([]rune(b)))
+}
+
+// Pad or cut text to a width, in runes.
+func fitText(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return text + strings.Repeat(" ", width-len(runes))
+}
+
+// A line of the text of statistics. Lines of ranked artists and tracks
+// reference them, e.g. to play them.
+type statsLine struct {
+	text   string
+	header bool
+	rank   *StatsRank
+}
+
+// Describe the range of some statistics, like `since 2024-01-01`.
+func (stats *Stats) title() string {
+	title := "all time"
+	if stats.Since != nil {
+		title = "since " + stats.Since.Format("2006-01-02 15:04")
+	}
+	if stats.Until != nil {
+		title += ", until " + stats.Until.Format("2006-01-02 15:04")
+	}
+	return "Listening statistics, " + title
+}
+
+// Format statistics as lines of text charts.
+func (stats *Stats) lines() []statsLine {
+	lines := []statsLine{
+		{text: stats.title(), header: true},
+		{text: fmt.Sprintf("%d plays, %s heard", stats.Plays, FormatDuration(stats.Heard))},
+	}
+	section := func(title string) {
+		lines = append(lines, statsLine{}, statsLine{text: title, header: true})
+	}
+
+	periods := func(title string, counted []StatsPeriod, shown int) {
+		counted = counted[max(0, len(counted)-shown):]
+		if len(counted) == 0 {
+			return
+		}
+
+		values := []int{}
+		top := 0
+		for _, period := range counted {
+			values = append(values, period.Plays)
+			top = max(top, period.Plays)
+		}
+		section(fmt.Sprintf("%s (%s to %s, at most %d)", title, counted[0].Period, counted[len(counted)-1].Period, top))
+		lines = append(lines, statsLine{text: sparkline(values)})
+	}
+	periods("Plays per day", stats.PerDay, statsDays)
+	periods("Plays per week", stats.PerWeek, statsWeeks)
+
+	ranks := func(title string, ranked []StatsRank) {
+		if len(ranked) == 0 {
+			return
+		}
+		section(title)
+		for i := range ranked {
+			rank := &ranked[i]
+			name := rank.Name
+			if rank.By != "" {
+				name = rank.By + " – " + name
+			}
+			text := fmt.Sprintf("%2d. %s %s %5d plays  %s", i+1, fitText(name, statsNameWidth), bar(rank.Plays, ranked[0].Plays, statsBarWidth), rank.Plays, FormatDuration(rank.Heard))
+
+			line := statsLine{text: text}
+			if rank.URI != "" {
+				line.rank = rank
+			}
+			lines = append(lines, line)
+		}
+	}
+	ranks("Top artists", stats.TopArtists)
+	ranks("Top albums", stats.TopAlbums)
+	ranks("Top tracks", stats.TopTracks)
+
+	genres := func(title string, ranked []StatsGenre) {
+		if len(ranked) == 0 {
+			return
+		}
+		section(title)
+		for _, genre := range ranked {
+			text := fmt.Sprintf("    %s %s %5d  %3.0f%%", fitText(genre.Genre, statsNameWidth), bar(genre.Count, ranked[0].Count, statsBarWidth), genre.Count, genre.Share*100)
+			lines = append(lines, statsLine{text: text})
+		}
+	}
+	genres("Genres of plays", stats.PlayedGenres)
+
+	if len(stats.LibraryGrowth) != 0 {
+		section(fmt.Sprintf("Saved tracks by month added (%d in total)", stats.SavedTracks))
+
+		top := 0
+		totals := []int{}
+		for _, month := range stats.LibraryGrowth {
+			top = max(top, month.Added)
+			totals = append(totals, month.Total)
+		}
+		lines = append(lines, statsLine{text: "    total " + sparkline(totals)})
+		for _, month := range stats.LibraryGrowth {
+			text := fmt.Sprintf("    %s %s %5d  %6d in total", month.Month, bar(month.Added, top, statsBarWidth), month.Added, month.Total)
+			lines = append(lines, statsLine{text: text})
+		}
+	}
+
+	genres("Genres of saved tracks", stats.SavedGenres)
+
+	return lines
+}
+
+// Write statistics as text charts.
+func WriteStats(w io.Writer, stats *Stats) error {
+	for _, line := range stats.lines() {
+		if _, err := fmt.Fprintln(w, line.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write statistics as JSON.
+func WriteStatsJSON(w io.Writer, stats *Stats) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stats)
+}
+
+// Show statistics in a table, one line per row. Rows of ranked artists and
+// tracks reference them.
+func ShowStats(table *tview.Table, stats *Stats, theme *Theme) {
+	table.Clear()
+
+	for row, line := range stats.lines() {
+		cell := tview.NewTableCell(line.text).SetSelectable(line.rank != nil)
+		if line.header {
+			cell.SetStyle(theme.Header)
+		}
+		if line.rank != nil {
+			cell.SetReference(line.rank)
+		}
+		table.SetCell(row, 0, cell)
+	}
+
+	table.Select(0, 0).ScrollToBeginning()
+}
+
+// Compute statistics from the plays within a range (`[SINCE [UNTIL]]`) and
+// the saved tracks loaded into the listing, and show them.
+func (ui *UI) showStats(args []string) error {
+	if _, err := ParseHistoryRange(args, time.Now()); err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+
+	ui.message("computing listening statistics...")
+	go ui.loadStats(args)
+	return nil
+}
+
+// Compute the listening statistics and show them once done.
+func (ui *UI) loadStats(args []string) {
+	// The listing loads lazily, so fetch every saved track. Statistics of a
+	// partial library would be wrong, so none are shown on failure.
+	library, err := FetchLibrary(ui.ctx, ui.cli)
+	if err != nil {
+		log.WithError(err).Error("failed to compute listening statistics")
+		ui.app.QueueUpdateDraw(func() {
+			ui.message("failed to fetch the saved tracks for listening statistics")
+		})
+		return
+	}
+
+	stats, err := LoadStats(ui.ctx, ui.cli, ui.historyPath, args, library)
+	if err != nil {
+		log.WithError(err).Error("failed to compute listening statistics")
+		ui.app.QueueUpdateDraw(func() {
+			ui.message("failed to compute listening statistics: " + err.Error())
+		})
+		return
+	}
+
+	ui.app.QueueUpdateDraw(func() {
+		ui.shownStats = stats
+		ShowStats(ui.stats, stats, ui.theme)
+		ui.openingPage("stats")
+		ui.showPage("stats")
+		ui.message(fmt.Sprintf("%d plays, %s heard, %d saved tracks", stats.Plays, FormatDuration(stats.Heard), stats.SavedTracks))
+	})
+}
+
+// Export the statistics shown as JSON to a file.
+func (ui *UI) exportStats(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("export-stats: expected a file")
+	}
+	if ui.shownStats == nil {
+		return fmt.Errorf("export-stats: no statistics shown (try :stats)")
+	}
+
+	file, err := os.Create(args[0])
+	if err != nil {
+		return fmt.Errorf("export-stats: %w", err)
+	}
+
+	if err := WriteStatsJSON(file, ui.shownStats); err != nil {
+		file.Close()
+		return fmt.Errorf("export-stats: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("export-stats: %w", err)
+	}
+
+	ui.message("exported listening statistics to " + args[0])
+	return nil
+}
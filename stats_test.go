@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Describe periods like `2024-01-31:2/3000`, i.e. plays and time heard.
+func describePeriods(periods []StatsPeriod) []string {
+	described := []string{}
+	for _, period := range periods {
+		described = append(described, fmt.Sprintf("%s:%d/%d", period.Period, period.Plays, period.Heard))
+	}
+	return described
+}
+
+func TestCountPeriods(t *testing.T) {
+	now := time.Date(2024, 1, 31, 15, 30, 0, 0, time.UTC)
+	play := func(started string, heard int) *HistoryEntry {
+		at, _ := time.Parse(time.RFC3339, started)
+		return &HistoryEntry{Started: at, Heard: heard}
+	}
+	entries := []*HistoryEntry{
+		play("2024-01-27T10:00:00Z", 1000),
+		play("2024-01-27T23:00:00Z", 2000),
+		play("2024-01-29T08:00:00Z", 4000),
+	}
+
+	tests := []struct {
+		name    string
+		entries []*HistoryEntry
+		args    []string
+		perDay  []string
+		perWeek []string
+	}{
+		{
+			name: "since the first play",
+			entries: entries,
+			perDay: []string{"2024-01-27:2/3000", "2024-01-28:0/0", "2024-01-29:1/4000", "2024-01-30:0/0", "2024-01-31:0/0"},
+			perWeek: []string{"2024-W04:2/3000", "2024-W05:1/4000"},
+		},
+		{
+			name: "since a date",
+			entries: entries[2:],
+			args: []string{"2024-01-26"},
+			perDay: []string{"2024-01-26:0/0", "2024-01-27:0/0", "2024-01-28:0/0", "2024-01-29:1/4000", "2024-01-30:0/0", "2024-01-31:0/0"},
+			perWeek: []string{"2024-W04:0/0", "2024-W05:1/4000"},
+		},
+		{
+			name: "until yesterday",
+			entries: entries,
+			args: []string{"2024-01-28", "yesterday"},
+			perDay: []string{"2024-01-28:0/0", "2024-01-29:1/4000", "2024-01-30:0/0"},
+			perWeek: []string{"2024-W04:0/0", "2024-W05:1/4000"},
+		},
+		{
+			name: "no plays",
+			perDay: []string{},
+			perWeek: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseHistoryRange(tt.args, now)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		perDay, perWeek := countPeriods(tt.entries, r, now)
+		if got := describePeriods(perDay); !slices.Equal(got, tt.perDay) {
+			t.Errorf("%s: counted days %q, expected %q", tt.name, got, tt.perDay)
+		}
+		if got := describePeriods(perWeek); !slices.Equal(got, tt.perWeek) {
+			t.Errorf("%s: counted weeks %q, expected %q", tt.name, got, tt.perWeek)
+		}
+	}
+}
+
+func TestCountGrowth(t *testing.T) {
+	library := []*Item{
+		{AddedAt: "2024-02-10T00:00:00Z"},
+		{AddedAt: "2023-12-31T23:59:59Z"},
+		{AddedAt: "2024-02-01T00:00:00Z"},
+		{AddedAt: ""},
+		{AddedAt: "2024-03-05T00:00:00Z"},
+	}
+
+	want := []StatsGrowth{
+		{Month: "2023-12", Added: 1, Total: 1},
+		{Month: "2024-02", Added: 2, Total: 3},
+		{Month: "2024-03", Added: 1, Total: 4},
+	}
+	if got := countGrowth(library); !slices.Equal(got, want) {
+		t.Errorf("counted growth %v, expected %v", got, want)
+	}
+
+	if got := countGrowth(nil); len(got) != 0 {
+		t.Errorf("counted growth %v of no saved tracks", got)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values []int
+		want   string
+	}{
+		{nil, ""},
+		{[]int{0, 0}, "  "},
+		{[]int{1, 2, 3, 4, 5, 6, 7, 8}, "▁▂▃▄▅▆▇█"},
+		{[]int{0, 5, 10}, " ▄█"},
+		{[]int{1, 100}, "▁█"},
+		{[]int{3}, "█"},
+	}
+
+	for _, tt := range tests {
+		if got := sparkline(tt.values); got != tt.want {
+			t.Errorf("sparkline(%v) = %q, expected %q", tt.values, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+// Manager for the status bar.
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Width of the seek bar, including the progress and duration.
+const seekBarWidth = 50
+
+// Seek bar, showing the progress of the playing item. Clicking it seeks.
+type SeekBar struct {
+	*tview.Box
+
+	mu sync.Mutex
+	np *NowPlaying
+
+	style tcell.Style
+
+	// Request a seek to a position (in milliseconds).
+	seek func(position int)
+}
+
+// Create a seek bar, styled by a theme.
+func NewSeekBar(theme *Theme, seek func(position int)) *SeekBar {
+	_, bg, _ := theme.Status.Decompose()
+
+	bar := &SeekBar{
+		Box: tview.NewBox(),
+		np: &NowPlaying{},
+		style: theme.Status,
+		seek: seek,
+	}
+	bar.SetBackgroundColor(bg)
+
+	return bar
+}
+
+// Update the seek bar with a new player state.
+func (bar *SeekBar) Update(np *NowPlaying) {
+	bar.mu.Lock()
+	defer bar.mu.Unlock()
+
+	bar.np = np
+}
+
+// Get the position of the gauge on the screen, between the progress and the
+// duration.
+func (bar *SeekBar) gauge() (x, y, width int) {
+	x, y, width, _ = bar.GetInnerRect()
+	label := len(" 0:00:00 ")
+	return x + label, y, width - 2*label
+}
+
+// Draw the progress, the gauge, and the duration.
+func (bar *SeekBar) Draw(screen tcell.Screen) {
+	bar.Box.DrawForSubclass(screen, bar)
+
+	bar.mu.Lock()
+	np := bar.np
+	bar.mu.Unlock()
+
+	if np.Item == nil {
+		return
+	}
+
+	progress := np.EstimatedProgress()
+	duration := np.Item.Duration()
+
+	x, y, width := bar.gauge()
+	if width < 1 {
+		return
+	}
+
+	filled := 0
+	if duration > 0 {
+		filled = min(width, width*progress/duration)
+	}
+	line := strings.Repeat("━", filled) + strings.Repeat("─", width-filled)
+
+	left, _, full, _ := bar.GetInnerRect()
+	for i, r := range []rune(" " + FormatDuration(progress)) {
+		screen.SetContent(left+i, y, r, nil, bar.style)
+	}
+	for i, r := range []rune(line) {
+		screen.SetContent(x+i, y, r, nil, bar.style)
+	}
+	total := []rune(FormatDuration(duration) + " ")
+	for i, r := range total {
+		screen.SetContent(left+full-len(total)+i, y, r, nil, bar.style)
+	}
+}
+
+// Seek when the end user clicks the gauge.
+func (bar *SeekBar) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return bar.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		mx, my := event.Position()
+		if action != tview.MouseLeftClick || !bar.InRect(mx, my) {
+			return false, nil
+		}
+
+		bar.mu.Lock()
+		np := bar.np
+		bar.mu.Unlock()
+
+		x, _, width := bar.gauge()
+		if np.Item == nil || width < 1 || mx < x || mx >= x+width {
+			return true, nil
+		}
+
+		bar.seek(np.Item.Duration() * (mx - x) / width)
+		return true, nil
+	})
+}
+
+// Show what is playing in the status bar, updated with each player state.
+// While playing, the seek bar is redrawn every second. Will continue to run
+// in background.
+func StatusManager(ctx context.Context, app *tview.Application, status *tview.TextView, seek *SeekBar, state *StateWatcher) {
+	ch := state.Subscribe()
+	defer state.Unsubscribe(ch)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <- ctx.Done():
+			return
+
+		case np := <- ch:
+			line := FormatNowPlaying(np)
+			seek.Update(np)
+			app.QueueUpdateDraw(func() {
+				status.SetText(line)
+			})
+
+		case <- ticker.C:
+			if state.Latest().Playing {
+				app.Draw()
+			}
+		}
+	}
+}
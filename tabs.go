@@ -0,0 +1,150 @@
+package main
+
+// Tab bar, listing every page of the interactive interface with the active
+// one highlighted.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// A tab of the interactive interface. Some tabs open further pages, e.g. a
+// playlist from the playlists page; the tab returns to whichever of its pages
+// was shown last.
+type Tab struct {
+	Title string
+
+	// Pages of the tab. The first is shown by default.
+	Pages []string
+}
+
+// Every tab, in order. Tabs are numbered from 1.
+var tabs = []Tab{
+	{"Library", []string{"listing", "smart", "changes", "duplicates"}},
+	{"Playlists", []string{"playlists", "playlist"}},
+	{"Albums", []string{"albums", "album"}},
+	{"Podcasts", []string{"shows", "episodes"}},
+	{"Search", []string{"search", "artist"}},
+	{"Queue", []string{"queue", "history", "stats"}},
+	{"Devices", []string{"devices"}},
+	{"Logs", []string{"logs"}},
+	{"Help", []string{"help"}},
+}
+
+// Get the tab of a page, or -1 if none.
+func tabOf(page string) int {
+	for i, tab := range tabs {
+		for _, name := range tab.Pages {
+			if name == page {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Tab bar. Clicking a tab selects it.
+type TabBar struct {
+	*tview.TextView
+	theme *Theme
+
+	// Index of the active tab.
+	active int
+
+	// Page last shown by each tab.
+	pages []string
+
+	// Columns spanned by each tab, as [start, end).
+	spans [][2]int
+
+	// Called when the end user clicks a tab.
+	selected func(tab int)
+}
+
+// Create a tab bar, styled by a theme.
+func NewTabBar(theme *Theme, selected func(tab int)) *TabBar {
+	bar := &TabBar{
+		TextView: tview.NewTextView().SetDynamicColors(true).SetWrap(false).SetTextStyle(theme.Status),
+		theme: theme,
+		selected: selected,
+	}
+
+	_, bg, _ := theme.Status.Decompose()
+	bar.SetBackgroundColor(bg)
+
+	for _, tab := range tabs {
+		bar.pages = append(bar.pages, tab.Pages[0])
+	}
+
+	bar.SetMouseCapture(bar.mouseCapture)
+	bar.render()
+
+	return bar
+}
+
+// Redraw the tabs, highlighting the active tab.
+func (bar *TabBar) render() {
+	var text strings.Builder
+	bar.spans = nil
+
+	x := 0
+	for i, tab := range tabs {
+		label := fmt.Sprintf(" %d %s ", i+1, tab.Title)
+		bar.spans = append(bar.spans, [2]int{x, x + len(label)})
+		x += len(label)
+
+		if i == bar.active {
+			text.WriteString(StyleTag(bar.theme.Selected) + label + StyleTag(bar.theme.Status))
+		} else {
+			text.WriteString(label)
+		}
+	}
+
+	bar.SetText(text.String())
+}
+
+// Get the active tab.
+func (bar *TabBar) Active() int {
+	return bar.active
+}
+
+// Get the page to show for a tab, i.e. the one shown last.
+func (bar *TabBar) Page(tab int) string {
+	return bar.pages[tab]
+}
+
+// Record that a page is shown, activating its tab.
+func (bar *TabBar) Visit(page string) {
+	tab := tabOf(page)
+	if tab < 0 {
+		return
+	}
+
+	bar.pages[tab] = page
+	bar.active = tab
+	bar.render()
+}
+
+// Handle clicks on a tab. The tab bar never takes focus.
+func (bar *TabBar) mouseCapture(action tview.MouseAction, ev *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+	switch action {
+	case tview.MouseLeftClick:
+		left, _, _, _ := bar.GetInnerRect()
+		x, _ := ev.Position()
+		for tab, span := range bar.spans {
+			if span[0] <= x-left && x-left < span[1] {
+				bar.selected(tab)
+				break
+			}
+		}
+		return action, nil
+
+	case tview.MouseLeftDown, tview.MouseLeftUp, tview.MouseLeftDoubleClick:
+		return action, nil
+	}
+
+	return action, ev
+}
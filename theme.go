@@ -0,0 +1,358 @@
+package main
+
+// Themes of the interactive interface. A theme maps each element to a style,
+// written as a foreground color, optionally `on` a background color, and any
+// attributes, e.g. `white on blue bold`. Colors are named (see the W3C color
+// names), `#rrggbb`, or `default`.
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Elements of a theme.
+var themeElements = []string{
+	"text",
+	"selected",
+	"header",
+	"playing",
+	"marked",
+	"status",
+	"log-trace",
+	"log-debug",
+	"log-info",
+	"log-warning",
+	"log-error",
+	"log-fatal",
+	"log-panic",
+}
+
+// Built-in themes.
+var builtinThemes = map[string]map[string]string{
+	"dark": {
+		"text": "white on default",
+		"selected": "black on white",
+		"header": "yellow bold",
+		"playing": "lime bold",
+		"marked": "fuchsia",
+		"status": "black on silver",
+		"log-trace": "gray",
+		"log-debug": "silver",
+		"log-info": "aqua",
+		"log-warning": "yellow",
+		"log-error": "red",
+		"log-fatal": "red bold",
+		"log-panic": "red bold",
+	},
+	"light": {
+		"text": "black on white",
+		"selected": "white on navy",
+		"header": "navy bold",
+		"playing": "green bold",
+		"marked": "purple",
+		"status": "white on navy",
+		"log-trace": "gray",
+		"log-debug": "gray",
+		"log-info": "teal",
+		"log-warning": "olive",
+		"log-error": "maroon",
+		"log-fatal": "maroon bold",
+		"log-panic": "maroon bold",
+	},
+	"monochrome": {
+		"text": "default",
+		"selected": "reverse",
+		"header": "bold",
+		"playing": "bold underline",
+		"marked": "italic",
+		"status": "reverse",
+		"log-trace": "dim",
+		"log-debug": "dim",
+		"log-info": "default",
+		"log-warning": "bold",
+		"log-error": "bold",
+		"log-fatal": "bold underline",
+		"log-panic": "bold underline",
+	},
+}
+
+// Theme that user themes start from, unless they set `base`.
+const defaultTheme = "dark"
+
+// Attributes by name.
+var themeAttributes = map[string]tcell.AttrMask{
+	"bold": tcell.AttrBold,
+	"dim": tcell.AttrDim,
+	"italic": tcell.AttrItalic,
+	"underline": tcell.AttrUnderline,
+	"reverse": tcell.AttrReverse,
+	"blink": tcell.AttrBlink,
+}
+
+// Styles of the interactive interface.
+type Theme struct {
+	Text     tcell.Style
+	Selected tcell.Style
+	Header   tcell.Style
+	Playing  tcell.Style
+	Marked   tcell.Style
+	Status   tcell.Style
+	Levels   map[log.Level]tcell.Style
+}
+
+// Get the names of the built-in themes and user themes.
+func ThemeNames(user map[string]map[string]string) []string {
+	names := []string{}
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	for name := range user {
+		if _, ok := builtinThemes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Parse a color.
+func parseColor(name string) (tcell.Color, error) {
+	if name == "default" {
+		return tcell.ColorDefault, nil
+	}
+
+	color := tcell.GetColor(name)
+	if color == tcell.ColorDefault {
+		return color, fmt.Errorf("invalid color: %s", name)
+	}
+
+	return color, nil
+}
+
+// Parse a style, e.g. `white on blue bold`.
+func ParseStyle(spec string) (tcell.Style, error) {
+	style := tcell.StyleDefault
+
+	words := strings.Fields(strings.ToLower(spec))
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+
+		if attr, ok := themeAttributes[word]; ok {
+			style = style.Attributes(attr | attributes(style))
+			continue
+		}
+
+		if word == "on" {
+			if i++; i == len(words) {
+				return style, fmt.Errorf("missing background color: %s", spec)
+			}
+			color, err := parseColor(words[i])
+			if err != nil {
+				return style, err
+			}
+			style = style.Background(color)
+			continue
+		}
+
+		color, err := parseColor(word)
+		if err != nil {
+			return style, err
+		}
+		style = style.Foreground(color)
+	}
+
+	return style, nil
+}
+
+// Get the attributes of a style.
+func attributes(style tcell.Style) tcell.AttrMask {
+	_, _, attr := style.Decompose()
+	return attr
+}
+
+// Create a theme by name, from the built-in themes and user themes. User
+// themes override built-in themes of the same name, and start from the
+// theme named by `base` (or the default theme).
+func NewTheme(name string, user map[string]map[string]string) (*Theme, error) {
+	specs, err := themeSpecs(name, user, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	theme := &Theme{Levels: map[log.Level]tcell.Style{}}
+	for _, element := range themeElements {
+		style, err := ParseStyle(specs[element])
+		if err != nil {
+			return nil, fmt.Errorf("theme %s: %s: %w", name, element, err)
+		}
+
+		switch element {
+		case "text":
+			theme.Text = style
+		case "selected":
+			theme.Selected = style
+		case "header":
+			theme.Header = style
+		case "playing":
+			theme.Playing = style
+		case "marked":
+			theme.Marked = style
+		case "status":
+			theme.Status = style
+		default:
+			level, _ := parseLogLevel(strings.TrimPrefix(element, "log-"))
+			theme.Levels[level] = style
+		}
+	}
+
+	return theme, nil
+}
+
+// Resolve the style of every element of a theme, following `base`.
+func themeSpecs(name string, user map[string]map[string]string, seen map[string]bool) (map[string]string, error) {
+	builtin, isBuiltin := builtinThemes[name]
+
+	spec, ok := user[name]
+	if !ok {
+		if !isBuiltin {
+			return nil, fmt.Errorf("unknown theme: %s (try one of: %s)", name, strings.Join(ThemeNames(user), ", "))
+		}
+		return builtin, nil
+	}
+
+	if seen[name] {
+		return nil, fmt.Errorf("theme %s: circular base", name)
+	}
+	seen[name] = true
+
+	// A user theme named after a built-in theme modifies it.
+	base, ok := spec["base"]
+	if !ok {
+		base = defaultTheme
+		if isBuiltin {
+			base = name
+		}
+	}
+
+	specs := builtin
+	if base != name || !isBuiltin {
+		var err error
+		if specs, err = themeSpecs(base, user, seen); err != nil {
+			return nil, err
+		}
+	}
+
+	merged := map[string]string{}
+	for element, style := range specs {
+		merged[element] = style
+	}
+	for element, style := range spec {
+		if element == "base" {
+			continue
+		}
+		if _, ok := merged[element]; !ok {
+			return nil, fmt.Errorf("theme %s: unknown element: %s", name, element)
+		}
+		merged[element] = style
+	}
+
+	return merged, nil
+}
+
+// Apply a theme to the defaults of every primitive. Must be called before
+// any primitives are created.
+func (theme *Theme) Apply() {
+	fg, bg, _ := theme.Text.Decompose()
+
+	tview.Styles.PrimitiveBackgroundColor = bg
+	tview.Styles.ContrastBackgroundColor = bg
+	tview.Styles.MoreContrastBackgroundColor = bg
+	tview.Styles.PrimaryTextColor = fg
+	tview.Styles.SecondaryTextColor = fg
+	tview.Styles.TertiaryTextColor = fg
+	tview.Styles.InverseTextColor = fg
+	tview.Styles.ContrastSecondaryTextColor = fg
+	tview.Styles.BorderColor = fg
+	tview.Styles.TitleColor = fg
+	tview.Styles.GraphicsColor = fg
+}
+
+// Apply a theme to a table.
+func (theme *Theme) ApplyTable(table *tview.Table) *tview.Table {
+	_, bg, _ := theme.Text.Decompose()
+	table.SetBackgroundColor(bg)
+	return table.SetSelectedStyle(theme.Selected)
+}
+
+// Format a color as a color tag.
+func colorTag(color tcell.Color) string {
+	if color == tcell.ColorDefault {
+		return "-"
+	}
+	return fmt.Sprintf("#%06x", color.Hex())
+}
+
+// Format a style as a color tag, e.g. `[#ffffff:#000000:b]`.
+func StyleTag(style tcell.Style) string {
+	fg, bg, attr := style.Decompose()
+
+	flags := ""
+	for _, flag := range []struct {
+		attr tcell.AttrMask
+		code string
+	}{
+		{tcell.AttrBold, "b"},
+		{tcell.AttrDim, "d"},
+		{tcell.AttrItalic, "i"},
+		{tcell.AttrUnderline, "u"},
+		{tcell.AttrReverse, "r"},
+		{tcell.AttrBlink, "l"},
+	} {
+		if attr&flag.attr != 0 {
+			flags += flag.code
+		}
+	}
+	if flags == "" {
+		flags = "-"
+	}
+
+	return fmt.Sprintf("[%s:%s:%s]", colorTag(fg), colorTag(bg), flags)
+}
+
+// Formatter for log messages written to the logs page, with the level styled
+// by the theme.
+type themeFormatter struct {
+	theme *Theme
+}
+
+// Format a log entry as a line with color tags.
+func (f *themeFormatter) Format(entry *log.Entry) ([]byte, error) {
+	var b bytes.Buffer
+
+	level := strings.ToUpper(entry.Level.String())
+	if len(level) > 4 {
+		level = level[:4]
+	}
+
+	fmt.Fprintf(&b, "%s%s[-:-:-] %s", StyleTag(f.theme.Levels[entry.Level]), level, tview.Escape(entry.Message))
+
+	keys := []string{}
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%s", key, tview.Escape(fmt.Sprint(entry.Data[key])))
+	}
+
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
@@ -4,10 +4,10 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/zmb3/spotify/v2"
-	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
@@ -38,12 +38,40 @@ func FormatDuration(ms int) string {
 	return fmt.Sprintf("%d:%02d:%02d", h, m, s)
 }
 
+// Parse a duration formatted as `[HH]:[MM]:[SS]`, `[MM]:[SS]`, or `[SS]` into
+// milliseconds.
+func ParseDuration(s string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+
+	seconds := 0
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+		seconds = seconds*60 + n
+	}
+
+	return seconds * 1000, nil
+}
+
+// Columns of a table of tracks.
+var trackColumns = []Column{
+	{"Name", "name"},
+	{"Artist", "artist"},
+	{"Album", "album"},
+	{"Duration", "duration"},
+}
+
 // Create a row of table cells from a Spotify track.
-func IntoCells(track *spotify.FullTrack) []*tview.TableCell {
-	name := tview.NewTableCell(track.Name).SetTextColor(tcell.ColorWhite).SetReference(track.URI)
-	artist := tview.NewTableCell(FormatArtists(track.Artists)).SetTextColor(tcell.ColorWhite)
-	album := tview.NewTableCell(track.Album.Name).SetTextColor(tcell.ColorWhite)
-	duration := tview.NewTableCell(FormatDuration(track.Duration)).SetAlign(tview.AlignRight).SetTextColor(tcell.ColorWhite)
+func TrackIntoCells(track *spotify.FullTrack) []*tview.TableCell {
+	name := tview.NewTableCell(track.Name)
+	artist := tview.NewTableCell(FormatArtists(track.Artists))
+	album := tview.NewTableCell(track.Album.Name)
+	duration := tview.NewTableCell(FormatDuration(track.Duration)).SetAlign(tview.AlignRight)
 
 	//id := track.ID
 	//number := track.TrackNumber
@@ -0,0 +1,199 @@
+package main
+
+// Spotify URI interactions.
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Types of Spotify URIs.
+type URIType string
+
+const (
+	// URI of a track.
+	TrackURI URIType = "track"
+
+	// URI of a podcast episode.
+	EpisodeURI URIType = "episode"
+
+	// URI of an album.
+	AlbumURI URIType = "album"
+
+	// URI of a playlist.
+	PlaylistURI URIType = "playlist"
+
+	// URI of an artist.
+	ArtistURI URIType = "artist"
+
+	// URI of a podcast show.
+	ShowURI URIType = "show"
+)
+
+// Base address of links to the Spotify web player.
+const linkBase = "https://open.spotify.com/"
+
+// A parsed Spotify URI.
+type URI struct {
+	Type URIType
+	ID   spotify.ID
+}
+
+// Check that a URI type is known.
+func validType(typ URIType) bool {
+	switch typ {
+	case TrackURI, EpisodeURI, AlbumURI, PlaylistURI, ArtistURI, ShowURI:
+		return true
+	}
+	return false
+}
+
+// Check that an ID is base-62.
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !(('0' <= r && r <= '9') || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z')) {
+			return false
+		}
+	}
+	return true
+}
+
+// Create a URI from its parts, validating them.
+func newURI(typ, id string) (*URI, error) {
+	if !validType(URIType(typ)) {
+		return nil, fmt.Errorf("unknown URI type: %s", typ)
+	}
+	if !validID(id) {
+		return nil, fmt.Errorf("invalid ID: %s", id)
+	}
+
+	return &URI{Type: URIType(typ), ID: spotify.ID(id)}, nil
+}
+
+// Parse a Spotify URI (e.g. `spotify:track:[ID]`) or a link to the Spotify
+// web player (e.g. `https://open.spotify.com/track/[ID]?si=...`).
+func ParseURI(s string) (*URI, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.HasPrefix(s, "spotify:") {
+		parts := strings.Split(s, ":")
+
+		// Legacy playlist URIs are namespaced by user, e.g.
+		// `spotify:user:[USER]:playlist:[ID]`.
+		if len(parts) == 5 && parts[1] == "user" {
+			parts = []string{parts[0], parts[3], parts[4]}
+		}
+
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid URI: %s", s)
+		}
+
+		return newURI(parts[1], parts[2])
+	}
+
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	link, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid link: %w", err)
+	}
+	if link.Host != "open.spotify.com" {
+		return nil, fmt.Errorf("not a Spotify URI or link: %s", s)
+	}
+
+	parts := strings.Split(strings.Trim(link.Path, "/"), "/")
+
+	// Localized links are prefixed, e.g. `/intl-de/track/[ID]`.
+	if len(parts) > 0 && strings.HasPrefix(parts[0], "intl-") {
+		parts = parts[1:]
+	}
+
+	// Legacy playlist links are namespaced by user, e.g.
+	// `/user/[USER]/playlist/[ID]`.
+	if len(parts) == 4 && parts[0] == "user" {
+		parts = parts[2:]
+	}
+
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid link: %s", s)
+	}
+
+	return newURI(parts[0], parts[1])
+}
+
+// Parse every whitespace-delimited Spotify URI or link in some text.
+func ParseURIs(text string) ([]*URI, error) {
+	uris := []*URI{}
+	for _, field := range strings.Fields(text) {
+		uri, err := ParseURI(field)
+		if err != nil {
+			return nil, err
+		}
+		uris = append(uris, uri)
+	}
+
+	if len(uris) == 0 {
+		return nil, fmt.Errorf("no URIs found")
+	}
+
+	return uris, nil
+}
+
+// Format a URI as `spotify:[type]:[ID]`.
+func (uri *URI) String() string {
+	return fmt.Sprintf("spotify:%s:%s", uri.Type, uri.ID)
+}
+
+// Convert a URI to the type used by the client library.
+func (uri *URI) Spotify() spotify.URI {
+	return spotify.URI(uri.String())
+}
+
+// Format a URI as a link to the Spotify web player.
+func (uri *URI) Link() string {
+	return fmt.Sprintf("%s%s/%s", linkBase, uri.Type, uri.ID)
+}
+
+// Check if a URI refers to a playback context (i.e. a collection of playable
+// items) rather than a single playable item.
+func (uri *URI) IsContext() bool {
+	switch uri.Type {
+	case AlbumURI, PlaylistURI, ArtistURI, ShowURI:
+		return true
+	}
+	return false
+}
+
+// Check if a URI refers to a single playable item. Only these can be
+// enqueued.
+func (uri *URI) IsPlayable() bool {
+	switch uri.Type {
+	case TrackURI, EpisodeURI:
+		return true
+	}
+	return false
+}
+
+// Parse a playlist's URI, link, or ID.
+func parsePlaylistID(s string) (spotify.ID, error) {
+	if validID(s) {
+		return spotify.ID(s), nil
+	}
+
+	uri, err := ParseURI(s)
+	if err != nil {
+		return "", err
+	}
+	if uri.Type != PlaylistURI {
+		return "", fmt.Errorf("not a playlist: %s", s)
+	}
+
+	return uri.ID, nil
+}
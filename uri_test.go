@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"spotify:track:6rqhFgbbKwnb9MLmUQDhG6", "spotify:track:6rqhFgbbKwnb9MLmUQDhG6"},
+		{"  spotify:album:4aawyAB9vmqN3uQ7FjRGTy\n", "spotify:album:4aawyAB9vmqN3uQ7FjRGTy"},
+		{"spotify:user:someone:playlist:37i9dQZF1DXcBWIGoYBM5M", "spotify:playlist:37i9dQZF1DXcBWIGoYBM5M"},
+		{"https://open.spotify.com/track/6rqhFgbbKwnb9MLmUQDhG6?si=abc", "spotify:track:6rqhFgbbKwnb9MLmUQDhG6"},
+		{"open.spotify.com/episode/512ojhOuo1ktJprKbVcKyQ", "spotify:episode:512ojhOuo1ktJprKbVcKyQ"},
+		{"https://open.spotify.com/intl-de/artist/0OdUWJ0sBjDrqHygGUXeCF", "spotify:artist:0OdUWJ0sBjDrqHygGUXeCF"},
+		{"https://open.spotify.com/user/someone/playlist/37i9dQZF1DXcBWIGoYBM5M", "spotify:playlist:37i9dQZF1DXcBWIGoYBM5M"},
+		{"https://open.spotify.com/show/5CfCWKI5pZ28U0uOzXkDHe/", "spotify:show:5CfCWKI5pZ28U0uOzXkDHe"},
+
+		// Invalid
+		{"", ""},
+		{"spotify:track", ""},
+		{"spotify:song:6rqhFgbbKwnb9MLmUQDhG6", ""},
+		{"spotify:track:6rqh-FgbbKwnb9", ""},
+		{"spotify:track:", ""},
+		{"https://example.com/track/6rqhFgbbKwnb9MLmUQDhG6", ""},
+		{"https://open.spotify.com/track", ""},
+		{"https://open.spotify.com/genre/6rqhFgbbKwnb9MLmUQDhG6", ""},
+	}
+
+	for _, tt := range tests {
+		uri, err := ParseURI(tt.input)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ParseURI(%q) = %s, expected an error", tt.input, uri)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseURI(%q) failed: %v", tt.input, err)
+		} else if got := uri.String(); got != tt.want {
+			t.Errorf("ParseURI(%q) = %s, expected %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseURIs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{
+			"spotify:track:6rqhFgbbKwnb9MLmUQDhG6\nhttps://open.spotify.com/album/4aawyAB9vmqN3uQ7FjRGTy\n",
+			[]string{"spotify:track:6rqhFgbbKwnb9MLmUQDhG6", "spotify:album:4aawyAB9vmqN3uQ7FjRGTy"},
+		},
+		{
+			" spotify:track:6rqhFgbbKwnb9MLmUQDhG6 \t spotify:track:6rqhFgbbKwnb9MLmUQDhG6",
+			[]string{"spotify:track:6rqhFgbbKwnb9MLmUQDhG6", "spotify:track:6rqhFgbbKwnb9MLmUQDhG6"},
+		},
+
+		// Invalid
+		{"", nil},
+		{" \n\t", nil},
+		{"spotify:track:6rqhFgbbKwnb9MLmUQDhG6 not-a-uri", nil},
+	}
+
+	for _, tt := range tests {
+		uris, err := ParseURIs(tt.input)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("ParseURIs(%q) = %v, expected an error", tt.input, uris)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseURIs(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if len(uris) != len(tt.want) {
+			t.Errorf("ParseURIs(%q) found %d URIs, expected %d", tt.input, len(uris), len(tt.want))
+			continue
+		}
+		for i, uri := range uris {
+			if got := uri.String(); got != tt.want[i] {
+				t.Errorf("ParseURIs(%q)[%d] = %s, expected %s", tt.input, i, got, tt.want[i])
+			}
+		}
+	}
+}